@@ -0,0 +1,34 @@
+package cah
+
+import (
+	"time"
+)
+
+// APITokenStore keeps the personal API tokens. Like the sessions, tokens are stored hashed.
+type APITokenStore interface {
+	Create(t APIToken) (APIToken, error)
+	ByToken(tokenHash string) (APIToken, error)
+	ByUser(userID int) ([]APIToken, error)
+	Touch(id int, lastUsed time.Time) error
+	Delete(id int) error
+}
+
+type APITokenUsecases interface {
+	// Create returns the new token along with its secret, which is not stored and can not be shown again
+	Create(u User, name string) (t APIToken, token string, err error)
+	// ByToken returns the API token of the secret, if it was not revoked
+	ByToken(token string) (APIToken, bool)
+	ByUser(userID int) ([]APIToken, error)
+	Revoke(userID int, id int) error
+}
+
+// APIToken lets bots and scripts use the API as the user, sending it as an "Authorization: Bearer" header.
+// LastUsedAt is zero if the token was never used.
+type APIToken struct {
+	ID         int       `json:"id" db:"api_token"`
+	UserID     int       `json:"-" db:"user"`
+	Name       string    `json:"name" db:"name"`
+	TokenHash  string    `json:"-" db:"token"`
+	CreatedAt  time.Time `json:"createdAt" db:"-"`
+	LastUsedAt time.Time `json:"lastUsedAt" db:"-"`
+}
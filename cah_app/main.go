@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
@@ -16,6 +16,9 @@ import (
 	"github.com/j4rv/cah/usecase/fixture"
 )
 
+const expansionsDir = "expansions"
+const expansionsPollInterval = 2 * time.Second
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
@@ -31,13 +34,26 @@ func run() {
 	gameStore := mem.GetGameStore()
 	cardStore := mem.GetCardStore()
 	userStore := sqlite.NewUserStore()
+	cardBanStore := sqlite.NewCardBanStore()
+	moderationStore := sqlite.NewModerationStore()
+	statsStore := sqlite.NewStatsStore()
 	usecases := cah.Usecases{
-		GameState: usecase.NewGameStateUsecase(stateStore),
-		Card:      usecase.NewCardUsecase(cardStore),
-		User:      usecase.NewUserUsecase(userStore),
-		Game:      usecase.NewGameUsecase(gameStore),
+		GameState:  usecase.NewGameStateUsecase(stateStore, statsStore),
+		Card:       usecase.NewCardUsecase(cardStore, cardBanStore, moderationStore, expansionsDir),
+		User:       usecase.NewUserUsecase(userStore),
+		Game:       usecase.NewGameUsecase(gameStore, statsStore),
+		Stats:      usecase.NewStatsUsecase(statsStore, cardStore),
+		Session:    usecase.NewSessionUsecase(sqlite.NewSessionStore()),
+		TwoFactor:  usecase.NewTwoFactorUsecase(sqlite.NewTwoFactorStore(), "J4RV's CAH"),
+		APIToken:   usecase.NewAPITokenUsecase(sqlite.NewAPITokenStore()),
+		Friend:     usecase.NewFriendUsecase(sqlite.NewFriendStore(), userStore),
+		GameInvite: usecase.NewGameInviteUsecase(mem.GetGameInviteStore(), nil),
+	}
+	// The expansions are watched while the server runs
+	err := usecases.Card.WatchExpansions(context.Background(), expansionsPollInterval)
+	if err != nil {
+		log.Fatal("error while populating cards. Is there an expansions folder in the active dir?", err)
 	}
-	populateCards(usecases.Card)
 
 	fixture.PopulateUsers(usecases.User)
 	createTestGames(usecases)
@@ -107,19 +123,3 @@ func getTestUsers(usecase cah.Usecases) []cah.User {
 	}
 	return users
 }
-
-func populateCards(cardUC cah.CardUsecases) {
-	files, err := ioutil.ReadDir("expansions")
-	if err != nil {
-		log.Fatal("error while populating cards. Is there an expansions folder in the active dir?", err)
-	}
-	for _, f := range files {
-		if f.IsDir() {
-			log.Println("Loading cards from", f.Name())
-			err := cardUC.CreateFromFolder("expansions/"+f.Name(), f.Name())
-			if err != nil {
-				fmt.Println("Got error while loading cards", err)
-			}
-		}
-	}
-}
@@ -1,36 +1,190 @@
 package cah
 
-import "io"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 type CardStore interface {
-	CreateWhite(text, expansion string) error
-	CreateBlack(text, expansion string, blanks int) error
+	CreateWhite(text, expansion string, tags ...string) error
+	CreateBlack(text, expansion string, blanks int, tags ...string) error
 	AllWhites() ([]*WhiteCard, error)
 	AllBlacks() ([]*BlackCard, error)
+	WhitesByID(...int) ([]*WhiteCard, error)
+	BlacksByID(...int) ([]*BlackCard, error)
 	ExpansionWhites(...string) ([]*WhiteCard, error)
 	ExpansionBlacks(...string) ([]*BlackCard, error)
 	AvailableExpansions() ([]string, error)
+	Expansion(name string) (Expansion, error)
+	Expansions() ([]Expansion, error)
+	ReplaceExpansion(e Expansion, whites []*WhiteCard, blacks []*BlackCard) error
+	RenameExpansion(oldName, newName string) error
+	DeleteExpansion(name string) error
+	Search(CardSearch) (CardSearchResult, error)
+	SetTags(cardID int, tags []string) error
+	SetText(cardID int, text string, blanks int) error
+	SetDisabled(cardID int, disabled bool) error
 }
 
 type CardUsecases interface {
 	CreateFromReaders(wdat, bdat io.Reader, expansionName string) error
 	CreateFromFolder(folderPath, expansionName string) error
+	// WatchExpansions loads the expansions and keeps them updated until the context is done
+	WatchExpansions(ctx context.Context, interval time.Duration) error
+	Upload(owner User, info Expansion, wdat, bdat io.Reader) error
+	UploadZip(owner User, info Expansion, zdat io.ReaderAt, size int64) error
 	AllWhites() []*WhiteCard
 	AllBlacks() []*BlackCard
+	WhitesByID(...int) ([]*WhiteCard, error)
+	BlacksByID(...int) ([]*BlackCard, error)
 	ExpansionWhites(...string) []*WhiteCard
 	ExpansionBlacks(...string) []*BlackCard
 	AvailableExpansions() []string
+	Expansion(name string) (Expansion, error)
+	Expansions() []Expansion
+	RenameExpansion(oldName, newName string) error
+	DeleteExpansion(name string) error
+	Search(CardSearch) (CardSearchResult, error)
+	BanCard(u User, cardID int) error
+	UnbanCard(u User, cardID int) error
+	BannedCards(u User) []int
+	SetTags(cardID int, tags []string) error
+	ReportCard(u User, cardID int, reason string) error
+	CardReports() []CardReport
+	DismissReports(cardID int) error
+	EditCard(cardID int, text string) error
+	DisableCard(cardID int, disabled bool) error
+}
+
+// CardModerationStore keeps the changes made to cards by moderators,
+// so they can be applied again when the cards are loaded.
+// It also keeps the card reports made by the players.
+type CardModerationStore interface {
+	SetTags(cardID int, tags []string) error
+	Tags() (map[int][]string, error)
+	SetText(cardID int, text string) error
+	Texts() (map[int]string, error)
+	SetDisabled(cardID int, disabled bool) error
+	Disabled() ([]int, error)
+	Report(userID, cardID int, reason string) error
+	Reports() ([]CardReport, error)
+	ResolveReports(cardID int) error
+}
+
+// CardBanStore keeps the personal card ban lists of the users
+type CardBanStore interface {
+	Ban(userID, cardID int) error
+	Unban(userID, cardID int) error
+	Banned(userID int) ([]int, error)
 }
 
 type WhiteCard struct {
-	ID        int    `json:"-" db:"white_card"`
-	Text      string `json:"text" db:"text"`
-	Expansion string `json:"expansion" db:"expansion"`
+	ID        int      `json:"id" db:"white_card"`
+	Text      string   `json:"text" db:"text"`
+	Expansion string   `json:"expansion" db:"expansion"`
+	Tags      []string `json:"tags,omitempty" db:"-"`
+	Disabled  bool     `json:"disabled,omitempty" db:"-"`
 }
 
 type BlackCard struct {
-	ID        int    `json:"-" db:"black_card"`
-	Text      string `json:"text" db:"text"`
-	Expansion string `json:"expansion" db:"expansion"`
-	Blanks    int    `json:"blanks" db:"blanks"`
+	ID        int      `json:"id" db:"black_card"`
+	Text      string   `json:"text" db:"text"`
+	Expansion string   `json:"expansion" db:"expansion"`
+	Blanks    int      `json:"blanks" db:"blanks"`
+	Tags      []string `json:"tags,omitempty" db:"-"`
+	Disabled  bool     `json:"disabled,omitempty" db:"-"`
+}
+
+// CardReport is a card reported by a player. A player has at most one report per card,
+// reporting the same card again replaces the reason.
+// Reports are resolved when a moderator changes the card or dismisses them.
+type CardReport struct {
+	ID        int       `json:"id" db:"card_report"`
+	CardID    int       `json:"cardID" db:"card"`
+	UserID    int       `json:"userID" db:"user"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Content tags used by the cards, expansions can use other tags too
+const (
+	NSFWTag      = "nsfw"
+	PoliticalTag = "political"
+	LanguageTag  = "language"
+)
+
+// HasTag reports if the card has any of the tags
+func (c WhiteCard) HasTag(tags ...string) bool {
+	return HasTag(c.Tags, tags...)
+}
+
+// HasTag reports if the card has any of the tags
+func (c BlackCard) HasTag(tags ...string) bool {
+	return HasTag(c.Tags, tags...)
+}
+
+// HasTag reports if any of the tags is in the tagged list
+func HasTag(tagged []string, tags ...string) bool {
+	for _, ct := range tagged {
+		for _, t := range tags {
+			if ct == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expansion holds the metadata of a group of cards.
+// The expansion Tags are added to all of its cards.
+// Expansions loaded from the expansions folder without an owner have OwnerID 0.
+// Whites, Blacks and Picks are computed by the store from the expansion cards,
+// Picks maps a blanks amount to the amount of black cards with that many blanks.
+type Expansion struct {
+	Name string `json:"name"`
+	// Key is used for the IDs of the cards, so they do not change when the expansion is renamed.
+	// Expansions without a key use their name.
+	Key         string      `json:"-"`
+	OwnerID     int         `json:"ownerID"`
+	Language    string      `json:"language,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Whites      int         `json:"whites"`
+	Blacks      int         `json:"blacks"`
+	Picks       map[int]int `json:"picks"`
+}
+
+const (
+	WhiteCardType = "white"
+	BlackCardType = "black"
+)
+
+// CardSearch filters cards by text, expansions and type.
+// Every word in Query needs to match the start of a word in the card text.
+// An empty Query, Expansions or Type does not filter by that field.
+type CardSearch struct {
+	Query      string
+	Expansions []string
+	Type       string
+	Offset     int
+	Limit      int
+	// IncludeDisabled also finds the disabled cards, so moderators can enable them again
+	IncludeDisabled bool
+}
+
+// CardSearchResult holds a page of the cards found, Total is the amount of cards found in all the pages
+type CardSearchResult struct {
+	Total int           `json:"total"`
+	Cards []*SearchCard `json:"cards"`
+}
+
+// SearchCard is a white or a black card found by a CardSearch
+type SearchCard struct {
+	ID        int    `json:"id"`
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Expansion string `json:"expansion"`
+	Blanks    int    `json:"blanks,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
 }
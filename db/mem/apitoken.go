@@ -0,0 +1,73 @@
+package mem
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type apiTokenMemStore struct {
+	abstractMemStore
+	tokens map[int]cah.APIToken
+}
+
+// NewAPITokenStore returns an empty store, each call keeps its own tokens
+func NewAPITokenStore() *apiTokenMemStore {
+	return &apiTokenMemStore{tokens: map[int]cah.APIToken{}}
+}
+
+func (store *apiTokenMemStore) Create(t cah.APIToken) (cah.APIToken, error) {
+	store.Lock()
+	defer store.Unlock()
+	t.ID = store.nextID()
+	store.tokens[t.ID] = t
+	return t, nil
+}
+
+func (store *apiTokenMemStore) ByToken(tokenHash string) (cah.APIToken, error) {
+	store.Lock()
+	defer store.Unlock()
+	for _, t := range store.tokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+	return cah.APIToken{}, errors.New("No API token found with that token")
+}
+
+// ByUser returns the API tokens of the user, the newest first
+func (store *apiTokenMemStore) ByUser(userID int) ([]cah.APIToken, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := []cah.APIToken{}
+	for _, t := range store.tokens {
+		if t.UserID == userID {
+			ret = append(ret, t)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID > ret[j].ID
+	})
+	return ret, nil
+}
+
+func (store *apiTokenMemStore) Touch(id int, lastUsed time.Time) error {
+	store.Lock()
+	defer store.Unlock()
+	t, ok := store.tokens[id]
+	if !ok {
+		return nil
+	}
+	t.LastUsedAt = lastUsed
+	store.tokens[id] = t
+	return nil
+}
+
+func (store *apiTokenMemStore) Delete(id int) error {
+	store.Lock()
+	defer store.Unlock()
+	delete(store.tokens, id)
+	return nil
+}
@@ -3,6 +3,7 @@ package mem
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 
 	"github.com/j4rv/cah"
@@ -12,38 +13,99 @@ type cardMemStore struct {
 	abstractMemStore
 	whiteCards map[string][]*cah.WhiteCard
 	blackCards map[string][]*cah.BlackCard
+	expansions map[string]*cah.Expansion
+	index      cardIndex
 }
 
 var cardStore = &cardMemStore{
 	whiteCards: map[string][]*cah.WhiteCard{},
 	blackCards: map[string][]*cah.BlackCard{},
+	expansions: map[string]*cah.Expansion{},
+	index:      newCardIndex(),
 }
 
 func GetCardStore() *cardMemStore {
 	return cardStore
 }
 
-func (store *cardMemStore) CreateWhite(t, e string) error {
-	if len(t) == 0 {
-		return errors.New("Card text cannot be empty")
-	}
-	if len(t) > 120 {
-		return errors.New("Card text cannot be longer than 120")
-	}
-	if len(e) == 0 {
-		return errors.New("Expansion cannot be empty")
+func (store *cardMemStore) CreateWhite(t, e string, tags ...string) error {
+	if err := checkWhite(t, e); err != nil {
+		return err
 	}
 	store.Lock()
 	defer store.Unlock()
 	c := &cah.WhiteCard{}
-	c.ID = store.nextID()
+	store.registerExpansion(e)
+	c.ID = store.cardID(cah.WhiteCardType, store.expansions[e].Key, t)
 	c.Text = t
 	c.Expansion = e
+	c.Tags = tags
 	store.whiteCards[e] = append(store.whiteCards[e], c)
+	store.index.addWhite(c)
+	return nil
+}
+
+func (store *cardMemStore) CreateBlack(t, e string, blanks int, tags ...string) error {
+	if err := checkBlack(t, e, blanks); err != nil {
+		return err
+	}
+	store.Lock()
+	defer store.Unlock()
+	c := &cah.BlackCard{}
+	store.registerExpansion(e)
+	c.ID = store.cardID(cah.BlackCardType, store.expansions[e].Key, t)
+	c.Text = t
+	c.Expansion = e
+	c.Blanks = blanks
+	c.Tags = tags
+	store.blackCards[e] = append(store.blackCards[e], c)
+	store.index.addBlack(c)
+	return nil
+}
+
+// ReplaceExpansion stores an expansion with the provided cards, replacing the previous ones at once.
+// Non valid cards are skipped. Games that already have the old cards in their decks keep them.
+func (store *cardMemStore) ReplaceExpansion(e cah.Expansion, whites []*cah.WhiteCard, blacks []*cah.BlackCard) error {
+	if len(e.Name) == 0 {
+		return errors.New("Expansion cannot be empty")
+	}
+	newWhites := make([]*cah.WhiteCard, 0, len(whites))
+	for _, c := range whites {
+		if err := checkWhite(c.Text, e.Name); err != nil {
+			log.Printf("Skipping white card '%s' from expansion %s: %s\n", c.Text, e.Name, err)
+			continue
+		}
+		newWhites = append(newWhites, &cah.WhiteCard{Text: c.Text, Expansion: e.Name, Tags: c.Tags})
+	}
+	newBlacks := make([]*cah.BlackCard, 0, len(blacks))
+	for _, c := range blacks {
+		if err := checkBlack(c.Text, e.Name, c.Blanks); err != nil {
+			log.Printf("Skipping black card '%s' from expansion %s: %s\n", c.Text, e.Name, err)
+			continue
+		}
+		newBlacks = append(newBlacks, &cah.BlackCard{Text: c.Text, Expansion: e.Name, Blanks: c.Blanks, Tags: c.Tags})
+	}
+	if e.Key == "" {
+		e.Key = e.Name
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.unindexExpansion(e.Name)
+	for _, c := range newWhites {
+		c.ID = store.cardID(cah.WhiteCardType, e.Key, c.Text)
+		store.index.addWhite(c)
+	}
+	for _, c := range newBlacks {
+		c.ID = store.cardID(cah.BlackCardType, e.Key, c.Text)
+		store.index.addBlack(c)
+	}
+	store.whiteCards[e.Name] = newWhites
+	store.blackCards[e.Name] = newBlacks
+	store.expansions[e.Name] = &e
 	return nil
 }
 
-func (store *cardMemStore) CreateBlack(t, e string, blanks int) error {
+func checkWhite(t, e string) error {
 	if len(t) == 0 {
 		return errors.New("Card text cannot be empty")
 	}
@@ -53,21 +115,114 @@ func (store *cardMemStore) CreateBlack(t, e string, blanks int) error {
 	if len(e) == 0 {
 		return errors.New("Expansion cannot be empty")
 	}
+	return nil
+}
+
+func checkBlack(t, e string, blanks int) error {
+	if err := checkWhite(t, e); err != nil {
+		return err
+	}
 	if blanks < 1 {
 		return errors.New("Black cards need to have at least 1 blank")
 	}
 	if blanks > 5 {
 		return fmt.Errorf("Black cards blanks maximum is five, but got %d", blanks)
 	}
+	return nil
+}
+
+func (store *cardMemStore) WhitesByID(ids ...int) ([]*cah.WhiteCard, error) {
 	store.Lock()
 	defer store.Unlock()
-	c := &cah.BlackCard{}
-	c.ID = store.nextID()
-	c.Text = t
-	c.Expansion = e
-	c.Blanks = blanks
-	store.blackCards[e] = append(store.blackCards[e], c)
-	return nil
+	ret := []*cah.WhiteCard{}
+	for _, id := range ids {
+		c, ok := store.index.whites[id]
+		if !ok {
+			return ret, fmt.Errorf("No white card found with ID %d", id)
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+func (store *cardMemStore) BlacksByID(ids ...int) ([]*cah.BlackCard, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := []*cah.BlackCard{}
+	for _, id := range ids {
+		c, ok := store.index.blacks[id]
+		if !ok {
+			return ret, fmt.Errorf("No black card found with ID %d", id)
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// SetTags replaces the tags of a white or black card
+func (store *cardMemStore) SetTags(id int, tags []string) error {
+	store.Lock()
+	defer store.Unlock()
+	if c, ok := store.index.whites[id]; ok {
+		edited := *c
+		edited.Tags = tags
+		store.replaceWhite(c, &edited)
+		return nil
+	}
+	if c, ok := store.index.blacks[id]; ok {
+		edited := *c
+		edited.Tags = tags
+		store.replaceBlack(c, &edited)
+		return nil
+	}
+	return fmt.Errorf("No card found with ID %d", id)
+}
+
+// SetText replaces the text of a white or black card, blanks is ignored for white cards.
+// The card keeps its ID.
+func (store *cardMemStore) SetText(id int, text string, blanks int) error {
+	store.Lock()
+	defer store.Unlock()
+	if c, ok := store.index.whites[id]; ok {
+		if err := checkWhite(text, c.Expansion); err != nil {
+			return err
+		}
+		edited := *c
+		edited.Text = text
+		store.replaceWhite(c, &edited)
+		return nil
+	}
+	if c, ok := store.index.blacks[id]; ok {
+		if err := checkBlack(text, c.Expansion, blanks); err != nil {
+			return err
+		}
+		edited := *c
+		edited.Text = text
+		edited.Blanks = blanks
+		store.replaceBlack(c, &edited)
+		return nil
+	}
+	return fmt.Errorf("No card found with ID %d", id)
+}
+
+// SetDisabled disables or enables a white or black card.
+// Disabled cards are not returned by ExpansionWhites and ExpansionBlacks.
+func (store *cardMemStore) SetDisabled(id int, disabled bool) error {
+	store.Lock()
+	defer store.Unlock()
+	if c, ok := store.index.whites[id]; ok {
+		edited := *c
+		edited.Disabled = disabled
+		store.replaceWhite(c, &edited)
+		return nil
+	}
+	if c, ok := store.index.blacks[id]; ok {
+		edited := *c
+		edited.Disabled = disabled
+		store.replaceBlack(c, &edited)
+		return nil
+	}
+	return fmt.Errorf("No card found with ID %d", id)
 }
 
 func (store *cardMemStore) AllWhites() ([]*cah.WhiteCard, error) {
@@ -104,7 +259,11 @@ func (store *cardMemStore) ExpansionWhites(exps ...string) ([]*cah.WhiteCard, er
 			log.Printf("Could not find white cards from expansion %s\n", exp)
 			continue
 		}
-		ret = append(ret, cards...)
+		for _, c := range cards {
+			if !c.Disabled {
+				ret = append(ret, c)
+			}
+		}
 	}
 	return ret, nil
 }
@@ -119,7 +278,11 @@ func (store *cardMemStore) ExpansionBlacks(exps ...string) ([]*cah.BlackCard, er
 			log.Printf("Could not find black cards from expansion %s\n", exp)
 			continue
 		}
-		ret = append(ret, cards...)
+		for _, c := range cards {
+			if !c.Disabled {
+				ret = append(ret, c)
+			}
+		}
 	}
 	return ret, nil
 }
@@ -127,11 +290,160 @@ func (store *cardMemStore) ExpansionBlacks(exps ...string) ([]*cah.BlackCard, er
 func (store *cardMemStore) AvailableExpansions() ([]string, error) {
 	store.Lock()
 	defer store.Unlock()
-	keys := make([]string, len(store.whiteCards))
+	keys := make([]string, len(store.expansions))
 	i := 0
-	for expansion := range store.whiteCards {
+	for expansion := range store.expansions {
 		keys[i] = expansion
 		i++
 	}
 	return keys, nil
 }
+
+func (store *cardMemStore) Expansion(name string) (cah.Expansion, error) {
+	store.Lock()
+	defer store.Unlock()
+	e, ok := store.expansions[name]
+	if !ok {
+		return cah.Expansion{}, fmt.Errorf("No expansion found with name '%s'", name)
+	}
+	return store.withCounts(*e), nil
+}
+
+func (store *cardMemStore) Expansions() ([]cah.Expansion, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := make([]cah.Expansion, 0, len(store.expansions))
+	for _, e := range store.expansions {
+		ret = append(ret, store.withCounts(*e))
+	}
+	return ret, nil
+}
+
+// RenameExpansion changes the name of an expansion and of all its cards, which keep their IDs
+func (store *cardMemStore) RenameExpansion(oldName, newName string) error {
+	if len(newName) == 0 {
+		return errors.New("Expansion cannot be empty")
+	}
+	store.Lock()
+	defer store.Unlock()
+	e, ok := store.expansions[oldName]
+	if !ok {
+		return fmt.Errorf("No expansion found with name '%s'", oldName)
+	}
+	if _, ok := store.expansions[newName]; ok {
+		return fmt.Errorf("An expansion with name '%s' already exists", newName)
+	}
+	// The cards are renamed copies, running games keep the cards they share with the store
+	whites := make([]*cah.WhiteCard, len(store.whiteCards[oldName]))
+	for i, c := range store.whiteCards[oldName] {
+		renamed := *c
+		renamed.Expansion = newName
+		whites[i] = &renamed
+		store.index.whites[renamed.ID] = &renamed
+	}
+	blacks := make([]*cah.BlackCard, len(store.blackCards[oldName]))
+	for i, c := range store.blackCards[oldName] {
+		renamed := *c
+		renamed.Expansion = newName
+		blacks[i] = &renamed
+		store.index.blacks[renamed.ID] = &renamed
+	}
+	store.whiteCards[newName] = whites
+	store.blackCards[newName] = blacks
+	delete(store.whiteCards, oldName)
+	delete(store.blackCards, oldName)
+	renamed := *e
+	renamed.Name = newName
+	store.expansions[newName] = &renamed
+	delete(store.expansions, oldName)
+	return nil
+}
+
+// DeleteExpansion removes an expansion and all its cards.
+// Games that already have those cards in their decks keep them.
+func (store *cardMemStore) DeleteExpansion(name string) error {
+	store.Lock()
+	defer store.Unlock()
+	if _, ok := store.expansions[name]; !ok {
+		return fmt.Errorf("No expansion found with name '%s'", name)
+	}
+	store.unindexExpansion(name)
+	delete(store.whiteCards, name)
+	delete(store.blackCards, name)
+	delete(store.expansions, name)
+	return nil
+}
+
+// withCounts does not count disabled cards, it needs to be called while holding the lock
+func (store *cardMemStore) withCounts(e cah.Expansion) cah.Expansion {
+	e.Whites, e.Blacks = 0, 0
+	e.Picks = map[int]int{}
+	for _, c := range store.whiteCards[e.Name] {
+		if !c.Disabled {
+			e.Whites++
+		}
+	}
+	for _, c := range store.blackCards[e.Name] {
+		if !c.Disabled {
+			e.Blacks++
+			e.Picks[c.Blanks]++
+		}
+	}
+	return e
+}
+
+// cardID derives the ID of a card from its type, expansion key and text, so it does not change
+// when the expansion is reloaded, renamed or the server restarts and it can be stored elsewhere.
+// It needs to be called while holding the lock.
+func (store *cardMemStore) cardID(cardType, expansionKey, text string) int {
+	h := fnv.New64a()
+	h.Write([]byte(cardType + "\x00" + expansionKey + "\x00" + text))
+	// 52 bits so the ID is a safe integer in javascript
+	id := int(h.Sum64() & (1<<52 - 1))
+	for id == 0 || store.index.whites[id] != nil || store.index.blacks[id] != nil {
+		id++
+	}
+	return id
+}
+
+// replaceWhite swaps a stored card for its edited copy. Cards are never changed in place,
+// since the running games share them in their decks, hands and rounds history.
+// It needs to be called while holding the lock.
+func (store *cardMemStore) replaceWhite(old, edited *cah.WhiteCard) {
+	store.index.removeWhite(old)
+	store.index.addWhite(edited)
+	for i, c := range store.whiteCards[old.Expansion] {
+		if c == old {
+			store.whiteCards[old.Expansion][i] = edited
+		}
+	}
+}
+
+// replaceBlack swaps a stored card for its edited copy, like replaceWhite.
+// It needs to be called while holding the lock.
+func (store *cardMemStore) replaceBlack(old, edited *cah.BlackCard) {
+	store.index.removeBlack(old)
+	store.index.addBlack(edited)
+	for i, c := range store.blackCards[old.Expansion] {
+		if c == old {
+			store.blackCards[old.Expansion][i] = edited
+		}
+	}
+}
+
+// unindexExpansion needs to be called while holding the lock
+func (store *cardMemStore) unindexExpansion(name string) {
+	for _, c := range store.whiteCards[name] {
+		store.index.removeWhite(c)
+	}
+	for _, c := range store.blackCards[name] {
+		store.index.removeBlack(c)
+	}
+}
+
+// registerExpansion needs to be called while holding the lock
+func (store *cardMemStore) registerExpansion(name string) {
+	if _, ok := store.expansions[name]; !ok {
+		store.expansions[name] = &cah.Expansion{Name: name, Key: name}
+	}
+}
@@ -0,0 +1,122 @@
+package mem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/j4rv/cah"
+)
+
+func TestCardSearch(t *testing.T) {
+	assert := assert.New(t)
+	store := &cardMemStore{
+		whiteCards: map[string][]*cah.WhiteCard{},
+		blackCards: map[string][]*cah.BlackCard{},
+		expansions: map[string]*cah.Expansion{},
+		index:      newCardIndex(),
+	}
+	store.CreateWhite("A big, angry dog.", "Animals")
+	store.CreateWhite("A tiny cat.", "Animals")
+	store.CreateWhite("Dogecoin.", "Internet")
+	store.CreateBlack("Who let the _ out?", "Animals", 1)
+	store.CreateBlack("Why is the dog angry?", "Internet", 1)
+
+	cases := []struct {
+		name     string
+		search   cah.CardSearch
+		expected []string
+	}{
+		{"word", cah.CardSearch{Query: "cat"}, []string{"A tiny cat."}},
+		{"prefix and case", cah.CardSearch{Query: "DOG"}, []string{"A big, angry dog.", "Why is the dog angry?", "Dogecoin."}},
+		{"all words", cah.CardSearch{Query: "angry dog"}, []string{"A big, angry dog.", "Why is the dog angry?"}},
+		{"type", cah.CardSearch{Query: "angry", Type: cah.BlackCardType}, []string{"Why is the dog angry?"}},
+		{"expansion", cah.CardSearch{Query: "dog", Expansions: []string{"Internet"}}, []string{"Why is the dog angry?", "Dogecoin."}},
+		{"no query", cah.CardSearch{Expansions: []string{"Animals"}, Type: cah.WhiteCardType}, []string{"A big, angry dog.", "A tiny cat."}},
+		{"paging", cah.CardSearch{Query: "dog", Offset: 1, Limit: 1}, []string{"Why is the dog angry?"}},
+		{"not found", cah.CardSearch{Query: "horse"}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := store.Search(tc.search)
+			assert.NoError(err)
+			texts := []string{}
+			for _, c := range res.Cards {
+				texts = append(texts, c.Text)
+			}
+			assert.Equal(tc.expected, texts)
+		})
+	}
+
+	cat, _ := store.Search(cah.CardSearch{Query: "cat"})
+	store.SetDisabled(cat.Cards[0].ID, true)
+	res, _ := store.Search(cah.CardSearch{Query: "cat"})
+	assert.Equal(0, res.Total, "Disabled cards should not be found")
+	res, _ = store.Search(cah.CardSearch{Query: "cat", IncludeDisabled: true})
+	assert.Equal(1, res.Total, "Moderators should find the disabled cards")
+	assert.True(res.Cards[0].Disabled)
+
+	store.DeleteExpansion("Internet")
+	res, _ = store.Search(cah.CardSearch{Query: "dog"})
+	assert.Equal(1, res.Total, "Deleted cards should not be found")
+}
+
+func TestCardEditsDoNotChangeGames(t *testing.T) {
+	assert := assert.New(t)
+	store := &cardMemStore{
+		whiteCards: map[string][]*cah.WhiteCard{},
+		blackCards: map[string][]*cah.BlackCard{},
+		expansions: map[string]*cah.Expansion{},
+		index:      newCardIndex(),
+	}
+	store.CreateWhite("Old white.", "Edited")
+	store.CreateBlack("Old black _.", "Edited", 1)
+	// The cards in the decks of a running game
+	whites, _ := store.ExpansionWhites("Edited")
+	blacks, _ := store.ExpansionBlacks("Edited")
+	white, black := whites[0], blacks[0]
+
+	assert.NoError(store.SetText(white.ID, "New white.", 0))
+	assert.NoError(store.SetText(black.ID, "New _ and _.", 2))
+	assert.NoError(store.SetTags(white.ID, []string{cah.NSFWTag}))
+	assert.NoError(store.SetDisabled(black.ID, true))
+
+	assert.Equal(cah.WhiteCard{ID: white.ID, Text: "Old white.", Expansion: "Edited"}, *white)
+	assert.Equal(cah.BlackCard{ID: black.ID, Text: "Old black _.", Expansion: "Edited", Blanks: 1}, *black)
+
+	edited, err := store.WhitesByID(white.ID)
+	assert.NoError(err)
+	assert.Equal(cah.WhiteCard{ID: white.ID, Text: "New white.", Expansion: "Edited", Tags: []string{cah.NSFWTag}}, *edited[0])
+	editedBlack, err := store.BlacksByID(black.ID)
+	assert.NoError(err)
+	assert.Equal(cah.BlackCard{ID: black.ID, Text: "New _ and _.", Expansion: "Edited", Blanks: 2, Disabled: true}, *editedBlack[0])
+	all, _ := store.AllWhites()
+	assert.Equal(edited[0], all[0], "The expansion should hold the edited card")
+}
+
+func TestCardRenameDoesNotChangeGames(t *testing.T) {
+	assert := assert.New(t)
+	store := &cardMemStore{
+		whiteCards: map[string][]*cah.WhiteCard{},
+		blackCards: map[string][]*cah.BlackCard{},
+		expansions: map[string]*cah.Expansion{},
+		index:      newCardIndex(),
+	}
+	store.CreateWhite("White.", "Before")
+	store.CreateBlack("Black _.", "Before", 1)
+	// The cards in the decks of a running game
+	white := store.whiteCards["Before"][0]
+	black := store.blackCards["Before"][0]
+
+	assert.NoError(store.RenameExpansion("Before", "After"))
+	assert.Equal("Before", white.Expansion, "Running games should keep the card they have")
+	assert.Equal("Before", black.Expansion)
+	renamed, err := store.WhitesByID(white.ID)
+	assert.NoError(err)
+	assert.Equal("After", renamed[0].Expansion)
+	renamedBlacks, _ := store.ExpansionBlacks("After")
+	assert.Equal(black.ID, renamedBlacks[0].ID)
+	assert.Equal("After", renamedBlacks[0].Expansion)
+	res, _ := store.Search(cah.CardSearch{Query: "white"})
+	assert.Equal("After", res.Cards[0].Expansion)
+}
@@ -0,0 +1,153 @@
+package mem
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/j4rv/cah"
+)
+
+const defaultSearchLimit = 20
+const maxSearchLimit = 100
+
+// cardIndex is an inverted index from the words in the cards text to the cards IDs.
+// It is not safe for concurrent use, the card store lock protects it.
+type cardIndex struct {
+	words  map[string]map[int]bool
+	whites map[int]*cah.WhiteCard
+	blacks map[int]*cah.BlackCard
+}
+
+func newCardIndex() cardIndex {
+	return cardIndex{
+		words:  map[string]map[int]bool{},
+		whites: map[int]*cah.WhiteCard{},
+		blacks: map[int]*cah.BlackCard{},
+	}
+}
+
+func (idx cardIndex) addWhite(c *cah.WhiteCard) {
+	idx.whites[c.ID] = c
+	idx.addWords(c.ID, c.Text)
+}
+
+func (idx cardIndex) addBlack(c *cah.BlackCard) {
+	idx.blacks[c.ID] = c
+	idx.addWords(c.ID, c.Text)
+}
+
+func (idx cardIndex) removeWhite(c *cah.WhiteCard) {
+	delete(idx.whites, c.ID)
+	idx.removeWords(c.ID, c.Text)
+}
+
+func (idx cardIndex) removeBlack(c *cah.BlackCard) {
+	delete(idx.blacks, c.ID)
+	idx.removeWords(c.ID, c.Text)
+}
+
+func (idx cardIndex) addWords(id int, text string) {
+	for _, w := range searchWords(text) {
+		if idx.words[w] == nil {
+			idx.words[w] = map[int]bool{}
+		}
+		idx.words[w][id] = true
+	}
+}
+
+func (idx cardIndex) removeWords(id int, text string) {
+	for _, w := range searchWords(text) {
+		delete(idx.words[w], id)
+		if len(idx.words[w]) == 0 {
+			delete(idx.words, w)
+		}
+	}
+}
+
+// matching returns the IDs of the cards that have a word starting with every query word.
+// A nil result means that the query had no words, so every card matches.
+func (idx cardIndex) matching(query string) map[int]bool {
+	var ret map[int]bool
+	for _, qw := range searchWords(query) {
+		found := map[int]bool{}
+		for w, ids := range idx.words {
+			if !strings.HasPrefix(w, qw) {
+				continue
+			}
+			for id := range ids {
+				if ret == nil || ret[id] {
+					found[id] = true
+				}
+			}
+		}
+		ret = found
+	}
+	return ret
+}
+
+func (store *cardMemStore) Search(q cah.CardSearch) (cah.CardSearchResult, error) {
+	store.Lock()
+	defer store.Unlock()
+	matching := store.index.matching(q.Query)
+	inExpansion := func(e string) bool {
+		if len(q.Expansions) == 0 {
+			return true
+		}
+		for _, qe := range q.Expansions {
+			if qe == e {
+				return true
+			}
+		}
+		return false
+	}
+	found := []*cah.SearchCard{}
+	if q.Type == "" || q.Type == cah.WhiteCardType {
+		for id, c := range store.index.whites {
+			if (matching == nil || matching[id]) && inExpansion(c.Expansion) && (!c.Disabled || q.IncludeDisabled) {
+				found = append(found, &cah.SearchCard{ID: c.ID, Type: cah.WhiteCardType, Text: c.Text, Expansion: c.Expansion, Disabled: c.Disabled})
+			}
+		}
+	}
+	if q.Type == "" || q.Type == cah.BlackCardType {
+		for id, c := range store.index.blacks {
+			if (matching == nil || matching[id]) && inExpansion(c.Expansion) && (!c.Disabled || q.IncludeDisabled) {
+				found = append(found, &cah.SearchCard{ID: c.ID, Type: cah.BlackCardType, Text: c.Text, Expansion: c.Expansion, Blanks: c.Blanks, Disabled: c.Disabled})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Expansion != found[j].Expansion {
+			return found[i].Expansion < found[j].Expansion
+		}
+		if found[i].Type != found[j].Type {
+			return found[i].Type < found[j].Type
+		}
+		return found[i].Text < found[j].Text
+	})
+	return cah.CardSearchResult{Total: len(found), Cards: page(found, q.Offset, q.Limit)}, nil
+}
+
+func page(cards []*cah.SearchCard, offset, limit int) []*cah.SearchCard {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	if offset < 0 || offset >= len(cards) {
+		return []*cah.SearchCard{}
+	}
+	end := offset + limit
+	if end > len(cards) {
+		end = len(cards)
+	}
+	return cards[offset:end]
+}
+
+// searchWords splits a text in lower case words, ignoring punctuation
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
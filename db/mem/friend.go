@@ -0,0 +1,74 @@
+package mem
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type friendMemStore struct {
+	abstractMemStore
+	// relations holds the relation of each user with another, keyed by both IDs
+	relations map[[2]int]cah.Friendship
+}
+
+// NewFriendStore returns an empty store, each call keeps its own relations
+func NewFriendStore() *friendMemStore {
+	return &friendMemStore{relations: map[[2]int]cah.Friendship{}}
+}
+
+func (store *friendMemStore) Set(from, to int, status cah.FriendStatus, at time.Time) error {
+	store.Lock()
+	defer store.Unlock()
+	store.relations[[2]int{from, to}] = cah.Friendship{UserID: from, FriendID: to, Status: status, Since: at}
+	return nil
+}
+
+func (store *friendMemStore) Delete(from, to int) error {
+	store.Lock()
+	defer store.Unlock()
+	delete(store.relations, [2]int{from, to})
+	return nil
+}
+
+func (store *friendMemStore) Get(from, to int) (cah.Friendship, error) {
+	store.Lock()
+	defer store.Unlock()
+	r, ok := store.relations[[2]int{from, to}]
+	if !ok {
+		return r, fmt.Errorf("No relation found from the user with id %d to the user with id %d", from, to)
+	}
+	return r, nil
+}
+
+func (store *friendMemStore) From(userID int) ([]cah.Friendship, error) {
+	return store.filter(func(r cah.Friendship) bool { return r.UserID == userID }), nil
+}
+
+func (store *friendMemStore) To(userID int) ([]cah.Friendship, error) {
+	return store.filter(func(r cah.Friendship) bool { return r.FriendID == userID }), nil
+}
+
+// filter returns the relations that match, the oldest first
+func (store *friendMemStore) filter(match func(cah.Friendship) bool) []cah.Friendship {
+	store.Lock()
+	defer store.Unlock()
+	ret := []cah.Friendship{}
+	for _, r := range store.relations {
+		if match(r) {
+			ret = append(ret, r)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Since.Equal(ret[j].Since) {
+			return ret[i].Since.Before(ret[j].Since)
+		}
+		if ret[i].UserID != ret[j].UserID {
+			return ret[i].UserID < ret[j].UserID
+		}
+		return ret[i].FriendID < ret[j].FriendID
+	})
+	return ret
+}
@@ -62,3 +62,13 @@ func (store *gameMemStore) Update(g cah.Game) error {
 	store.games[g.ID] = g
 	return nil
 }
+
+func (store *gameMemStore) Delete(id int) error {
+	store.Lock()
+	defer store.Unlock()
+	if _, ok := store.games[id]; !ok {
+		return fmt.Errorf("No game found with id %d", id)
+	}
+	delete(store.games, id)
+	return nil
+}
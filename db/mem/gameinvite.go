@@ -0,0 +1,102 @@
+package mem
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/j4rv/cah"
+)
+
+type gameInviteMemStore struct {
+	abstractMemStore
+	invites map[int]*cah.GameInvite
+}
+
+var gameInviteStore = &gameInviteMemStore{
+	invites: map[int]*cah.GameInvite{},
+}
+
+func GetGameInviteStore() *gameInviteMemStore {
+	return gameInviteStore
+}
+
+func (store *gameInviteMemStore) Create(inv cah.GameInvite) (cah.GameInvite, error) {
+	store.Lock()
+	defer store.Unlock()
+	if inv.ID != 0 {
+		return inv, errors.New("Tried to create an invite but its ID was not zero")
+	}
+	inv.ID = store.nextID()
+	store.invites[inv.ID] = &inv
+	return inv, nil
+}
+
+func (store *gameInviteMemStore) ByID(id int) (cah.GameInvite, error) {
+	store.Lock()
+	defer store.Unlock()
+	inv, ok := store.invites[id]
+	if !ok {
+		return cah.GameInvite{}, fmt.Errorf("No invite found with id %d", id)
+	}
+	return *inv, nil
+}
+
+func (store *gameInviteMemStore) ByGame(gameID int) []cah.GameInvite {
+	store.Lock()
+	defer store.Unlock()
+	ret := []cah.GameInvite{}
+	for _, inv := range store.invites {
+		if inv.GameID == gameID {
+			ret = append(ret, *inv)
+		}
+	}
+	return ret
+}
+
+func (store *gameInviteMemStore) Use(id int) (cah.GameInvite, error) {
+	store.Lock()
+	defer store.Unlock()
+	inv, ok := store.invites[id]
+	if !ok {
+		return cah.GameInvite{}, fmt.Errorf("No invite found with id %d", id)
+	}
+	if inv.MaxUses != 0 && inv.Uses >= inv.MaxUses {
+		return *inv, errors.New("The invite has no uses left")
+	}
+	inv.Uses++
+	return *inv, nil
+}
+
+func (store *gameInviteMemStore) Unuse(id int) error {
+	store.Lock()
+	defer store.Unlock()
+	inv, ok := store.invites[id]
+	if !ok {
+		return fmt.Errorf("No invite found with id %d", id)
+	}
+	if inv.Uses > 0 {
+		inv.Uses--
+	}
+	return nil
+}
+
+func (store *gameInviteMemStore) Revoke(id int) error {
+	store.Lock()
+	defer store.Unlock()
+	inv, ok := store.invites[id]
+	if !ok {
+		return fmt.Errorf("No invite found with id %d", id)
+	}
+	inv.Revoked = true
+	return nil
+}
+
+func (store *gameInviteMemStore) Delete(id int) error {
+	store.Lock()
+	defer store.Unlock()
+	if _, ok := store.invites[id]; !ok {
+		return fmt.Errorf("No invite found with id %d", id)
+	}
+	delete(store.invites, id)
+	return nil
+}
@@ -0,0 +1,119 @@
+package mem
+
+import (
+	"sort"
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type moderationMemStore struct {
+	abstractMemStore
+	tags     map[int][]string
+	texts    map[int]string
+	disabled map[int]bool
+	reports  []cah.CardReport
+}
+
+// NewModerationStore returns an empty store, each call keeps its own moderation
+func NewModerationStore() *moderationMemStore {
+	return &moderationMemStore{
+		tags:     map[int][]string{},
+		texts:    map[int]string{},
+		disabled: map[int]bool{},
+	}
+}
+
+func (store *moderationMemStore) SetTags(cardID int, tags []string) error {
+	store.Lock()
+	defer store.Unlock()
+	store.tags[cardID] = append([]string{}, tags...)
+	return nil
+}
+
+func (store *moderationMemStore) Tags() (map[int][]string, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := make(map[int][]string, len(store.tags))
+	for id, tags := range store.tags {
+		ret[id] = append([]string{}, tags...)
+	}
+	return ret, nil
+}
+
+func (store *moderationMemStore) SetText(cardID int, text string) error {
+	store.Lock()
+	defer store.Unlock()
+	store.texts[cardID] = text
+	return nil
+}
+
+func (store *moderationMemStore) Texts() (map[int]string, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := make(map[int]string, len(store.texts))
+	for id, text := range store.texts {
+		ret[id] = text
+	}
+	return ret, nil
+}
+
+func (store *moderationMemStore) SetDisabled(cardID int, disabled bool) error {
+	store.Lock()
+	defer store.Unlock()
+	store.disabled[cardID] = disabled
+	return nil
+}
+
+func (store *moderationMemStore) Disabled() ([]int, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := []int{}
+	for id, disabled := range store.disabled {
+		if disabled {
+			ret = append(ret, id)
+		}
+	}
+	sort.Ints(ret)
+	return ret, nil
+}
+
+// Report replaces the previous report of the user for the card
+func (store *moderationMemStore) Report(userID, cardID int, reason string) error {
+	store.Lock()
+	defer store.Unlock()
+	pending := []cah.CardReport{}
+	for _, r := range store.reports {
+		if r.CardID != cardID || r.UserID != userID {
+			pending = append(pending, r)
+		}
+	}
+	store.reports = append(pending, cah.CardReport{
+		ID:        store.nextID(),
+		CardID:    cardID,
+		UserID:    userID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+// Reports returns the reports not resolved yet, oldest first
+func (store *moderationMemStore) Reports() ([]cah.CardReport, error) {
+	store.Lock()
+	defer store.Unlock()
+	return append([]cah.CardReport{}, store.reports...), nil
+}
+
+func (store *moderationMemStore) ResolveReports(cardID int) error {
+	store.Lock()
+	defer store.Unlock()
+	pending := []cah.CardReport{}
+	for _, r := range store.reports {
+		if r.CardID != cardID {
+			pending = append(pending, r)
+		}
+	}
+	store.reports = pending
+	return nil
+}
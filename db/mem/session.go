@@ -0,0 +1,101 @@
+package mem
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type sessionMemStore struct {
+	abstractMemStore
+	sessions map[int]cah.Session
+}
+
+// NewSessionStore returns an empty store, each call keeps its own sessions
+func NewSessionStore() *sessionMemStore {
+	return &sessionMemStore{sessions: map[int]cah.Session{}}
+}
+
+func (store *sessionMemStore) Create(s cah.Session) (cah.Session, error) {
+	store.Lock()
+	defer store.Unlock()
+	s.ID = store.nextID()
+	store.sessions[s.ID] = s
+	return s, nil
+}
+
+func (store *sessionMemStore) ByToken(tokenHash string) (cah.Session, error) {
+	store.Lock()
+	defer store.Unlock()
+	for _, s := range store.sessions {
+		if s.TokenHash == tokenHash {
+			return s, nil
+		}
+	}
+	return cah.Session{}, errors.New("No session found with that token")
+}
+
+// ByUser returns the sessions of the user, the most recently used first
+func (store *sessionMemStore) ByUser(userID int) ([]cah.Session, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := []cah.Session{}
+	for _, s := range store.sessions {
+		if s.UserID == userID {
+			ret = append(ret, s)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].LastSeenAt.Equal(ret[j].LastSeenAt) {
+			return ret[i].LastSeenAt.After(ret[j].LastSeenAt)
+		}
+		return ret[i].ID > ret[j].ID
+	})
+	return ret, nil
+}
+
+func (store *sessionMemStore) Touch(id int, lastSeen, expiresAt time.Time) error {
+	store.Lock()
+	defer store.Unlock()
+	s, ok := store.sessions[id]
+	if !ok {
+		return nil
+	}
+	s.LastSeenAt = lastSeen
+	s.ExpiresAt = expiresAt
+	store.sessions[id] = s
+	return nil
+}
+
+func (store *sessionMemStore) Delete(ids ...int) error {
+	store.Lock()
+	defer store.Unlock()
+	for _, id := range ids {
+		delete(store.sessions, id)
+	}
+	return nil
+}
+
+func (store *sessionMemStore) DeleteByUser(userID int) error {
+	store.Lock()
+	defer store.Unlock()
+	for id, s := range store.sessions {
+		if s.UserID == userID {
+			delete(store.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (store *sessionMemStore) DeleteExpired(now time.Time) error {
+	store.Lock()
+	defer store.Unlock()
+	for id, s := range store.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(store.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package mem
+
+import (
+	"sort"
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type statsMemStore struct {
+	abstractMemStore
+	cards map[int]cah.CardStats
+	// seen holds when each user saw each card
+	seen    map[int]map[int]time.Time
+	results []cah.GameResult
+	ratings map[int]float64
+	// wins counts the rounds each card won for each user
+	wins map[int]map[int]int
+}
+
+// NewStatsStore returns an empty store, each call keeps its own stats.
+// The leaderboards get the usernames from the user store of this package.
+func NewStatsStore() *statsMemStore {
+	return &statsMemStore{
+		cards:   map[int]cah.CardStats{},
+		seen:    map[int]map[int]time.Time{},
+		ratings: map[int]float64{},
+		wins:    map[int]map[int]int{},
+	}
+}
+
+func (store *statsMemStore) AddDealt(cardIDs ...int) error {
+	return store.addCardStat(cardIDs, func(s *cah.CardStats) { s.Dealt++ })
+}
+
+func (store *statsMemStore) AddPlayed(cardIDs ...int) error {
+	return store.addCardStat(cardIDs, func(s *cah.CardStats) { s.Played++ })
+}
+
+func (store *statsMemStore) AddWon(cardIDs ...int) error {
+	return store.addCardStat(cardIDs, func(s *cah.CardStats) { s.Won++ })
+}
+
+// CardStats returns the stats of the cards, cards without stats are not in the map
+func (store *statsMemStore) CardStats(cardIDs ...int) (map[int]cah.CardStats, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := map[int]cah.CardStats{}
+	for _, id := range cardIDs {
+		if s, ok := store.cards[id]; ok {
+			ret[id] = s
+		}
+	}
+	return ret, nil
+}
+
+func (store *statsMemStore) AddSeen(userID int, cardIDs ...int) error {
+	store.Lock()
+	defer store.Unlock()
+	if store.seen[userID] == nil {
+		store.seen[userID] = map[int]time.Time{}
+	}
+	now := time.Now()
+	for _, id := range cardIDs {
+		store.seen[userID][id] = now
+	}
+	return nil
+}
+
+// Seen returns the cards seen by any of the users since that time
+func (store *statsMemStore) Seen(since time.Time, userIDs ...int) ([]int, error) {
+	store.Lock()
+	defer store.Unlock()
+	found := map[int]bool{}
+	for _, userID := range userIDs {
+		for id, at := range store.seen[userID] {
+			if !at.Before(since) {
+				found[id] = true
+			}
+		}
+	}
+	ret := []int{}
+	for id := range found {
+		ret = append(ret, id)
+	}
+	sort.Ints(ret)
+	return ret, nil
+}
+
+func (store *statsMemStore) AddGameResults(results ...cah.GameResult) error {
+	store.Lock()
+	defer store.Unlock()
+	for _, r := range results {
+		store.results = append(store.results, r)
+		if _, ok := store.ratings[r.UserID]; ok {
+			store.ratings[r.UserID] += r.RatingChange
+		} else {
+			store.ratings[r.UserID] = r.Rating
+		}
+		if store.wins[r.UserID] == nil {
+			store.wins[r.UserID] = map[int]int{}
+		}
+		for _, card := range r.WinningCards {
+			store.wins[r.UserID][card]++
+		}
+	}
+	return nil
+}
+
+// UserStats adds up the game results of the user, with up to favourites favourite cards
+func (store *statsMemStore) UserStats(userID int, favourites int) (cah.UserStats, error) {
+	store.Lock()
+	defer store.Unlock()
+	res := cah.UserStats{UserID: userID, FavouriteCards: []cah.FavouriteCard{}}
+	for _, r := range store.results {
+		if r.UserID != userID {
+			continue
+		}
+		res.GamesPlayed++
+		if r.Won {
+			res.GamesWon++
+		}
+		res.RoundsWon += r.RoundsWon
+		res.CardsPlayed += r.CardsPlayed
+	}
+	for card, wins := range store.wins[userID] {
+		res.FavouriteCards = append(res.FavouriteCards, cah.FavouriteCard{CardID: card, Wins: wins})
+	}
+	sort.Slice(res.FavouriteCards, func(i, j int) bool {
+		a, b := res.FavouriteCards[i], res.FavouriteCards[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.CardID < b.CardID
+	})
+	if len(res.FavouriteCards) > favourites {
+		res.FavouriteCards = res.FavouriteCards[:favourites]
+	}
+	return res, nil
+}
+
+// Ratings returns the ratings of the users, users without a rating are not in the map
+func (store *statsMemStore) Ratings(userIDs ...int) (map[int]float64, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := map[int]float64{}
+	for _, id := range userIDs {
+		if r, ok := store.ratings[id]; ok {
+			ret[id] = r
+		}
+	}
+	return ret, nil
+}
+
+// Leaderboard ranks the users with a rating and games finished since the query time
+func (store *statsMemStore) Leaderboard(q cah.LeaderboardQuery) (cah.Leaderboard, error) {
+	store.Lock()
+	defer store.Unlock()
+	allowed := map[int]bool{}
+	for _, id := range q.UserIDs {
+		allowed[id] = true
+	}
+	entries := map[int]*cah.LeaderboardEntry{}
+	for _, r := range store.results {
+		rating, ok := store.ratings[r.UserID]
+		if !ok || r.FinishedAt.Before(q.Since) || (len(allowed) != 0 && !allowed[r.UserID]) {
+			continue
+		}
+		e, ok := entries[r.UserID]
+		if !ok {
+			u, err := userStore.ByID(r.UserID)
+			if err != nil {
+				continue
+			}
+			e = &cah.LeaderboardEntry{UserID: r.UserID, Username: u.Username, Rating: rating}
+			entries[r.UserID] = e
+		}
+		e.GamesPlayed++
+		if r.Won {
+			e.GamesWon++
+		}
+		e.RatingChange += r.RatingChange
+	}
+	ranked := make([]cah.LeaderboardEntry, 0, len(entries))
+	for _, e := range entries {
+		ranked = append(ranked, *e)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if !q.Since.IsZero() && a.RatingChange != b.RatingChange {
+			return a.RatingChange > b.RatingChange
+		}
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		return a.UserID < b.UserID
+	})
+	res := cah.Leaderboard{Total: len(ranked), Entries: []cah.LeaderboardEntry{}}
+	for i := q.Offset; i < len(ranked) && i < q.Offset+q.Limit; i++ {
+		ranked[i].Rank = i + 1
+		res.Entries = append(res.Entries, ranked[i])
+	}
+	return res, nil
+}
+
+// addCardStat applies the change to each card, a card repeated in cardIDs is changed once per repetition
+func (store *statsMemStore) addCardStat(cardIDs []int, change func(*cah.CardStats)) error {
+	store.Lock()
+	defer store.Unlock()
+	for _, id := range cardIDs {
+		s := store.cards[id]
+		s.CardID = id
+		change(&s)
+		store.cards[id] = s
+	}
+	return nil
+}
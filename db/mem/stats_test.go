@@ -0,0 +1,48 @@
+package mem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderboard(t *testing.T) {
+	assert := assert.New(t)
+	store := NewStatsStore()
+	now := time.Now()
+	assert.NoError(store.AddGameResults(
+		cah.GameResult{UserID: 1, Won: true, Rating: 1520, RatingChange: 20, FinishedAt: now.Add(-48 * time.Hour)},
+		cah.GameResult{UserID: 2, Rating: 1490, RatingChange: -10, FinishedAt: now.Add(-48 * time.Hour)},
+	))
+	assert.NoError(store.AddGameResults(
+		cah.GameResult{UserID: 1, Rating: 1510, RatingChange: -10, FinishedAt: now},
+		cah.GameResult{UserID: 2, Won: true, Rating: 1500, RatingChange: 10, FinishedAt: now},
+	))
+
+	all, err := store.Leaderboard(cah.LeaderboardQuery{Limit: 10})
+	assert.NoError(err)
+	assert.Equal(2, all.Total)
+	assert.Equal(1, all.Entries[0].UserID, "All-time leaderboards should be sorted by rating")
+	assert.Equal("Red", all.Entries[0].Username)
+	assert.Equal(2, all.Entries[0].GamesPlayed)
+	assert.Equal(1, all.Entries[0].GamesWon)
+
+	recent, err := store.Leaderboard(cah.LeaderboardQuery{Since: now.Add(-time.Hour), Limit: 10})
+	assert.NoError(err)
+	assert.Equal(2, recent.Entries[0].UserID, "Leaderboards with a window should be sorted by the rating won during it")
+	assert.Equal(10.0, recent.Entries[0].RatingChange)
+	assert.Equal(1, recent.Entries[0].GamesPlayed)
+
+	page, err := store.Leaderboard(cah.LeaderboardQuery{Offset: 1, Limit: 10})
+	assert.NoError(err)
+	assert.Equal(2, page.Total)
+	assert.Len(page.Entries, 1)
+	assert.Equal(2, page.Entries[0].Rank)
+
+	only, err := store.Leaderboard(cah.LeaderboardQuery{UserIDs: []int{2}, Limit: 10})
+	assert.NoError(err)
+	assert.Equal(1, only.Total)
+	assert.Equal(2, only.Entries[0].UserID)
+}
@@ -0,0 +1,72 @@
+package mem
+
+import (
+	"fmt"
+
+	"github.com/j4rv/cah"
+)
+
+type twoFactorMemStore struct {
+	abstractMemStore
+	configs map[int]cah.TwoFactor
+	// codes holds the hashed recovery codes of each user
+	codes map[int]map[string]bool
+}
+
+// NewTwoFactorStore returns an empty store, each call keeps its own secrets
+func NewTwoFactorStore() *twoFactorMemStore {
+	return &twoFactorMemStore{configs: map[int]cah.TwoFactor{}, codes: map[int]map[string]bool{}}
+}
+
+func (store *twoFactorMemStore) TwoFactor(userID int) (cah.TwoFactor, error) {
+	store.Lock()
+	defer store.Unlock()
+	tf, ok := store.configs[userID]
+	if !ok {
+		return tf, fmt.Errorf("No two-factor authentication found for the user with id %d", userID)
+	}
+	return tf, nil
+}
+
+func (store *twoFactorMemStore) SetTwoFactor(tf cah.TwoFactor) error {
+	store.Lock()
+	defer store.Unlock()
+	store.configs[tf.UserID] = tf
+	return nil
+}
+
+// DeleteTwoFactor removes the secret and the recovery codes of the user
+func (store *twoFactorMemStore) DeleteTwoFactor(userID int) error {
+	store.Lock()
+	defer store.Unlock()
+	delete(store.configs, userID)
+	delete(store.codes, userID)
+	return nil
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user
+func (store *twoFactorMemStore) SetRecoveryCodes(userID int, hashes ...string) error {
+	store.Lock()
+	defer store.Unlock()
+	store.codes[userID] = map[string]bool{}
+	for _, h := range hashes {
+		store.codes[userID][h] = true
+	}
+	return nil
+}
+
+func (store *twoFactorMemStore) UseRecoveryCode(userID int, hash string) (bool, error) {
+	store.Lock()
+	defer store.Unlock()
+	if !store.codes[userID][hash] {
+		return false, nil
+	}
+	delete(store.codes[userID], hash)
+	return true, nil
+}
+
+func (store *twoFactorMemStore) RecoveryCodes(userID int) (int, error) {
+	store.Lock()
+	defer store.Unlock()
+	return len(store.codes[userID]), nil
+}
@@ -2,6 +2,8 @@ package mem
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/j4rv/cah"
@@ -10,10 +12,16 @@ import (
 type userMemStore struct {
 	abstractMemStore
 	users map[int]*cah.User
+	// identities holds the user ID of each issuer and subject
+	identities map[[2]string]int
+	// seen holds the last time each guest was seen
+	seen map[int]time.Time
 }
 
 var userStore = &userMemStore{
-	users: make(map[int]*cah.User),
+	users:      make(map[int]*cah.User),
+	identities: make(map[[2]string]int),
+	seen:       make(map[int]time.Time),
 }
 
 func GetUserStore() *userMemStore {
@@ -26,6 +34,7 @@ func (store *userMemStore) Create(username, password string) (cah.User, error) {
 	user := cah.User{}
 	user.Username = username
 	user.Password = password
+	user.Role = cah.RoleUser
 	user.CreatedAt = time.Now()
 	user.ID = store.nextID()
 	store.users[user.ID] = &user
@@ -52,3 +61,175 @@ func (store *userMemStore) ByName(name string) (cah.User, error) {
 	}
 	return cah.User{}, errors.New("User not found")
 }
+
+func (store *userMemStore) SetPassword(userID int, password string) error {
+	store.Lock()
+	defer store.Unlock()
+	u, ok := store.users[userID]
+	if !ok {
+		return errors.New("User not found")
+	}
+	u.Password = password
+	return nil
+}
+
+func (store *userMemStore) SetUsername(userID int, username string) error {
+	store.Lock()
+	defer store.Unlock()
+	u, ok := store.users[userID]
+	if !ok {
+		return errors.New("User not found")
+	}
+	for _, other := range store.users {
+		if other.ID != userID && other.Username == username {
+			return errors.New("Username already exists")
+		}
+	}
+	u.Username = username
+	return nil
+}
+
+func (store *userMemStore) Delete(userID int) error {
+	store.Lock()
+	defer store.Unlock()
+	if _, ok := store.users[userID]; !ok {
+		return errors.New("User not found")
+	}
+	delete(store.users, userID)
+	delete(store.seen, userID)
+	for id, linked := range store.identities {
+		if linked == userID {
+			delete(store.identities, id)
+		}
+	}
+	return nil
+}
+
+func (store *userMemStore) CreateGuest(username, password string) (cah.User, error) {
+	u, err := store.Create(username, password)
+	if err != nil {
+		return u, err
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.users[u.ID].Guest = true
+	u.Guest = true
+	return u, nil
+}
+
+func (store *userMemStore) Upgrade(userID int, username, password string) error {
+	store.Lock()
+	defer store.Unlock()
+	u, ok := store.users[userID]
+	if !ok || !u.Guest {
+		return errors.New("Guest not found")
+	}
+	u.Username = username
+	u.Password = password
+	u.Guest = false
+	return nil
+}
+
+func (store *userMemStore) GuestSeen(userID int, at time.Time) error {
+	store.Lock()
+	defer store.Unlock()
+	store.seen[userID] = at
+	return nil
+}
+
+func (store *userMemStore) Guests(seenBefore time.Time) ([]cah.User, error) {
+	store.Lock()
+	defer store.Unlock()
+	ret := []cah.User{}
+	for _, u := range store.users {
+		if u.Guest && u.CreatedAt.Before(seenBefore) && store.seen[u.ID].Before(seenBefore) {
+			ret = append(ret, *u)
+		}
+	}
+	return ret, nil
+}
+
+func (store *userMemStore) SetRole(userID int, role cah.Role) error {
+	store.Lock()
+	defer store.Unlock()
+	u, ok := store.users[userID]
+	if !ok {
+		return errors.New("User not found")
+	}
+	u.Role = role
+	return nil
+}
+
+func (store *userMemStore) SetBanned(userID int, banned bool) error {
+	store.Lock()
+	defer store.Unlock()
+	u, ok := store.users[userID]
+	if !ok {
+		return errors.New("User not found")
+	}
+	u.Banned = banned
+	return nil
+}
+
+func (store *userMemStore) List(q cah.UserQuery) (cah.UserList, error) {
+	store.Lock()
+	defer store.Unlock()
+	found := []cah.User{}
+	query := strings.ToLower(q.Query)
+	for _, u := range store.users {
+		if strings.Contains(strings.ToLower(u.Username), query) {
+			found = append(found, *u)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Username < found[j].Username
+	})
+	res := cah.UserList{Total: len(found), Users: []cah.User{}}
+	if q.Offset < len(found) {
+		end := q.Offset + q.Limit
+		if end > len(found) {
+			end = len(found)
+		}
+		res.Users = found[q.Offset:end]
+	}
+	return res, nil
+}
+
+func (store *userMemStore) SetLocale(userID int, locale string) error {
+	store.Lock()
+	defer store.Unlock()
+	u, ok := store.users[userID]
+	if !ok {
+		return errors.New("User not found")
+	}
+	u.Locale = locale
+	return nil
+}
+
+func (store *userMemStore) LinkIdentity(userID int, issuer, subject string) error {
+	store.Lock()
+	defer store.Unlock()
+	if _, ok := store.users[userID]; !ok {
+		return errors.New("User not found")
+	}
+	id := [2]string{issuer, subject}
+	if _, ok := store.identities[id]; ok {
+		return errors.New("Identity already linked")
+	}
+	store.identities[id] = userID
+	return nil
+}
+
+func (store *userMemStore) ByIdentity(issuer, subject string) (cah.User, error) {
+	store.Lock()
+	defer store.Unlock()
+	userID, ok := store.identities[[2]string{issuer, subject}]
+	if !ok {
+		return cah.User{}, errors.New("User not found")
+	}
+	u, ok := store.users[userID]
+	if !ok {
+		return cah.User{}, errors.New("User not found")
+	}
+	return *u, nil
+}
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type apiTokenStore struct{}
+
+func NewAPITokenStore() *apiTokenStore {
+	return &apiTokenStore{}
+}
+
+// apiTokenRow is an API token as stored, with the times as unix timestamps.
+// Tokens that were never used have a zero last_used_at.
+type apiTokenRow struct {
+	ID         int    `db:"api_token"`
+	UserID     int    `db:"user"`
+	Name       string `db:"name"`
+	TokenHash  string `db:"token"`
+	CreatedAt  int64  `db:"created_at"`
+	LastUsedAt int64  `db:"last_used_at"`
+}
+
+func (r apiTokenRow) apiToken() cah.APIToken {
+	t := cah.APIToken{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		Name:      r.Name,
+		TokenHash: r.TokenHash,
+		CreatedAt: time.Unix(r.CreatedAt, 0),
+	}
+	if r.LastUsedAt != 0 {
+		t.LastUsedAt = time.Unix(r.LastUsedAt, 0)
+	}
+	return t
+}
+
+func (store *apiTokenStore) Create(t cah.APIToken) (cah.APIToken, error) {
+	res, err := db.Exec(`INSERT INTO api_token (user, name, token, created_at) VALUES (?, ?, ?, ?)`,
+		t.UserID, t.Name, t.TokenHash, t.CreatedAt.Unix())
+	if err != nil {
+		return t, err
+	}
+	id, err := res.LastInsertId()
+	t.ID = int(id)
+	return t, err
+}
+
+func (store *apiTokenStore) ByToken(tokenHash string) (cah.APIToken, error) {
+	var row apiTokenRow
+	err := db.Get(&row, `SELECT * FROM api_token WHERE token = ?`, tokenHash)
+	return row.apiToken(), err
+}
+
+// ByUser returns the API tokens of the user, the newest first
+func (store *apiTokenStore) ByUser(userID int) ([]cah.APIToken, error) {
+	rows := []apiTokenRow{}
+	err := db.Select(&rows, `SELECT * FROM api_token WHERE user = ? ORDER BY api_token DESC`, userID)
+	ret := make([]cah.APIToken, len(rows))
+	for i, r := range rows {
+		ret[i] = r.apiToken()
+	}
+	return ret, err
+}
+
+func (store *apiTokenStore) Touch(id int, lastUsed time.Time) error {
+	_, err := db.Exec(`UPDATE api_token SET last_used_at = ? WHERE api_token = ?`, lastUsed.Unix(), id)
+	return err
+}
+
+func (store *apiTokenStore) Delete(id int) error {
+	_, err := db.Exec(`DELETE FROM api_token WHERE api_token = ?`, id)
+	return err
+}
@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokens(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewAPITokenStore()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	first, err := store.Create(cah.APIToken{UserID: 1, Name: "first", TokenHash: "a", CreatedAt: now})
+	assert.NoError(err)
+	assert.NotZero(first.ID)
+	_, err = store.Create(cah.APIToken{UserID: 1, Name: "repeated", TokenHash: "a", CreatedAt: now})
+	assert.Error(err, "Tokens should be unique")
+	second, err := store.Create(cah.APIToken{UserID: 1, Name: "second", TokenHash: "b", CreatedAt: now})
+	assert.NoError(err)
+	_, err = store.Create(cah.APIToken{UserID: 2, Name: "other", TokenHash: "c", CreatedAt: now})
+	assert.NoError(err)
+
+	found, err := store.ByToken("a")
+	assert.NoError(err)
+	assert.Equal(first, found)
+	assert.True(found.LastUsedAt.IsZero())
+
+	assert.NoError(store.Touch(first.ID, now.Add(time.Minute)))
+	found, err = store.ByToken("a")
+	assert.NoError(err)
+	assert.Equal(now.Add(time.Minute), found.LastUsedAt)
+
+	tokens, err := store.ByUser(1)
+	assert.NoError(err)
+	assert.Len(tokens, 2)
+	assert.Equal(second.ID, tokens[0].ID, "The newest token should come first")
+
+	assert.NoError(store.Delete(first.ID))
+	_, err = store.ByToken("a")
+	assert.Error(err)
+}
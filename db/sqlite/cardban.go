@@ -0,0 +1,25 @@
+package sqlite
+
+type cardBanStore struct{}
+
+func NewCardBanStore() *cardBanStore {
+	return &cardBanStore{}
+}
+
+func (store *cardBanStore) Ban(userID, cardID int) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO card_ban (user, card) VALUES (?, ?)`,
+		userID, cardID)
+	return err
+}
+
+func (store *cardBanStore) Unban(userID, cardID int) error {
+	_, err := db.Exec(`DELETE FROM card_ban WHERE user = ? AND card = ?`,
+		userID, cardID)
+	return err
+}
+
+func (store *cardBanStore) Banned(userID int) ([]int, error) {
+	res := []int{}
+	err := db.Select(&res, `SELECT card FROM card_ban WHERE user = ? ORDER BY card_ban`, userID)
+	return res, err
+}
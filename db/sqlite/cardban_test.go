@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardBan(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewCardBanStore()
+
+	assert.NoError(store.Ban(1, 100))
+	assert.NoError(store.Ban(1, 200))
+	assert.NoError(store.Ban(1, 100), "Banning a card twice should not fail")
+	assert.NoError(store.Ban(2, 300))
+	banned, err := store.Banned(1)
+	assert.NoError(err)
+	assert.Equal([]int{100, 200}, banned)
+
+	assert.NoError(store.Unban(1, 100))
+	banned, err = store.Banned(1)
+	assert.NoError(err)
+	assert.Equal([]int{200}, banned)
+	banned, err = store.Banned(3)
+	assert.NoError(err)
+	assert.Empty(banned)
+}
@@ -19,4 +19,19 @@ func InitDB(dbFileName string) {
 
 func CreateTables() {
 	createTableUser()
+	createTableCardBan()
+	createTableCardModeration()
+	createTableCardReport()
+	createTableCardStats()
+	createTableCardSeen()
+	createTableGameResult()
+	createTableUserCardWin()
+	createTableUserRating()
+	createTableSession()
+	createTableUserTOTP()
+	createTableRecoveryCode()
+	createTableAPIToken()
+	createTableUserIdentity()
+	createTableFriendship()
+	createTableUserSeen()
 }
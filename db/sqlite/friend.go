@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+type friendStore struct{}
+
+func NewFriendStore() *friendStore {
+	return &friendStore{}
+}
+
+// friendshipRow is a relation as stored, with since as a unix timestamp
+type friendshipRow struct {
+	ID       int              `db:"friendship"`
+	UserID   int              `db:"user"`
+	FriendID int              `db:"friend"`
+	Status   cah.FriendStatus `db:"status"`
+	Since    int64            `db:"since"`
+}
+
+func (r friendshipRow) friendship() cah.Friendship {
+	return cah.Friendship{
+		UserID:   r.UserID,
+		FriendID: r.FriendID,
+		Status:   r.Status,
+		Since:    time.Unix(r.Since, 0),
+	}
+}
+
+func (store *friendStore) Set(from, to int, status cah.FriendStatus, at time.Time) error {
+	_, err := db.Exec(`INSERT INTO friendship (user, friend, status, since) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user, friend) DO UPDATE SET status = excluded.status, since = excluded.since`,
+		from, to, status, at.Unix())
+	return err
+}
+
+func (store *friendStore) Delete(from, to int) error {
+	_, err := db.Exec(`DELETE FROM friendship WHERE user = ? AND friend = ?`, from, to)
+	return err
+}
+
+func (store *friendStore) Get(from, to int) (cah.Friendship, error) {
+	var row friendshipRow
+	err := db.Get(&row, `SELECT * FROM friendship WHERE user = ? AND friend = ?`, from, to)
+	return row.friendship(), err
+}
+
+func (store *friendStore) From(userID int) ([]cah.Friendship, error) {
+	return store.selectFriendships(`SELECT * FROM friendship WHERE user = ? ORDER BY since, friendship`, userID)
+}
+
+func (store *friendStore) To(userID int) ([]cah.Friendship, error) {
+	return store.selectFriendships(`SELECT * FROM friendship WHERE friend = ? ORDER BY since, friendship`, userID)
+}
+
+func (store *friendStore) selectFriendships(query string, userID int) ([]cah.Friendship, error) {
+	rows := []friendshipRow{}
+	err := db.Select(&rows, query, userID)
+	ret := make([]cah.Friendship, len(rows))
+	for i, r := range rows {
+		ret[i] = r.friendship()
+	}
+	return ret, err
+}
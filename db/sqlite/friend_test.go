@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFriendships(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewFriendStore()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	assert.NoError(store.Set(1, 2, cah.FriendPending, now))
+	assert.Error(store.Set(1, 1, cah.FriendPending, now), "Users should not be related to themselves")
+	assert.Error(store.Set(1, 3, "enemies", now))
+	assert.NoError(store.Set(3, 1, cah.FriendBlocked, now))
+
+	f, err := store.Get(1, 2)
+	assert.NoError(err)
+	assert.Equal(cah.Friendship{UserID: 1, FriendID: 2, Status: cah.FriendPending, Since: now}, f)
+	assert.NoError(store.Set(1, 2, cah.FriendAccepted, now.Add(time.Minute)))
+	f, err = store.Get(1, 2)
+	assert.NoError(err)
+	assert.Equal(cah.FriendAccepted, f.Status, "Set should replace the status")
+	assert.Equal(now.Add(time.Minute), f.Since)
+
+	from, err := store.From(1)
+	assert.NoError(err)
+	assert.Len(from, 1)
+	to, err := store.To(1)
+	assert.NoError(err)
+	assert.Len(to, 1)
+	assert.Equal(3, to[0].UserID)
+
+	assert.NoError(store.Delete(1, 2))
+	_, err = store.Get(1, 2)
+	assert.Error(err)
+}
+
+func TestFriendshipsDeletedWithUser(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	a, _ := us.Create("A", "pass")
+	b, _ := us.Create("B", "pass")
+	store := NewFriendStore()
+	now := time.Now()
+	if err := store.Set(a.ID, b.ID, cah.FriendAccepted, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(b.ID, a.ID, cah.FriendAccepted, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.Delete(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if from, err := store.From(b.ID); err != nil || len(from) != 0 {
+		t.Fatalf("Expected the relations with the deleted user to be deleted, got %v, error: %v", from, err)
+	}
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/j4rv/cah"
+)
+
+type moderationStore struct{}
+
+func NewModerationStore() *moderationStore {
+	return &moderationStore{}
+}
+
+func (store *moderationStore) SetTags(cardID int, tags []string) error {
+	_, err := db.Exec(`INSERT INTO card_moderation (card, tags) VALUES (?, ?)
+		ON CONFLICT(card) DO UPDATE SET tags = excluded.tags`,
+		cardID, strings.Join(tags, ","))
+	return err
+}
+
+func (store *moderationStore) Tags() (map[int][]string, error) {
+	rows := []struct {
+		Card int    `db:"card"`
+		Tags string `db:"tags"`
+	}{}
+	err := db.Select(&rows, `SELECT card, tags FROM card_moderation WHERE tags IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[int][]string, len(rows))
+	for _, r := range rows {
+		ret[r.Card] = []string{}
+		if r.Tags != "" {
+			ret[r.Card] = strings.Split(r.Tags, ",")
+		}
+	}
+	return ret, nil
+}
+
+func (store *moderationStore) SetText(cardID int, text string) error {
+	_, err := db.Exec(`INSERT INTO card_moderation (card, text) VALUES (?, ?)
+		ON CONFLICT(card) DO UPDATE SET text = excluded.text`,
+		cardID, text)
+	return err
+}
+
+func (store *moderationStore) Texts() (map[int]string, error) {
+	rows := []struct {
+		Card int    `db:"card"`
+		Text string `db:"text"`
+	}{}
+	err := db.Select(&rows, `SELECT card, text FROM card_moderation WHERE text IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[int]string, len(rows))
+	for _, r := range rows {
+		ret[r.Card] = r.Text
+	}
+	return ret, nil
+}
+
+func (store *moderationStore) SetDisabled(cardID int, disabled bool) error {
+	_, err := db.Exec(`INSERT INTO card_moderation (card, disabled) VALUES (?, ?)
+		ON CONFLICT(card) DO UPDATE SET disabled = excluded.disabled`,
+		cardID, disabled)
+	return err
+}
+
+func (store *moderationStore) Disabled() ([]int, error) {
+	res := []int{}
+	err := db.Select(&res, `SELECT card FROM card_moderation WHERE disabled = 1 ORDER BY card`)
+	return res, err
+}
+
+func (store *moderationStore) Report(userID, cardID int, reason string) error {
+	_, err := db.Exec(`INSERT INTO card_report (card, user, reason) VALUES (?, ?, ?)
+		ON CONFLICT(card, user) DO UPDATE SET reason = excluded.reason, resolved = 0, created_at = CURRENT_TIMESTAMP`,
+		cardID, userID, reason)
+	return err
+}
+
+// Reports returns the reports not resolved yet, oldest first
+func (store *moderationStore) Reports() ([]cah.CardReport, error) {
+	res := []cah.CardReport{}
+	err := db.Select(&res, `SELECT card_report, card, user, reason, created_at FROM card_report
+		WHERE resolved = 0 ORDER BY created_at, card_report`)
+	return res, err
+}
+
+func (store *moderationStore) ResolveReports(cardID int) error {
+	_, err := db.Exec(`UPDATE card_report SET resolved = 1 WHERE card = ?`, cardID)
+	return err
+}
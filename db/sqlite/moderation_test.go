@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerationTags(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewModerationStore()
+
+	assert.NoError(store.SetTags(1, []string{"nsfw", "political"}))
+	assert.NoError(store.SetTags(2, []string{"language"}))
+	assert.NoError(store.SetTags(2, []string{}))
+	assert.NoError(store.SetDisabled(3, true))
+	tags, err := store.Tags()
+	assert.NoError(err)
+	assert.Equal(map[int][]string{1: {"nsfw", "political"}, 2: {}}, tags)
+}
+
+func TestModerationTextsAndDisabled(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewModerationStore()
+
+	assert.NoError(store.SetText(1, "Fixed _ card"))
+	assert.NoError(store.SetDisabled(1, true))
+	assert.NoError(store.SetDisabled(2, true))
+	assert.NoError(store.SetDisabled(2, false))
+	texts, err := store.Texts()
+	assert.NoError(err)
+	assert.Equal(map[int]string{1: "Fixed _ card"}, texts)
+	disabled, err := store.Disabled()
+	assert.NoError(err)
+	assert.Equal([]int{1}, disabled)
+}
+
+func TestModerationReports(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewModerationStore()
+
+	assert.NoError(store.Report(1, 10, "Offensive"))
+	assert.NoError(store.Report(2, 10, "Wrong blanks"))
+	assert.NoError(store.Report(1, 10, "Very offensive"))
+	assert.NoError(store.Report(1, 20, "Typo"))
+	assert.Error(store.Report(1, 30, ""))
+	reports, err := store.Reports()
+	assert.NoError(err)
+	assert.Len(reports, 3)
+	reasons := map[string]bool{}
+	for _, r := range reports {
+		reasons[r.Reason] = true
+	}
+	assert.Equal(map[string]bool{"Very offensive": true, "Wrong blanks": true, "Typo": true}, reasons)
+
+	assert.NoError(store.ResolveReports(10))
+	reports, err = store.Reports()
+	assert.NoError(err)
+	assert.Len(reports, 1)
+	assert.Equal(20, reports[0].CardID)
+	assert.Equal(1, reports[0].UserID)
+}
+
+func TestModerationOldTable(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	// The table as it was when it only kept the tags
+	db.MustExec(`DROP TABLE card_moderation`)
+	createTable("card_moderation", []string{
+		"card INTEGER NOT NULL UNIQUE",
+		"tags TEXT NOT NULL DEFAULT ''",
+	})
+	db.MustExec(`INSERT INTO card_moderation (card, tags) VALUES (1, 'nsfw'), (2, '')`)
+	CreateTables()
+	store := NewModerationStore()
+
+	assert.NoError(store.SetText(3, "Fixed _ card"))
+	assert.NoError(store.SetDisabled(4, true))
+	tags, err := store.Tags()
+	assert.NoError(err)
+	assert.Equal(map[int][]string{1: {"nsfw"}, 2: {}}, tags, "The old tags should be kept, and other changes should not clear them")
+	texts, err := store.Texts()
+	assert.NoError(err)
+	assert.Equal(map[int]string{3: "Fixed _ card"}, texts)
+	disabled, err := store.Disabled()
+	assert.NoError(err)
+	assert.Equal([]int{4}, disabled)
+}
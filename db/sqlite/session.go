@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/jmoiron/sqlx"
+)
+
+type sessionStore struct{}
+
+func NewSessionStore() *sessionStore {
+	return &sessionStore{}
+}
+
+// sessionRow is a session as stored, with the times as unix timestamps and the age in seconds
+type sessionRow struct {
+	ID         int    `db:"session"`
+	UserID     int    `db:"user"`
+	TokenHash  string `db:"token"`
+	UserAgent  string `db:"user_agent"`
+	IP         string `db:"ip"`
+	Age        int64  `db:"age"`
+	CreatedAt  int64  `db:"created_at"`
+	LastSeenAt int64  `db:"last_seen_at"`
+	ExpiresAt  int64  `db:"expires_at"`
+}
+
+func (r sessionRow) session() cah.Session {
+	return cah.Session{
+		ID:         r.ID,
+		UserID:     r.UserID,
+		TokenHash:  r.TokenHash,
+		UserAgent:  r.UserAgent,
+		IP:         r.IP,
+		Age:        time.Duration(r.Age) * time.Second,
+		CreatedAt:  time.Unix(r.CreatedAt, 0),
+		LastSeenAt: time.Unix(r.LastSeenAt, 0),
+		ExpiresAt:  time.Unix(r.ExpiresAt, 0),
+	}
+}
+
+func (store *sessionStore) Create(s cah.Session) (cah.Session, error) {
+	res, err := db.Exec(`INSERT INTO session (user, token, user_agent, ip, age, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, s.UserID, s.TokenHash, s.UserAgent, s.IP, int64(s.Age/time.Second),
+		s.CreatedAt.Unix(), s.LastSeenAt.Unix(), s.ExpiresAt.Unix())
+	if err != nil {
+		return s, err
+	}
+	id, err := res.LastInsertId()
+	s.ID = int(id)
+	return s, err
+}
+
+func (store *sessionStore) ByToken(tokenHash string) (cah.Session, error) {
+	var row sessionRow
+	err := db.Get(&row, `SELECT * FROM session WHERE token = ?`, tokenHash)
+	return row.session(), err
+}
+
+// ByUser returns the sessions of the user, the most recently used first
+func (store *sessionStore) ByUser(userID int) ([]cah.Session, error) {
+	rows := []sessionRow{}
+	err := db.Select(&rows, `SELECT * FROM session WHERE user = ? ORDER BY last_seen_at DESC, session DESC`, userID)
+	ret := make([]cah.Session, len(rows))
+	for i, r := range rows {
+		ret[i] = r.session()
+	}
+	return ret, err
+}
+
+func (store *sessionStore) Touch(id int, lastSeen, expiresAt time.Time) error {
+	_, err := db.Exec(`UPDATE session SET last_seen_at = ?, expires_at = ? WHERE session = ?`,
+		lastSeen.Unix(), expiresAt.Unix(), id)
+	return err
+}
+
+func (store *sessionStore) Delete(ids ...int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`DELETE FROM session WHERE session IN (?)`, ids)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(db.Rebind(query), args...)
+	return err
+}
+
+func (store *sessionStore) DeleteByUser(userID int) error {
+	_, err := db.Exec(`DELETE FROM session WHERE user = ?`, userID)
+	return err
+}
+
+func (store *sessionStore) DeleteExpired(now time.Time) error {
+	_, err := db.Exec(`DELETE FROM session WHERE expires_at <= ?`, now.Unix())
+	return err
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewSessionStore()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	s, err := store.Create(cah.Session{UserID: 1, TokenHash: "a", UserAgent: "Firefox", IP: "127.0.0.1",
+		Age: time.Hour, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(err)
+	assert.NotZero(s.ID)
+	_, err = store.Create(cah.Session{UserID: 1, TokenHash: "a", CreatedAt: now, LastSeenAt: now, ExpiresAt: now})
+	assert.Error(err, "Tokens should be unique")
+	old, err := store.Create(cah.Session{UserID: 1, TokenHash: "b", Age: time.Minute,
+		CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)})
+	assert.NoError(err)
+	_, err = store.Create(cah.Session{UserID: 2, TokenHash: "c", Age: time.Minute,
+		CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Minute)})
+	assert.NoError(err)
+
+	found, err := store.ByToken("a")
+	assert.NoError(err)
+	assert.Equal(s, found)
+
+	sessions, err := store.ByUser(1)
+	assert.NoError(err)
+	assert.Len(sessions, 2)
+	assert.Equal(s.ID, sessions[0].ID, "The most recently used session should come first")
+
+	assert.NoError(store.Touch(old.ID, now.Add(time.Second), now.Add(time.Minute)))
+	found, err = store.ByToken("b")
+	assert.NoError(err)
+	assert.Equal(now.Add(time.Minute), found.ExpiresAt)
+
+	assert.NoError(store.DeleteExpired(now.Add(2 * time.Minute)))
+	_, err = store.ByToken("b")
+	assert.Error(err)
+	_, err = store.ByToken("a")
+	assert.NoError(err)
+
+	assert.NoError(store.Delete(s.ID))
+	_, err = store.ByToken("a")
+	assert.Error(err)
+
+	assert.NoError(store.DeleteByUser(2))
+	_, err = store.ByToken("c")
+	assert.Error(err)
+}
@@ -0,0 +1,216 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/jmoiron/sqlx"
+)
+
+type statsStore struct{}
+
+func NewStatsStore() *statsStore {
+	return &statsStore{}
+}
+
+func (store *statsStore) AddDealt(cardIDs ...int) error {
+	return addCardStat("dealt", cardIDs)
+}
+
+func (store *statsStore) AddPlayed(cardIDs ...int) error {
+	return addCardStat("played", cardIDs)
+}
+
+func (store *statsStore) AddWon(cardIDs ...int) error {
+	return addCardStat("won", cardIDs)
+}
+
+// CardStats returns the stats of the cards, cards without stats are not in the map
+func (store *statsStore) CardStats(cardIDs ...int) (map[int]cah.CardStats, error) {
+	ret := map[int]cah.CardStats{}
+	if len(cardIDs) == 0 {
+		return ret, nil
+	}
+	query, args, err := sqlx.In(`SELECT card, dealt, played, won FROM card_stats WHERE card IN (?)`, cardIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows := []cah.CardStats{}
+	err = db.Select(&rows, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		ret[r.CardID] = r
+	}
+	return ret, nil
+}
+
+func (store *statsStore) AddSeen(userID int, cardIDs ...int) error {
+	if len(cardIDs) == 0 {
+		return nil
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, id := range cardIDs {
+		_, err := tx.Exec(`INSERT INTO card_seen (user, card, seen_at) VALUES (?, ?, ?)
+			ON CONFLICT(user, card) DO UPDATE SET seen_at = excluded.seen_at`, userID, id, now)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Seen returns the cards seen by any of the users since that time
+func (store *statsStore) Seen(since time.Time, userIDs ...int) ([]int, error) {
+	res := []int{}
+	if len(userIDs) == 0 {
+		return res, nil
+	}
+	query, args, err := sqlx.In(`SELECT DISTINCT card FROM card_seen WHERE seen_at >= ? AND user IN (?) ORDER BY card`,
+		since.Unix(), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Select(&res, db.Rebind(query), args...)
+	return res, err
+}
+
+func (store *statsStore) AddGameResults(results ...cah.GameResult) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		_, err := tx.Exec(`INSERT INTO game_result (user, game_state, won, rounds_won, cards_played, rating_change, finished_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, r.UserID, r.GameStateID, r.Won, r.RoundsWon, r.CardsPlayed, r.RatingChange, r.FinishedAt.Unix())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO user_rating (user, rating) VALUES (?, ?)
+			ON CONFLICT(user) DO UPDATE SET rating = rating + ?`, r.UserID, r.Rating, r.RatingChange)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, card := range r.WinningCards {
+			_, err := tx.Exec(`INSERT INTO user_card_win (user, card, wins) VALUES (?, ?, 1)
+				ON CONFLICT(user, card) DO UPDATE SET wins = wins + 1`, r.UserID, card)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// UserStats adds up the game results of the user, with up to favourites favourite cards
+func (store *statsStore) UserStats(userID int, favourites int) (cah.UserStats, error) {
+	res := cah.UserStats{}
+	err := db.Get(&res, `SELECT ? AS user, COUNT(*) AS games_played, IFNULL(SUM(won), 0) AS games_won,
+		IFNULL(SUM(rounds_won), 0) AS rounds_won, IFNULL(SUM(cards_played), 0) AS cards_played
+		FROM game_result WHERE user = ?`, userID, userID)
+	if err != nil {
+		return res, err
+	}
+	res.FavouriteCards = []cah.FavouriteCard{}
+	err = db.Select(&res.FavouriteCards, `SELECT card, wins FROM user_card_win WHERE user = ?
+		ORDER BY wins DESC, user_card_win LIMIT ?`, userID, favourites)
+	return res, err
+}
+
+// Ratings returns the ratings of the users, users without a rating are not in the map
+func (store *statsStore) Ratings(userIDs ...int) (map[int]float64, error) {
+	ret := map[int]float64{}
+	if len(userIDs) == 0 {
+		return ret, nil
+	}
+	query, args, err := sqlx.In(`SELECT user, rating FROM user_rating WHERE user IN (?)`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows := []struct {
+		User   int     `db:"user"`
+		Rating float64 `db:"rating"`
+	}{}
+	err = db.Select(&rows, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		ret[r.User] = r.Rating
+	}
+	return ret, nil
+}
+
+func (store *statsStore) Leaderboard(q cah.LeaderboardQuery) (cah.Leaderboard, error) {
+	res := cah.Leaderboard{Entries: []cah.LeaderboardEntry{}}
+	where := ""
+	args := []interface{}{q.Since.Unix()}
+	if q.Since.IsZero() {
+		args[0] = 0
+	}
+	if len(q.UserIDs) != 0 {
+		where = "WHERE r.user IN (?)"
+		args = append(args, q.UserIDs)
+	}
+	order := "rating DESC"
+	if !q.Since.IsZero() {
+		order = "rating_change DESC, rating DESC"
+	}
+	// Using Sprintf since where and order are not user inputs
+	ranked := fmt.Sprintf(`SELECT r.user, u.username, r.rating, COUNT(*) AS games_played,
+		SUM(g.won) AS games_won, SUM(g.rating_change) AS rating_change
+		FROM user_rating r
+		JOIN user u ON u.user = r.user
+		JOIN game_result g ON g.user = r.user AND g.finished_at >= ?
+		%s GROUP BY r.user`, where)
+
+	query, countArgs, err := sqlx.In(`SELECT COUNT(*) FROM (`+ranked+`)`, args...)
+	if err != nil {
+		return res, err
+	}
+	if err := db.Get(&res.Total, db.Rebind(query), countArgs...); err != nil {
+		return res, err
+	}
+	query, pageArgs, err := sqlx.In(ranked+` ORDER BY `+order+`, r.user LIMIT ? OFFSET ?`, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return res, err
+	}
+	if err := db.Select(&res.Entries, db.Rebind(query), pageArgs...); err != nil {
+		return res, err
+	}
+	for i := range res.Entries {
+		res.Entries[i].Rank = q.Offset + i + 1
+	}
+	return res, nil
+}
+
+// addCardStat increases the stat column by one for each card, a card repeated in cardIDs is increased once per repetition
+func addCardStat(column string, cardIDs []int) error {
+	if len(cardIDs) == 0 {
+		return nil
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	// Using Sprintf since the column is not an user input
+	stmt := fmt.Sprintf(`INSERT INTO card_stats (card, %[1]s) VALUES (?, 1)
+		ON CONFLICT(card) DO UPDATE SET %[1]s = %[1]s + 1`, column)
+	for _, id := range cardIDs {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
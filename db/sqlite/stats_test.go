@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardStats(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewStatsStore()
+
+	assert.NoError(store.AddDealt(1, 2, 3, 1))
+	assert.NoError(store.AddPlayed(1, 2))
+	assert.NoError(store.AddWon(1))
+	stats, err := store.CardStats(1, 2, 3, 4)
+	assert.NoError(err)
+	assert.Len(stats, 3)
+	assert.Equal(2, stats[1].Dealt)
+	assert.Equal(1, stats[1].Played)
+	assert.Equal(1, stats[1].Won)
+	assert.Equal(1, stats[2].Played)
+	assert.Equal(0, stats[2].Won)
+	assert.Equal(0, stats[3].Played)
+}
+
+func TestCardSeen(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewStatsStore()
+
+	assert.NoError(store.AddSeen(1, 10, 11))
+	assert.NoError(store.AddSeen(2, 11, 12))
+	assert.NoError(store.AddSeen(3, 13))
+	db.MustExec(`UPDATE card_seen SET seen_at = ? WHERE card = 12`, time.Now().Add(-48*time.Hour).Unix())
+	seen, err := store.Seen(time.Now().Add(-24*time.Hour), 1, 2)
+	assert.NoError(err)
+	assert.Equal([]int{10, 11}, seen)
+	seen, err = store.Seen(time.Now().Add(-72*time.Hour), 2)
+	assert.NoError(err)
+	assert.Equal([]int{11, 12}, seen)
+}
+
+func TestUserStats(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewStatsStore()
+
+	stats, err := store.UserStats(1, 3)
+	assert.NoError(err)
+	assert.Equal(cah.UserStats{UserID: 1, FavouriteCards: []cah.FavouriteCard{}}, stats)
+
+	now := time.Now()
+	assert.NoError(store.AddGameResults(
+		cah.GameResult{UserID: 1, GameStateID: 1, Won: true, RoundsWon: 3, CardsPlayed: 8, WinningCards: []int{10, 11, 10}, FinishedAt: now},
+		cah.GameResult{UserID: 2, GameStateID: 1, RoundsWon: 1, CardsPlayed: 9, WinningCards: []int{12}, FinishedAt: now},
+	))
+	assert.NoError(store.AddGameResults(
+		cah.GameResult{UserID: 1, GameStateID: 2, RoundsWon: 1, CardsPlayed: 5, WinningCards: []int{13}, FinishedAt: now},
+	))
+	stats, err = store.UserStats(1, 2)
+	assert.NoError(err)
+	assert.Equal(2, stats.GamesPlayed)
+	assert.Equal(1, stats.GamesWon)
+	assert.Equal(4, stats.RoundsWon)
+	assert.Equal(13, stats.CardsPlayed)
+	assert.Equal([]cah.FavouriteCard{{CardID: 10, Wins: 2}, {CardID: 11, Wins: 1}}, stats.FavouriteCards)
+}
+
+func TestLeaderboard(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewStatsStore()
+	users := NewUserStore()
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		_, err := users.Create(name, "password")
+		assert.NoError(err)
+	}
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	now := time.Now()
+	assert.NoError(store.AddGameResults(
+		cah.GameResult{UserID: 1, GameStateID: 1, Won: true, Rating: 1520, RatingChange: 20, FinishedAt: old},
+		cah.GameResult{UserID: 2, GameStateID: 1, Rating: 1480, RatingChange: -20, FinishedAt: old},
+	))
+	assert.NoError(store.AddGameResults(
+		cah.GameResult{UserID: 2, GameStateID: 2, Won: true, Rating: 1490, RatingChange: 10, FinishedAt: now},
+		cah.GameResult{UserID: 3, GameStateID: 2, Rating: 1490, RatingChange: -10, FinishedAt: now},
+	))
+
+	ratings, err := store.Ratings(1, 2, 4)
+	assert.NoError(err)
+	assert.Equal(map[int]float64{1: 1520, 2: 1490}, ratings)
+
+	board, err := store.Leaderboard(cah.LeaderboardQuery{Limit: 10})
+	assert.NoError(err)
+	assert.Equal(3, board.Total)
+	assert.Equal([]cah.LeaderboardEntry{
+		{Rank: 1, UserID: 1, Username: "Alice", Rating: 1520, RatingChange: 20, GamesPlayed: 1, GamesWon: 1},
+		{Rank: 2, UserID: 2, Username: "Bob", Rating: 1490, RatingChange: -10, GamesPlayed: 2, GamesWon: 1},
+		{Rank: 3, UserID: 3, Username: "Carol", Rating: 1490, RatingChange: -10, GamesPlayed: 1, GamesWon: 0},
+	}, board.Entries)
+
+	board, err = store.Leaderboard(cah.LeaderboardQuery{Since: now.Add(-30 * 24 * time.Hour), Limit: 1, Offset: 0})
+	assert.NoError(err)
+	assert.Equal(2, board.Total, "Players without games in the window are not ranked")
+	assert.Equal([]cah.LeaderboardEntry{
+		{Rank: 1, UserID: 2, Username: "Bob", Rating: 1490, RatingChange: 10, GamesPlayed: 1, GamesWon: 1},
+	}, board.Entries)
+
+	board, err = store.Leaderboard(cah.LeaderboardQuery{UserIDs: []int{1, 3}, Limit: 10, Offset: 1})
+	assert.NoError(err)
+	assert.Equal(2, board.Total)
+	assert.Len(board.Entries, 1)
+	assert.Equal(2, board.Entries[0].Rank)
+	assert.Equal("Carol", board.Entries[0].Username)
+}
+
+func TestRatingChanges(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewStatsStore()
+	assert.NoError(store.AddGameResults(cah.GameResult{UserID: 1, Rating: 1510, RatingChange: 10, FinishedAt: time.Now()}))
+	// Two games that finished at the same time, both rated from 1510
+	assert.NoError(store.AddGameResults(cah.GameResult{UserID: 1, Rating: 1530, RatingChange: 20, FinishedAt: time.Now()}))
+	assert.NoError(store.AddGameResults(cah.GameResult{UserID: 1, Rating: 1505, RatingChange: -5, FinishedAt: time.Now()}))
+	ratings, err := store.Ratings(1)
+	assert.NoError(err)
+	assert.Equal(map[int]float64{1: 1525}, ratings, "No rating change should be lost")
+}
@@ -10,9 +10,191 @@ func createTableUser() {
 		"username TEXT UNIQUE",
 		"password TEXT",
 		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"locale TEXT NOT NULL DEFAULT ''",
+		"guest INTEGER NOT NULL DEFAULT 0",
+		"role TEXT NOT NULL DEFAULT 'user'",
+		"banned INTEGER NOT NULL DEFAULT 0",
+		"CHECK(role IN ('user', 'moderator', 'admin'))",
 		"CHECK(username <> '' AND password <> '' AND LENGTH(username) <= 36)",
 	})
 	createIndex("user", "username")
+	addColumn("user", "locale", "TEXT NOT NULL DEFAULT ''")
+	addColumn("user", "guest", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("user", "role", "TEXT NOT NULL DEFAULT 'user'")
+	addColumn("user", "banned", "INTEGER NOT NULL DEFAULT 0")
+}
+
+func createTableCardBan() {
+	createTable("card_ban", []string{
+		"user INTEGER NOT NULL",
+		"card INTEGER NOT NULL",
+		"UNIQUE(user, card)",
+	})
+}
+
+// card_moderation holds a row per moderated card, NULL tags or text mean they were not changed
+func createTableCardModeration() {
+	// Older databases only kept the tags, which could not be NULL. SQLite can not change that
+	// with ALTER TABLE, so the table is created again and its rows, which all changed the tags, copied.
+	var tagsNotNull bool
+	db.Get(&tagsNotNull, `SELECT "notnull" FROM pragma_table_info('card_moderation') WHERE name = 'tags'`)
+	if tagsNotNull {
+		db.MustExec(`ALTER TABLE card_moderation RENAME TO card_moderation_old`)
+	}
+	createTable("card_moderation", []string{
+		"card INTEGER NOT NULL UNIQUE",
+		"tags TEXT",
+		"text TEXT",
+		"disabled INTEGER NOT NULL DEFAULT 0",
+	})
+	addColumn("card_moderation", "text", "TEXT")
+	addColumn("card_moderation", "disabled", "INTEGER NOT NULL DEFAULT 0")
+	if tagsNotNull {
+		db.MustExec(`INSERT INTO card_moderation (card, tags) SELECT card, tags FROM card_moderation_old`)
+		db.MustExec(`DROP TABLE card_moderation_old`)
+	}
+}
+
+func createTableCardReport() {
+	createTable("card_report", []string{
+		"card INTEGER NOT NULL",
+		"user INTEGER NOT NULL",
+		"reason TEXT NOT NULL",
+		"resolved INTEGER NOT NULL DEFAULT 0",
+		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(card, user)",
+		"CHECK(reason <> '' AND LENGTH(reason) <= 200)",
+	})
+}
+
+func createTableCardStats() {
+	createTable("card_stats", []string{
+		"card INTEGER NOT NULL UNIQUE",
+		"dealt INTEGER NOT NULL DEFAULT 0",
+		"played INTEGER NOT NULL DEFAULT 0",
+		"won INTEGER NOT NULL DEFAULT 0",
+	})
+}
+
+// card_seen holds when a user saw a card for the last time, as a unix timestamp
+func createTableCardSeen() {
+	createTable("card_seen", []string{
+		"user INTEGER NOT NULL",
+		"card INTEGER NOT NULL",
+		"seen_at INTEGER NOT NULL",
+		"UNIQUE(user, card)",
+	})
+	createIndex("card_seen", "seen_at")
+}
+
+// game_result holds a row per player of each finished game, finished_at is a unix timestamp
+func createTableGameResult() {
+	createTable("game_result", []string{
+		"user INTEGER NOT NULL",
+		"game_state INTEGER NOT NULL",
+		"won INTEGER NOT NULL DEFAULT 0",
+		"rounds_won INTEGER NOT NULL DEFAULT 0",
+		"cards_played INTEGER NOT NULL DEFAULT 0",
+		"rating_change REAL NOT NULL DEFAULT 0",
+		"finished_at INTEGER NOT NULL",
+	})
+	createIndex("game_result", "user")
+	createIndex("game_result", "finished_at")
+	addColumn("game_result", "rating_change", "REAL NOT NULL DEFAULT 0")
+}
+
+func createTableUserRating() {
+	createTable("user_rating", []string{
+		"user INTEGER NOT NULL UNIQUE",
+		"rating REAL NOT NULL",
+	})
+}
+
+func createTableUserCardWin() {
+	createTable("user_card_win", []string{
+		"user INTEGER NOT NULL",
+		"card INTEGER NOT NULL",
+		"wins INTEGER NOT NULL DEFAULT 0",
+		"UNIQUE(user, card)",
+	})
+}
+
+// session holds the server side sessions, with the times as unix timestamps and the age in seconds
+func createTableSession() {
+	createTable("session", []string{
+		"user INTEGER NOT NULL",
+		"token TEXT NOT NULL UNIQUE",
+		"user_agent TEXT NOT NULL DEFAULT ''",
+		"ip TEXT NOT NULL DEFAULT ''",
+		"age INTEGER NOT NULL",
+		"created_at INTEGER NOT NULL",
+		"last_seen_at INTEGER NOT NULL",
+		"expires_at INTEGER NOT NULL",
+	})
+	createIndex("session", "user")
+}
+
+// user_totp holds the TOTP secrets, last_step is the time step of the last code used
+func createTableUserTOTP() {
+	createTable("user_totp", []string{
+		"user INTEGER NOT NULL UNIQUE",
+		"secret TEXT NOT NULL",
+		"enabled INTEGER NOT NULL DEFAULT 0",
+		"last_step INTEGER NOT NULL DEFAULT 0",
+	})
+}
+
+// recovery_code holds the SHA-256 of the unused recovery codes
+func createTableRecoveryCode() {
+	createTable("recovery_code", []string{
+		"user INTEGER NOT NULL",
+		"code TEXT NOT NULL",
+		"UNIQUE(user, code)",
+	})
+}
+
+// api_token holds the SHA-256 of the personal API tokens, with the times as unix timestamps
+func createTableAPIToken() {
+	createTable("api_token", []string{
+		"user INTEGER NOT NULL",
+		"name TEXT NOT NULL",
+		"token TEXT NOT NULL UNIQUE",
+		"created_at INTEGER NOT NULL",
+		"last_used_at INTEGER NOT NULL DEFAULT 0",
+	})
+	createIndex("api_token", "user")
+}
+
+// user_identity links the users to their identities in external login providers
+func createTableUserIdentity() {
+	createTable("user_identity", []string{
+		"user INTEGER NOT NULL",
+		"issuer TEXT NOT NULL",
+		"subject TEXT NOT NULL",
+		"UNIQUE(issuer, subject)",
+	})
+	createIndex("user_identity", "user")
+}
+
+// friendship holds the relations from a user to another, with since as a unix timestamp
+func createTableFriendship() {
+	createTable("friendship", []string{
+		"user INTEGER NOT NULL",
+		"friend INTEGER NOT NULL",
+		"status TEXT NOT NULL",
+		"since INTEGER NOT NULL",
+		"UNIQUE(user, friend)",
+		"CHECK(status IN ('pending', 'accepted', 'blocked') AND user <> friend)",
+	})
+	createIndex("friendship", "friend")
+}
+
+// user_seen holds the last time each guest was seen as a unix timestamp, so only inactive guests are deleted
+func createTableUserSeen() {
+	createTable("user_seen", []string{
+		"user INTEGER NOT NULL UNIQUE",
+		"seen_at INTEGER NOT NULL",
+	})
 }
 
 // methods for repetitive stuff
@@ -26,6 +208,19 @@ func createTable(table string, columns []string) {
 	db.MustExec(statement)
 }
 
+// addColumn adds a column to a table created before the column existed
+func addColumn(table, column, definition string) {
+	columns := []string{}
+	db.Select(&columns, `SELECT name FROM pragma_table_info(?)`, table)
+	for _, c := range columns {
+		if c == column {
+			return
+		}
+	}
+	// Using Sprintf since this internal method does not use user inputs
+	db.MustExec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+}
+
 func createIndex(table, column string) {
 	indexName := fmt.Sprintf("%s_%s", table, column)
 	// Using Sprintf since this internal method does not use user inputs
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"github.com/j4rv/cah"
+)
+
+type twoFactorStore struct{}
+
+func NewTwoFactorStore() *twoFactorStore {
+	return &twoFactorStore{}
+}
+
+func (store *twoFactorStore) TwoFactor(userID int) (cah.TwoFactor, error) {
+	res := cah.TwoFactor{}
+	err := db.Get(&res, `SELECT user, secret, enabled, last_step FROM user_totp WHERE user = ?`, userID)
+	return res, err
+}
+
+func (store *twoFactorStore) SetTwoFactor(tf cah.TwoFactor) error {
+	_, err := db.Exec(`INSERT INTO user_totp (user, secret, enabled, last_step) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user) DO UPDATE SET secret = excluded.secret, enabled = excluded.enabled, last_step = excluded.last_step`,
+		tf.UserID, tf.Secret, tf.Enabled, tf.LastStep)
+	return err
+}
+
+// DeleteTwoFactor removes the secret and the recovery codes of the user
+func (store *twoFactorStore) DeleteTwoFactor(userID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user = ?`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_code WHERE user = ?`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user
+func (store *twoFactorStore) SetRecoveryCodes(userID int, hashes ...string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_code WHERE user = ?`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_code (user, code) VALUES (?, ?)`, userID, h); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (store *twoFactorStore) UseRecoveryCode(userID int, hash string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM recovery_code WHERE user = ? AND code = ?`, userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (store *twoFactorStore) RecoveryCodes(userID int) (int, error) {
+	var n int
+	err := db.Get(&n, `SELECT COUNT(*) FROM recovery_code WHERE user = ?`, userID)
+	return n, err
+}
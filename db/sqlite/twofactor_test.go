@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/j4rv/cah"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoFactor(t *testing.T) {
+	assert := assert.New(t)
+	InitDB(":memory:")
+	defer db.Close()
+	store := NewTwoFactorStore()
+
+	_, err := store.TwoFactor(1)
+	assert.Error(err, "Users without two-factor authentication should not be found")
+	assert.NoError(store.SetTwoFactor(cah.TwoFactor{UserID: 1, Secret: "pending"}))
+	assert.NoError(store.SetTwoFactor(cah.TwoFactor{UserID: 1, Secret: "secret", Enabled: true, LastStep: 10}))
+	tf, err := store.TwoFactor(1)
+	assert.NoError(err)
+	assert.Equal(cah.TwoFactor{UserID: 1, Secret: "secret", Enabled: true, LastStep: 10}, tf)
+
+	assert.NoError(store.SetRecoveryCodes(1, "a", "b"))
+	assert.NoError(store.SetRecoveryCodes(2, "a"))
+	n, err := store.RecoveryCodes(1)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	used, err := store.UseRecoveryCode(1, "a")
+	assert.NoError(err)
+	assert.True(used)
+	used, err = store.UseRecoveryCode(1, "a")
+	assert.NoError(err)
+	assert.False(used, "Recovery codes should only be used once")
+	used, err = store.UseRecoveryCode(2, "a")
+	assert.NoError(err)
+	assert.True(used, "Recovery codes should belong to a user")
+
+	assert.NoError(store.DeleteTwoFactor(1))
+	_, err = store.TwoFactor(1)
+	assert.Error(err)
+	n, err = store.RecoveryCodes(1)
+	assert.NoError(err)
+	assert.Equal(0, n)
+}
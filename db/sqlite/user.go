@@ -1,6 +1,10 @@
 package sqlite
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/j4rv/cah"
 )
 
@@ -29,6 +33,124 @@ func (store *userStore) ByID(id int) (cah.User, error) {
 	return res, nil
 }
 
+func (store *userStore) SetLocale(userID int, locale string) error {
+	_, err := db.Exec(`UPDATE user SET locale = ? WHERE user = ?`, locale, userID)
+	return err
+}
+
+func (store *userStore) SetPassword(userID int, password string) error {
+	_, err := db.Exec(`UPDATE user SET password = ? WHERE user = ?`, password, userID)
+	return err
+}
+
+func (store *userStore) SetUsername(userID int, username string) error {
+	_, err := db.Exec(`UPDATE user SET username = ? WHERE user = ?`, username, userID)
+	return err
+}
+
+// userTables are the tables with rows that belong to a user, deleted along with them.
+// Card reports are kept, so moderators can still review them.
+var userTables = []string{"card_ban", "card_seen", "game_result", "user_card_win", "user_rating", "session",
+	"user_totp", "recovery_code", "api_token", "user_identity", "friendship", "user_seen"}
+
+// Delete removes the user and every row that belongs to them
+func (store *userStore) Delete(userID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, table := range userTables {
+		// Using Sprintf since table names are not user inputs
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE user = ?`, table), userID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	// The relations of other users with them too
+	if _, err := tx.Exec(`DELETE FROM friendship WHERE friend = ?`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM user WHERE user = ?`, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		return fmt.Errorf("No user found with ID %d", userID)
+	}
+	return tx.Commit()
+}
+
+func (store *userStore) CreateGuest(username, password string) (cah.User, error) {
+	var user cah.User
+	_, err := db.Exec(`INSERT INTO user (username, password, guest) VALUES (?, ?, 1)`,
+		username, password)
+	if err != nil {
+		return user, err
+	}
+	err = db.Get(&user, `SELECT * FROM user WHERE user = last_insert_rowid()`)
+	return user, err
+}
+
+// Upgrade turns a guest into a full user
+func (store *userStore) Upgrade(userID int, username, password string) error {
+	_, err := db.Exec(`UPDATE user SET username = ?, password = ?, guest = 0 WHERE user = ? AND guest = 1`,
+		username, password, userID)
+	return err
+}
+
+func (store *userStore) GuestSeen(userID int, at time.Time) error {
+	_, err := db.Exec(`INSERT INTO user_seen (user, seen_at) VALUES (?, ?)
+		ON CONFLICT(user) DO UPDATE SET seen_at = excluded.seen_at`, userID, at.Unix())
+	return err
+}
+
+func (store *userStore) Guests(seenBefore time.Time) ([]cah.User, error) {
+	res := []cah.User{}
+	// created_at is stored as text in UTC by CURRENT_TIMESTAMP
+	err := db.Select(&res, `SELECT user.* FROM user LEFT JOIN user_seen ON user_seen.user = user.user
+		WHERE user.guest = 1 AND user.created_at < ? AND (user_seen.seen_at IS NULL OR user_seen.seen_at < ?)`,
+		seenBefore.UTC().Format("2006-01-02 15:04:05"), seenBefore.Unix())
+	return res, err
+}
+
+func (store *userStore) SetRole(userID int, role cah.Role) error {
+	_, err := db.Exec(`UPDATE user SET role = ? WHERE user = ?`, role, userID)
+	return err
+}
+
+func (store *userStore) SetBanned(userID int, banned bool) error {
+	_, err := db.Exec(`UPDATE user SET banned = ? WHERE user = ?`, banned, userID)
+	return err
+}
+
+// List returns the users whose name contains the query, sorted by name
+func (store *userStore) List(q cah.UserQuery) (cah.UserList, error) {
+	res := cah.UserList{Users: []cah.User{}}
+	like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Query) + "%"
+	err := db.Get(&res.Total, `SELECT COUNT(*) FROM user WHERE username LIKE ? ESCAPE '\'`, like)
+	if err != nil {
+		return res, err
+	}
+	err = db.Select(&res.Users, `SELECT * FROM user WHERE username LIKE ? ESCAPE '\'
+		ORDER BY username LIMIT ? OFFSET ?`, like, q.Limit, q.Offset)
+	return res, err
+}
+
+func (store *userStore) LinkIdentity(userID int, issuer, subject string) error {
+	_, err := db.Exec(`INSERT INTO user_identity (user, issuer, subject) VALUES (?, ?, ?)`, userID, issuer, subject)
+	return err
+}
+
+func (store *userStore) ByIdentity(issuer, subject string) (cah.User, error) {
+	res := cah.User{}
+	err := db.Get(&res, `SELECT u.* FROM user u JOIN user_identity i ON i.user = u.user
+		WHERE i.issuer = ? AND i.subject = ?`, issuer, subject)
+	return res, err
+}
+
 func (store *userStore) ByName(name string) (cah.User, error) {
 	res := cah.User{}
 	if err := db.Get(&res, "SELECT * FROM user WHERE username = ?", name); err != nil {
@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"testing"
+	"time"
 
 	"github.com/j4rv/cah"
 )
@@ -105,3 +106,203 @@ func TestUserGetByName(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSetLocale(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	u, err := us.Create("Locale", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Locale != "" {
+		t.Fatalf("Expected an empty locale but got '%s'", u.Locale)
+	}
+	if err := us.SetLocale(u.ID, "es"); err != nil {
+		t.Fatal(err)
+	}
+	u, err = us.ByID(u.ID)
+	if err != nil || u.Locale != "es" {
+		t.Fatalf("Expected locale 'es' but got '%s', error: %v", u.Locale, err)
+	}
+}
+
+func TestAddColumnToOldTable(t *testing.T) {
+	InitDB(":memory:")
+	defer db.Close()
+	db.MustExec(`DROP TABLE user`)
+	db.MustExec(`CREATE TABLE user (user INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT UNIQUE, password TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`)
+	db.MustExec(`INSERT INTO user (username, password) VALUES ('Old', 'pass')`)
+	CreateTables()
+	u, err := NewUserStore().ByName("Old")
+	if err != nil || u.Locale != "" {
+		t.Fatalf("Expected the old user with an empty locale, got %+v, error: %v", u, err)
+	}
+}
+
+func TestUserSetUsernameAndPassword(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	u, err := us.Create("Name", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.Create("Taken", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.SetUsername(u.ID, "Taken"); err == nil {
+		t.Fatal("Expected an error when using a taken username")
+	}
+	if err := us.SetUsername(u.ID, "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX"); err == nil {
+		t.Fatal("Expected an error when using a username longer than 36 characters")
+	}
+	if err := us.SetUsername(u.ID, "New name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.SetPassword(u.ID, "new pass"); err != nil {
+		t.Fatal(err)
+	}
+	u, err = us.ByID(u.ID)
+	if err != nil || u.Username != "New name" || u.Password != "new pass" {
+		t.Fatalf("Expected the new username and password but got %+v, error: %v", u, err)
+	}
+}
+
+func TestUserDelete(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	u, err := us.Create("Deleted", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := us.Create("Kept", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bans := NewCardBanStore()
+	for _, id := range []int{u.ID, kept.ID} {
+		if err := bans.Ban(id, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := us.Delete(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.ByID(u.ID); err == nil {
+		t.Fatal("Expected the user to be deleted")
+	}
+	if banned, err := bans.Banned(u.ID); err != nil || len(banned) != 0 {
+		t.Fatalf("Expected the rows of the user to be deleted, got %v, error: %v", banned, err)
+	}
+	if banned, err := bans.Banned(kept.ID); err != nil || len(banned) != 1 {
+		t.Fatalf("Expected the rows of other users to be kept, got %v, error: %v", banned, err)
+	}
+	if err := us.Delete(u.ID); err == nil {
+		t.Fatal("Expected an error when deleting a user that does not exist")
+	}
+}
+
+func TestUserGuests(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	guest, err := us.CreateGuest("Guest", "guest")
+	if err != nil || !guest.Guest {
+		t.Fatalf("Expected a guest but got %+v, error: %v", guest, err)
+	}
+	if _, err := us.Create("Full", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	guests, err := us.Guests(time.Now().Add(-time.Hour))
+	if err != nil || len(guests) != 0 {
+		t.Fatalf("Expected no guests created before an hour ago, got %v, error: %v", guests, err)
+	}
+	guests, err = us.Guests(time.Now().Add(time.Hour))
+	if err != nil || len(guests) != 1 || guests[0].ID != guest.ID {
+		t.Fatalf("Expected only the guest, got %v, error: %v", guests, err)
+	}
+	if err := us.GuestSeen(guest.ID, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	guests, err = us.Guests(time.Now().Add(time.Hour))
+	if err != nil || len(guests) != 0 {
+		t.Fatalf("Expected no guests not seen since an hour from now, got %v, error: %v", guests, err)
+	}
+	if err := us.Upgrade(guest.ID, "Upgraded", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := us.ByID(guest.ID)
+	if err != nil || u.Guest || u.Username != "Upgraded" || u.Password != "pass" {
+		t.Fatalf("Expected the upgraded user but got %+v, error: %v", u, err)
+	}
+}
+
+func TestUserRoleAndBan(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	u, err := us.Create("Role", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Role != cah.RoleUser || u.Banned {
+		t.Fatalf("Expected a regular user but got %+v", u)
+	}
+	if err := us.SetRole(u.ID, "king"); err == nil {
+		t.Fatal("Expected an error when setting a role that does not exist")
+	}
+	if err := us.SetRole(u.ID, cah.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.SetBanned(u.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	u, err = us.ByID(u.ID)
+	if err != nil || u.Role != cah.RoleAdmin || !u.Banned {
+		t.Fatalf("Expected a banned admin but got %+v, error: %v", u, err)
+	}
+}
+
+func TestUserList(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	db.MustExec(`INSERT INTO user (username, password) VALUES
+		("first", "first"), ("second", "second"), ("third", "third"), ("50%", "pass")`)
+	list, err := us.List(cah.UserQuery{Query: "ir", Limit: 10})
+	if err != nil || list.Total != 2 || len(list.Users) != 2 {
+		t.Fatalf("Expected first and third, got %+v, error: %v", list, err)
+	}
+	list, err = us.List(cah.UserQuery{Query: "%", Limit: 10})
+	if err != nil || list.Total != 1 || list.Users[0].Username != "50%" {
+		t.Fatalf("Expected the wildcards to be escaped, got %+v, error: %v", list, err)
+	}
+	list, err = us.List(cah.UserQuery{Offset: 1, Limit: 2})
+	if err != nil || list.Total != 4 || len(list.Users) != 2 || list.Users[0].Username != "first" {
+		t.Fatalf("Expected the second page sorted by username, got %+v, error: %v", list, err)
+	}
+}
+
+func TestUserIdentity(t *testing.T) {
+	us, teardown := userTestSetup(t)
+	defer teardown()
+	u, err := us.Create("Identity", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.ByIdentity("https://sso.example.com", "1234"); err == nil {
+		t.Fatal("Expected an error before linking the identity")
+	}
+	if err := us.LinkIdentity(u.ID, "https://sso.example.com", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.LinkIdentity(u.ID+1, "https://sso.example.com", "1234"); err == nil {
+		t.Fatal("Expected an error when linking the identity twice")
+	}
+	found, err := us.ByIdentity("https://sso.example.com", "1234")
+	if err != nil || found.ID != u.ID || found.Username != "Identity" {
+		t.Fatalf("Expected the linked user but got %+v, error: %v", found, err)
+	}
+	if err := us.Delete(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.ByIdentity("https://sso.example.com", "1234"); err == nil {
+		t.Fatal("Expected the identity to be deleted along with the user")
+	}
+}
@@ -0,0 +1,64 @@
+package cah
+
+import (
+	"time"
+)
+
+// FriendStore keeps the relations between users. Relations have a direction:
+// a pending request goes from the requester to the requested user, friends have
+// an accepted relation in both directions and blocks go from the blocker.
+type FriendStore interface {
+	// Set creates the relation from one user to another, or replaces its status
+	Set(from, to int, status FriendStatus, at time.Time) error
+	Delete(from, to int) error
+	Get(from, to int) (Friendship, error)
+	// From returns the relations from the user to others
+	From(userID int) ([]Friendship, error)
+	// To returns the relations from others to the user
+	To(userID int) ([]Friendship, error)
+}
+
+type FriendUsecases interface {
+	// Request asks to be friends, accepting at once if the other user asked first
+	Request(u User, friendID int) error
+	Accept(u User, requesterID int) error
+	Decline(u User, requesterID int) error
+	// Remove ends a friendship or cancels a request sent by the user
+	Remove(u User, friendID int) error
+	// Block removes any relation with the other user and stops their requests
+	Block(u User, userID int) error
+	Unblock(u User, userID int) error
+	List(u User) (Friends, error)
+	AreFriends(userID, otherID int) bool
+}
+
+type FriendStatus string
+
+const (
+	FriendPending  FriendStatus = "pending"
+	FriendAccepted FriendStatus = "accepted"
+	FriendBlocked  FriendStatus = "blocked"
+)
+
+// Friendship is the relation from UserID to FriendID, Since is when it got its status
+type Friendship struct {
+	UserID   int          `db:"user"`
+	FriendID int          `db:"friend"`
+	Status   FriendStatus `db:"status"`
+	Since    time.Time    `db:"-"`
+}
+
+// Friends holds the relations of a user with others
+type Friends struct {
+	Friends []Friend `json:"friends"`
+	// Incoming are the requests other users sent to the user, Outgoing the ones the user sent
+	Incoming []Friend `json:"incoming"`
+	Outgoing []Friend `json:"outgoing"`
+	Blocked  []Friend `json:"blocked"`
+}
+
+type Friend struct {
+	UserID   int       `json:"userID"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
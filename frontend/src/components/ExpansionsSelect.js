@@ -90,7 +90,7 @@ class ExpansionsSelect extends Component {
     axios.get(availableExpansionsUrl).then(r =>
       this.setState({
         ...this.state,
-        expansions: r.data,
+        expansions: r.data.map(e => e.name),
       })
     )
   }
@@ -5,16 +5,19 @@ type GameStore interface {
 	ByID(int) (Game, error)
 	ByStatePhase(...Phase) []Game
 	Update(Game) error
+	Delete(id int) error
 }
 
 type GameUsecases interface {
 	Create(owner User, name, pass string) error
 	ByID(int) (Game, error)
 	AllOpen() []Game
+	All() []Game
 	InProgressForUser(User) []Game
 	UserJoins(User, Game) error
 	Start(Game, *GameState, ...Option) error
 	Options() GameOptions
+	RemoveUser(User) error
 	//Start(gameID int, options ...Option) error
 }
 
@@ -30,11 +33,19 @@ type Game struct {
 }
 
 type GameOptions interface {
+	DeckWeights(weights map[string]float64) GameOptions
+	Seeded(seed int64) GameOptions
+	Excluding(ids []int, tags []string) GameOptions
 	WhiteDeck([]*WhiteCard) Option
 	BlackDeck([]*BlackCard) Option
 	HandSize(size int) Option
 	RandomStartingCzar() Option
 	MaxRounds(max int) Option
+	IncludeWhites([]*WhiteCard) Option
+	IncludeBlacks([]*BlackCard) Option
+	ExcludeCards(ids ...int) Option
+	ExcludeTags(tags ...string) Option
+	PreferUnseen(seen ...int) Option
 }
 
 type Option func(s *GameState)
@@ -0,0 +1,48 @@
+package cah
+
+import (
+	"time"
+)
+
+// GameInviteStore keeps the invite links of the games
+type GameInviteStore interface {
+	Create(inv GameInvite) (GameInvite, error)
+	ByID(id int) (GameInvite, error)
+	ByGame(gameID int) []GameInvite
+	// Use counts a use of the invite, failing if it has no uses left
+	Use(id int) (GameInvite, error)
+	// Unuse gives back a use counted by Use
+	Unuse(id int) error
+	Revoke(id int) error
+	Delete(id int) error
+}
+
+type GameInviteUsecases interface {
+	// Create makes an invite link for the game. A zero expiresIn uses the default expiry
+	// and zero maxUses means unlimited uses.
+	Create(owner User, g Game, expiresIn time.Duration, maxUses int) (GameInvite, error)
+	// ByGame returns the invites of the game that can still be used
+	ByGame(owner User, g Game) ([]GameInvite, error)
+	// Check returns the invite of the token if it can be used, without using it
+	Check(token string) (GameInvite, error)
+	// Redeem returns the invite of the token and counts the use
+	Redeem(token string) (GameInvite, error)
+	// Unredeem gives back the use counted by Redeem, for when the user could not join the game
+	Unredeem(inv GameInvite) error
+	Revoke(owner User, g Game, inviteID int) error
+}
+
+// GameInvite lets anyone with its token join the game, without knowing its password.
+// The token is signed by the server, so it can not be forged for other games or expiry dates.
+type GameInvite struct {
+	ID     int `json:"id"`
+	GameID int `json:"gameID"`
+	// Token is filled by the usecases, it is not stored
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// MaxUses is zero for invites without a limit
+	MaxUses int  `json:"maxUses"`
+	Uses    int  `json:"uses"`
+	Revoked bool `json:"-"`
+}
@@ -29,6 +29,17 @@ type GameState struct {
 	HandSize        int          `json:"handSize" db:"handSize"`
 	CurrRound       int          `json:"-" db:"currRound"`
 	MaxRounds       int          `json:"-" db:"maxRounds"`
+	Rounds          []*Round     `json:"rounds" db:"rounds"`
+}
+
+// Round is a finished round, with the black card and the white cards that won it
+type Round struct {
+	Number     int          `json:"number"`
+	CzarID     int          `json:"czarID"`
+	WinnerID   int          `json:"winnerID"`
+	BlackCard  *BlackCard   `json:"blackCard"`
+	WhiteCards []*WhiteCard `json:"whiteCards"`
+	Sentence   string       `json:"sentence"`
 }
 
 func (s *GameState) DrawWhite() *WhiteCard {
@@ -93,10 +104,12 @@ func (s GameState) Clone() GameState {
 		BlackDeck:       make([]*BlackCard, len(s.BlackDeck)),
 		WhiteDeck:       make([]*WhiteCard, len(s.WhiteDeck)),
 		DiscardPile:     make([]*WhiteCard, len(s.DiscardPile)),
+		Rounds:          make([]*Round, len(s.Rounds)),
 	}
 	copy(res.Players, s.Players)
 	copy(res.BlackDeck, s.BlackDeck)
 	copy(res.WhiteDeck, s.WhiteDeck)
 	copy(res.DiscardPile, s.DiscardPile)
+	copy(res.Rounds, s.Rounds)
 	return res
 }
@@ -0,0 +1,154 @@
+// Package cardimg renders cards as images, in the classic black and white card style.
+// It uses the Go fonts, so it does not depend on the fonts installed in the server.
+package cardimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Card is a card to be rendered, its Text can contain line breaks
+type Card struct {
+	Text   string
+	Footer string
+	Black  bool
+}
+
+const (
+	cardWidth   = 300
+	cardHeight  = 420
+	margin      = 24
+	padding     = 28
+	border      = 3
+	footerSize  = 13
+	maxTextSize = 26
+	minTextSize = 14
+)
+
+var (
+	black = color.RGBA{0x10, 0x10, 0x10, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	gray  = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	bg    = color.RGBA{0xe8, 0xe8, 0xe8, 0xff}
+)
+
+var boldFont, regularFont *opentype.Font
+
+func init() {
+	var err error
+	if boldFont, err = opentype.Parse(gobold.TTF); err != nil {
+		panic(err)
+	}
+	if regularFont, err = opentype.Parse(goregular.TTF); err != nil {
+		panic(err)
+	}
+}
+
+// Render draws the cards in a row, the usual order is the black card followed by the white cards
+func Render(cards []Card) (*image.RGBA, error) {
+	width := margin + len(cards)*(cardWidth+margin)
+	height := cardHeight + 2*margin
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	for i, c := range cards {
+		x := margin + i*(cardWidth+margin)
+		if err := drawCard(img, image.Rect(x, margin, x+cardWidth, margin+cardHeight), c); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// WritePNG renders the cards and encodes them as a PNG image
+func WritePNG(w io.Writer, cards []Card) error {
+	img, err := Render(cards)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+func drawCard(img *image.RGBA, r image.Rectangle, c Card) error {
+	fg, fill := black, white
+	if c.Black {
+		fg, fill = white, black
+	}
+	draw.Draw(img, r, image.NewUniform(black), image.Point{}, draw.Src)
+	draw.Draw(img, r.Inset(border), image.NewUniform(fill), image.Point{}, draw.Src)
+
+	textWidth := r.Dx() - 2*padding
+	footerTop := r.Max.Y - padding - footerSize
+	// The biggest text size that fits in the card
+	var face font.Face
+	var lines []string
+	for size := maxTextSize; size >= minTextSize; size -= 2 {
+		f, err := newFace(boldFont, float64(size))
+		if err != nil {
+			return err
+		}
+		face, lines = f, wrap(f, c.Text, textWidth)
+		if len(lines)*lineHeight(f) <= footerTop-r.Min.Y-2*padding {
+			break
+		}
+	}
+	drawLines(img, face, fg, r.Min.X+padding, r.Min.Y+padding, lines)
+
+	if c.Footer != "" {
+		f, err := newFace(regularFont, footerSize)
+		if err != nil {
+			return err
+		}
+		drawLines(img, f, gray, r.Min.X+padding, footerTop, wrap(f, c.Footer, textWidth)[:1])
+	}
+	return nil
+}
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func lineHeight(face font.Face) int {
+	return face.Metrics().Height.Ceil()
+}
+
+// drawLines draws the lines with their top left corner at x, y
+func drawLines(img *image.RGBA, face font.Face, c color.Color, x, y int, lines []string) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+	ascent := face.Metrics().Ascent.Ceil()
+	for i, line := range lines {
+		d.Dot = fixed.P(x, y+ascent+i*lineHeight(face))
+		d.DrawString(line)
+	}
+}
+
+// wrap splits the text in lines no wider than width, breaking lines between words.
+// Words wider than width get a line of their own.
+func wrap(face font.Face, text string, width int) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
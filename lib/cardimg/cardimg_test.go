@@ -0,0 +1,41 @@
+package cardimg
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font"
+)
+
+func TestWrap(t *testing.T) {
+	face, err := newFace(boldFont, maxTextSize)
+	assert.NoError(t, err)
+	text := "In his newest and most difficult stunt, David Blaine must escape from\nthe Pope."
+	lines := wrap(face, text, 200)
+	assert.True(t, len(lines) > 2)
+	assert.Equal(t, "the Pope.", lines[len(lines)-1])
+	for _, l := range lines[:len(lines)-1] {
+		assert.True(t, font.MeasureString(face, l).Ceil() <= 200, l)
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	var buf bytes.Buffer
+	cards := []Card{
+		{Text: "_ would be woefully incomplete without _.", Footer: "Expansion 2", Black: true},
+		{Text: "The KKK.", Footer: "Base UK"},
+		{Text: "Being on fire.", Footer: "Base UK"},
+	}
+	assert.NoError(t, WritePNG(&buf, cards))
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, margin+3*(cardWidth+margin), img.Bounds().Dx())
+	assert.Equal(t, cardHeight+2*margin, img.Bounds().Dy())
+	// The black card is filled in black, the white ones in white
+	r, g, b, _ := img.At(margin+border+1, margin+cardHeight-border-1).RGBA()
+	assert.True(t, r < 0x2000 && g < 0x2000 && b < 0x2000)
+	r, g, b, _ = img.At(2*margin+cardWidth+border+1, margin+cardHeight-border-1).RGBA()
+	assert.True(t, r > 0xf000 && g > 0xf000 && b > 0xf000)
+}
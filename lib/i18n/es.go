@@ -0,0 +1,143 @@
+package i18n
+
+var es = map[string]string{
+	// Phases
+	"Not started":                 "Sin empezar",
+	"Sinners playing their cards": "Los pecadores juegan sus cartas",
+	"Czar is choosing winner":     "El Zar está eligiendo al ganador",
+	"Finished":                    "Terminada",
+
+	// Games
+	"A game name cannot be blank":                                       "El nombre de la partida no puede estar vacío",
+	"The minimum amount of players to start a game is 3, got: %d":       "Se necesitan al menos 3 jugadores para empezar una partida, hay %d",
+	"Only the game owner can start the game":                            "Solo el creador de la partida puede empezarla",
+	"Hand size needs to be a number between %d and %d (both included).": "El tamaño de la mano tiene que ser un número entre %d y %d (ambos incluidos).",
+	"The expansion '%s' needs a weight greater than zero":               "La expansión '%s' necesita un peso mayor que cero",
+	"The expansion '%s' has a weight but it was not selected":           "La expansión '%s' tiene un peso pero no está seleccionada",
+	"Not enough black cards to play a game. Please select more expansions. The amount of Black cards in selected expansions is %d, but the minimum is %d": "No hay suficientes cartas negras para jugar. Por favor, selecciona más expansiones. Las expansiones seleccionadas tienen %d cartas negras, pero el mínimo es %d",
+	"Not enough white cards to play a game. Please select more expansions. The amount of White cards in selected expansions is %d, but the minimum is %d": "No hay suficientes cartas blancas para jugar. Por favor, selecciona más expansiones. Las expansiones seleccionadas tienen %d cartas blancas, pero el mínimo es %d",
+	"Could not get game with id %d":                                   "No se encontró la partida con id %d",
+	"Misconstructed payload":                                          "Petición mal formada",
+	"Tried to start a game but it does not have any State":            "Se intentó empezar una partida sin estado",
+	"Tried to start a game but it already has a state. State ID '%d'": "Se intentó empezar una partida que ya tiene estado. ID del estado '%d'",
+	"Tried to end a game but it has already finished":                 "Se intentó terminar una partida que ya ha terminado",
+
+	// Game states
+	"Invalid amount of white cards to play, expected %d but got %d":               "Cantidad de cartas blancas no válida, se esperaban %d pero se recibieron %d",
+	"Not all sinners have played their cards":                                     "No todos los pecadores han jugado sus cartas",
+	"Non valid sinner index":                                                      "Pecador no válido",
+	"The Czar cannot play white cards":                                            "El Zar no puede jugar cartas blancas",
+	"You played your card(s) already":                                             "Ya has jugado tu(s) carta(s)",
+	"Only the Czar can choose the winner":                                         "Solo el Zar puede elegir al ganador",
+	"You are not playing this game":                                               "No estás jugando esta partida",
+	"The round %d has not finished yet":                                           "La ronda %d aún no ha terminado",
+	"Could not get game state from request. ID: %d":                               "No se encontró el estado de la partida con ID %d",
+	"The round needs to be a number":                                              "La ronda tiene que ser un número",
+	"Invalid winner id %d":                                                        "Ganador no válido con id %d",
+	"Tried to choose a winner in a non valid phase '%d'":                          "Se intentó elegir un ganador en una fase no válida '%d'",
+	"Tried to put a black card in play but there is already a black card in play": "Se intentó poner en juego una carta negra pero ya hay una en juego",
+	"Tried to put a black card in play but the game has already finished":         "Se intentó poner en juego una carta negra pero la partida ya ha terminado",
+	"Tried to rotate to the next Czar but there is still a black card in play":    "Se intentó pasar al siguiente Zar pero todavía hay una carta negra en juego",
+	"Tried to rotate to the next Czar but the game has already finished":          "Se intentó pasar al siguiente Zar pero la partida ya ha terminado",
+	"Non valid white card index: %d":                                              "Carta blanca no válida: %d",
+	"Index out of bounds. Index: %d, Hand size: %d":                               "Carta fuera de la mano. Índice: %d, tamaño de la mano: %d",
+
+	// Users
+	"Username cannot be empty.":                                           "El nombre de usuario no puede estar vacío.",
+	"Password cannot be empty.":                                           "La contraseña no puede estar vacía.",
+	"That username already exists. Please try another.":                   "Ese nombre de usuario ya existe. Por favor, prueba otro.",
+	"That password could not be protected correctly. Please try another.": "Esa contraseña no se ha podido proteger correctamente. Por favor, prueba otra.",
+	"The username or password you entered is incorrect.":                  "El nombre de usuario o la contraseña son incorrectos.",
+	"Too many failed login attempts. Please try again in %d minute(s).":   "Demasiados intentos fallidos. Por favor, inténtalo de nuevo en %d minuto(s).",
+	"The password you entered is incorrect.":                              "La contraseña es incorrecta.",
+	"The code you entered is incorrect.":                                  "El código es incorrecto.",
+	"Please log in with %s again to confirm it is you":                    "Por favor, vuelve a iniciar sesión con %s para confirmar que eres tú",
+	"Your login expired, please log in again.":                            "Tu inicio de sesión ha caducado, por favor, vuelve a entrar.",
+	"Two-factor authentication is already enabled":                        "La verificación en dos pasos ya está activada",
+	"Two-factor authentication is not enabled":                            "La verificación en dos pasos no está activada",
+	"Two-factor authentication needs to be enrolled first":                "Primero tienes que configurar la verificación en dos pasos",
+	"Guests cannot enable two-factor authentication":                      "Los invitados no pueden activar la verificación en dos pasos",
+	"Your account has been banned.":                                       "Tu cuenta ha sido bloqueada.",
+	"The role '%s' does not exist":                                        "El rol '%s' no existe",
+	"Guests do not have a password":                                       "Los invitados no tienen contraseña",
+	"You cannot change your own role or ban yourself":                     "No puedes cambiar tu propio rol ni bloquearte",
+	"Only started games can be ended":                                     "Solo se pueden terminar las partidas empezadas",
+	"Your API token is not valid or was revoked":                          "Tu token de API no es válido o ha sido revocado",
+	"Guests cannot create API tokens":                                     "Los invitados no pueden crear tokens de API",
+	"API tokens cannot create other tokens":                               "Los tokens de API no pueden crear otros tokens",
+	"The token name cannot be empty":                                      "El nombre del token no puede estar vacío",
+	"Token names cannot be longer than %d characters.":                    "Los nombres de los tokens no pueden tener más de %d caracteres.",
+	"You cannot have more than %d API tokens":                             "No puedes tener más de %d tokens de API",
+	"No API token found with ID %d":                                       "No se encontró el token de API con ID %d",
+	"Could not log in with %s, please try again.":                         "No se pudo iniciar sesión con %s, por favor, inténtalo de nuevo.",
+	"The external identity is not valid":                                  "La identidad externa no es válida",
+	"Guests cannot link their account":                                    "Los invitados no pueden vincular su cuenta",
+	"That account is already linked to another user":                      "Esa cuenta ya está vinculada a otro usuario",
+	"Only guests can upgrade their account":                               "Solo los invitados pueden mejorar su cuenta",
+	"Usernames cannot be longer than %d characters.":                      "Los nombres de usuario no pueden tener más de %d caracteres.",
+	"The language '%s' is not supported":                                  "El idioma '%s' no está disponible",
+	"No user found with ID %d":                                            "No se encontró el usuario con ID %d",
+	"Tried to get user from session without an id":                        "No has iniciado sesión",
+	"Session with non int id value":                                       "Sesión no válida",
+	"The user ID needs to be a number":                                    "El ID del usuario tiene que ser un número",
+	"The offset needs to be a number":                                     "El desplazamiento tiene que ser un número",
+	"The limit needs to be a number":                                      "El límite tiene que ser un número",
+	"Your session expired or was revoked":                                 "Tu sesión ha caducado o ha sido cerrada",
+	"Server side sessions are disabled":                                   "Las sesiones en el servidor están desactivadas",
+	"No session found with ID %d":                                         "No se encontró la sesión con ID %d",
+
+	// Friends
+	"Guests cannot have friends":                                  "Los invitados no pueden tener amigos",
+	"You cannot send a friend request to that user":               "No puedes enviar una solicitud de amistad a ese usuario",
+	"You are already friends":                                     "Ya sois amigos",
+	"You already sent a friend request to that user":              "Ya enviaste una solicitud de amistad a ese usuario",
+	"No friend request found from the user with ID %d":            "No hay ninguna solicitud de amistad del usuario con ID %d",
+	"No friend found with ID %d":                                  "No se encontró el amigo con ID %d",
+	"No blocked user found with ID %d":                            "No se encontró el usuario bloqueado con ID %d",
+	"You cannot have more than %d friends":                        "No puedes tener más de %d amigos",
+	"No user found with that username":                            "No se encontró ningún usuario con ese nombre",
+	"Only the game owner can invite friends":                      "Solo el creador de la partida puede invitar a amigos",
+	"You can only invite friends to games that did not start yet": "Solo puedes invitar a amigos a partidas que no han empezado",
+	"No invite found to the game with id %d":                      "No se encontró ninguna invitación a la partida con id %d",
+
+	// Game invite links
+	"Only the owner of the game can manage its invite links":                  "Solo el creador de la partida puede gestionar sus enlaces de invitación",
+	"You can only invite players to games that did not start yet":             "Solo puedes invitar a jugadores a partidas que no han empezado",
+	"Invite links must expire between one minute and %d days":                 "Los enlaces de invitación deben caducar entre un minuto y %d días",
+	"The maximum uses of an invite link must be between 0 (unlimited) and %d": "Los usos máximos de un enlace de invitación deben estar entre 0 (ilimitados) y %d",
+	"A game cannot have more than %d invite links":                            "Una partida no puede tener más de %d enlaces de invitación",
+	"No invite link found with ID %d":                                         "No se encontró el enlace de invitación con ID %d",
+	"This invite link is not valid":                                           "Este enlace de invitación no es válido",
+	"This invite link expired":                                                "Este enlace de invitación ha caducado",
+	"This invite link was revoked":                                            "Este enlace de invitación fue revocado",
+	"This invite link has no uses left":                                       "Este enlace de invitación no tiene usos restantes",
+	"This game already started":                                               "Esta partida ya ha empezado",
+
+	// Stats
+	"The leaderboard window '%s' is not valid": "El periodo de la clasificación '%s' no es válido",
+
+	// Cards
+	"Please write the reason of the report":           "Por favor, escribe el motivo del reporte",
+	"No card found with ID %d":                        "No se encontró la carta con ID %d",
+	"The card ID needs to be a number":                "El ID de la carta tiene que ser un número",
+	"The card type needs to be 'white' or 'black'":    "El tipo de carta tiene que ser 'white' o 'black'",
+	"The card with ID %d was disabled by a moderator": "La carta con ID %d fue desactivada por un moderador",
+	"Tags cannot be longer than %d":                   "Las etiquetas no pueden tener más de %d caracteres",
+	"The reason cannot be longer than %d":             "El motivo no puede tener más de %d caracteres",
+
+	// Expansions
+	"Missing the white cards file":                                     "Falta el archivo de las cartas blancas",
+	"Missing the black cards file":                                     "Falta el archivo de las cartas negras",
+	"The uploaded file is not a valid zip file":                        "El archivo subido no es un zip válido",
+	"The zip file needs to contain a 'white.md' and a 'black.md' file": "El zip tiene que contener un archivo 'white.md' y otro 'black.md'",
+	"The file %s cannot be bigger than %d bytes":                       "El archivo %s no puede tener más de %d bytes",
+	"The file %s cannot be empty":                                      "El archivo %s no puede estar vacío",
+	"The expansion name cannot be empty":                               "El nombre de la expansión no puede estar vacío",
+	"The expansion name cannot be longer than %d":                      "El nombre de la expansión no puede tener más de %d caracteres",
+	"The expansion name contains non valid characters":                 "El nombre de la expansión contiene caracteres no válidos",
+	"An expansion with that name already exists. Please try another.":  "Ya existe una expansión con ese nombre. Por favor, prueba otro.",
+	"Only the expansion owner or an admin can change it":               "Solo el creador de la expansión o un administrador pueden cambiarla",
+	"The expansion could not be stored":                                "No se pudo guardar la expansión",
+	"The expansion could not be renamed":                               "No se pudo renombrar la expansión",
+	"The expansion could not be deleted":                               "No se pudo borrar la expansión",
+}
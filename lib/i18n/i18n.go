@@ -0,0 +1,101 @@
+// Package i18n translates the server messages.
+// Messages are identified by their English text, so untranslated messages are shown in English.
+// Messages with arguments use their format as identifier, see Errorf.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language of the message identifiers
+const DefaultLanguage = "en"
+
+// catalogs maps a language to its translations, keyed by the English message
+var catalogs = map[string]map[string]string{
+	DefaultLanguage: {},
+	"es":            es,
+}
+
+// Languages returns the supported languages
+func Languages() []string {
+	ret := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		ret = append(ret, lang)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Supported reports if there is a catalog for the language
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// T translates the message, returning it unchanged if it has no translation
+func T(lang, msg string) string {
+	if tr, ok := catalogs[lang][msg]; ok {
+		return tr
+	}
+	return msg
+}
+
+// Sprintf translates the format and then formats it with the arguments
+func Sprintf(lang, format string, args ...interface{}) string {
+	return fmt.Sprintf(T(lang, format), args...)
+}
+
+// Error is an error whose message can be translated, see Errorf
+type Error struct {
+	Format string
+	Args   []interface{}
+}
+
+// Errorf works as fmt.Errorf, but the error keeps its format so it can be translated later
+func Errorf(format string, args ...interface{}) error {
+	return &Error{Format: format, Args: args}
+}
+
+// Error returns the message in the default language
+func (e *Error) Error() string {
+	return fmt.Sprintf(e.Format, e.Args...)
+}
+
+// TranslateError returns the message of the error in the language.
+// Errors not created with Errorf are translated by their whole message.
+func TranslateError(lang string, err error) string {
+	if e, ok := err.(*Error); ok {
+		return Sprintf(lang, e.Format, e.Args...)
+	}
+	return T(lang, err.Error())
+}
+
+// Match returns the supported language preferred by an Accept-Language header,
+// like "es-ES,es;q=0.9,en;q=0.8". Without any supported language, DefaultLanguage is returned.
+func Match(acceptLanguage string) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		// Only the primary subtag is used, "es-ES" is "es"
+		if i := strings.Index(lang, "-"); i != -1 {
+			lang = lang[:i]
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if Supported(lang) && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
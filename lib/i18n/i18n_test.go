@@ -0,0 +1,91 @@
+package i18n
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	cases := map[string]string{
+		"":                          "en",
+		"es":                        "es",
+		"es-ES,es;q=0.9,en;q=0.8":   "es",
+		"en-US,en;q=0.9,es;q=0.8":   "en",
+		"fr-FR,fr;q=0.9,es;q=0.5":   "es",
+		"fr-FR":                     "en",
+		"en;q=0.2, ES-mx;q=0.7, de": "es",
+	}
+	for header, expected := range cases {
+		assert.Equal(t, expected, Match(header), header)
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	assert := assert.New(t)
+	err := Errorf("Invalid amount of white cards to play, expected %d but got %d", 2, 1)
+	assert.Equal("Invalid amount of white cards to play, expected 2 but got 1", err.Error())
+	assert.Equal("Cantidad de cartas blancas no válida, se esperaban 2 pero se recibieron 1", TranslateError("es", err))
+	assert.Equal(err.Error(), TranslateError("en", err))
+	assert.Equal("El Zar no puede jugar cartas blancas", TranslateError("es", errors.New("The Czar cannot play white cards")))
+	assert.Equal("Untranslated", TranslateError("es", errors.New("Untranslated")))
+}
+
+// TestMessagesTranslated looks for the errors and messages of the packages that talk to the players,
+// checking that they have a Spanish translation.
+// Messages starting in lower case are internal, like the errors of the server configuration.
+func TestMessagesTranslated(t *testing.T) {
+	// messageArg is the argument with the message of each function
+	messageArg := map[string]int{"errors.New": 0, "fmt.Errorf": 0, "i18n.Errorf": 0, "i18n.T": 1, "i18n.Sprintf": 1}
+	for _, dir := range []string{"../..", "../../usecase", "../../server"} {
+		fset := token.NewFileSet()
+		pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range pkgs {
+			for _, f := range pkg.Files {
+				ast.Inspect(f, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					sel, ok := call.Fun.(*ast.SelectorExpr)
+					if !ok {
+						return true
+					}
+					pkgName, ok := sel.X.(*ast.Ident)
+					if !ok {
+						return true
+					}
+					arg, ok := messageArg[pkgName.Name+"."+sel.Sel.Name]
+					if !ok || len(call.Args) <= arg {
+						return true
+					}
+					lit, ok := call.Args[arg].(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						return true
+					}
+					msg, err := strconv.Unquote(lit.Value)
+					if err != nil || msg == "" || unicode.IsLower([]rune(msg)[0]) {
+						return true
+					}
+					if _, ok := es[msg]; !ok {
+						t.Errorf("%s: %q has no Spanish translation", fset.Position(lit.Pos()), msg)
+					}
+					return true
+				})
+			}
+		}
+	}
+}
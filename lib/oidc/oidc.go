@@ -0,0 +1,356 @@
+// Package oidc implements the login with an OpenID Connect provider, using the
+// authorization code flow with PKCE (RFC 7636). The provider is configured through
+// its discovery document, and the ID tokens need to be signed with RS256,
+// the algorithm every OpenID Connect provider supports.
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leeway is the clock difference allowed when checking the expiry of the ID tokens
+const Leeway = time.Minute
+
+// keysRefreshInterval limits how often the signing keys are downloaded again when
+// an ID token uses an unknown key, which happens when the provider rotates its keys
+const keysRefreshInterval = time.Minute
+
+type Config struct {
+	// Issuer is the URL of the provider, its discovery document is at Issuer/.well-known/openid-configuration
+	Issuer   string
+	ClientID string
+	// ClientSecret is optional, public clients only use PKCE
+	ClientSecret string
+	// RedirectURL is where the provider sends the users back, with the code to exchange
+	RedirectURL string
+	// Scopes are requested along with "openid"
+	Scopes []string
+}
+
+// Claims are the claims of an ID token used to identify the user
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// Provider is an OpenID Connect provider. It is safe for concurrent use.
+type Provider struct {
+	config        Config
+	client        *http.Client
+	authEndpoint  string
+	tokenEndpoint string
+	jwksURI       string
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Discover reads the discovery document of the provider. A nil client uses http.DefaultClient.
+func Discover(config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var doc discovery
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(client, wellKnown, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc: the provider issuer '%s' does not match the configured one '%s'", doc.Issuer, config.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc: the discovery document is missing endpoints")
+	}
+	return &Provider{
+		config:        config,
+		client:        client,
+		authEndpoint:  doc.AuthorizationEndpoint,
+		tokenEndpoint: doc.TokenEndpoint,
+		jwksURI:       doc.JWKSURI,
+		keys:          map[string]*rsa.PublicKey{},
+	}, nil
+}
+
+// RandomString returns a random URL safe string, to be used as state, nonce or PKCE verifier
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge returns the S256 PKCE challenge of the verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL of the provider where users log in.
+// The state, nonce and verifier need to be kept until the user comes back.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.authEndpoint, "?") {
+		sep = "&"
+	}
+	return p.authEndpoint + sep + v.Encode()
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades the code for an ID token and returns its claims,
+// once its signature, issuer, audience, expiry and nonce are checked
+func (p *Provider) Exchange(code, verifier, nonce string) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest("POST", p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		// client_secret_basic, the default client authentication of OpenID Connect
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer res.Body.Close()
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&tr); err != nil {
+		return Claims{}, fmt.Errorf("oidc: could not read the token response: %s", err)
+	}
+	if tr.Error != "" {
+		return Claims{}, fmt.Errorf("oidc: the provider refused the code: %s %s", tr.Error, tr.ErrorDescription)
+	}
+	if res.StatusCode != http.StatusOK || tr.IDToken == "" {
+		return Claims{}, fmt.Errorf("oidc: the token response has no ID token, status %d", res.StatusCode)
+	}
+	claims, err := p.Verify(tr.IDToken, time.Now())
+	if err != nil {
+		return claims, err
+	}
+	if claims.Nonce != nonce {
+		return Claims{}, errors.New("oidc: the ID token nonce does not match")
+	}
+	return claims, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature, issuer, audience and expiry of the ID token and returns its claims
+func (p *Provider) Verify(idToken string, now time.Time) (Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("oidc: malformed ID token")
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, err
+	}
+	if h.Alg != "RS256" {
+		return Claims{}, fmt.Errorf("oidc: unsupported ID token algorithm '%s'", h.Alg)
+	}
+	key, err := p.key(h.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, errors.New("oidc: malformed ID token signature")
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig); err != nil {
+		return Claims{}, errors.New("oidc: invalid ID token signature")
+	}
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Claims{}, err
+	}
+	if c.Issuer != p.config.Issuer {
+		return Claims{}, fmt.Errorf("oidc: unexpected ID token issuer '%s'", c.Issuer)
+	}
+	if !c.Audience.contains(p.config.ClientID) {
+		return Claims{}, errors.New("oidc: the ID token was not issued for this client")
+	}
+	if len(c.Audience) > 1 && c.AuthorizedParty != p.config.ClientID {
+		return Claims{}, errors.New("oidc: the ID token was authorized for another client")
+	}
+	if now.Add(-Leeway).Unix() >= c.Expiry {
+		return Claims{}, errors.New("oidc: the ID token expired")
+	}
+	if c.Subject == "" {
+		return Claims{}, errors.New("oidc: the ID token has no subject")
+	}
+	return c, nil
+}
+
+// key returns the signing key with the ID, downloading the keys again if it is unknown
+func (p *Provider) key(kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k := p.findKey(kid); k != nil {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("oidc: unknown signing key '%s'", kid)
+	}
+	keys, err := fetchKeys(p.client, p.jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+	if k := p.findKey(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key '%s'", kid)
+}
+
+// findKey returns the key with the ID. Tokens without a key ID can only use a provider with a single key.
+func (p *Provider) findKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+	return p.keys[kid]
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// fetchKeys downloads the RSA signing keys of the provider, ignoring the rest
+func fetchKeys(client *http.Client, uri string) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := getJSON(client, uri, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// audience is the aud claim, which can be a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("oidc: malformed ID token")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.New("oidc: malformed ID token")
+	}
+	return nil
+}
+
+func getJSON(client *http.Client, uri string, v interface{}) error {
+	res, err := client.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned status %d", uri, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
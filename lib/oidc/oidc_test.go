@@ -0,0 +1,135 @@
+package oidc_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah/lib/oidc"
+	"github.com/j4rv/cah/lib/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+const clientID = "cah"
+const redirectURL = "http://localhost/api/user/oidc/callback"
+
+func setup(t *testing.T) (*oidc.Provider, *oidctest.Provider, func()) {
+	srv, mock, err := oidctest.NewServer(clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := oidc.Discover(oidc.Config{Issuer: mock.Issuer, ClientID: clientID, RedirectURL: redirectURL}, nil)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return p, mock, srv.Close
+}
+
+// authorize follows the login in the provider and returns the code and state sent back to the client
+func authorize(t *testing.T, authURL string) (code, state string) {
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	loc, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), redirectURL) {
+		t.Fatalf("Expected a redirect to the client, got '%s' with status %d", loc, res.StatusCode)
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestChallenge(t *testing.T) {
+	// The S256 challenge is the base64url SHA-256 of the verifier, without padding
+	assert.Equal(t, "L-ff0P9_cuaPezogO0jjXgu_IiBG9ht0_vI8UjjDahs", oidc.Challenge("dBjftJeZ4CVP-mJ92K9JBsf_lZz3rfs47FSAjYQ2Lq0"))
+}
+
+func TestLogin(t *testing.T) {
+	assert := assert.New(t)
+	p, _, teardown := setup(t)
+	defer teardown()
+
+	verifier, _ := oidc.RandomString()
+	code, state := authorize(t, p.AuthCodeURL("the state", "the nonce", verifier)+"&login_hint=alice")
+	assert.Equal("the state", state)
+
+	_, err := p.Exchange(code, "wrong verifier", "the nonce")
+	assert.Error(err, "The PKCE verifier should be checked")
+
+	code, _ = authorize(t, p.AuthCodeURL("the state", "the nonce", verifier)+"&login_hint=alice")
+	claims, err := p.Exchange(code, verifier, "the nonce")
+	assert.NoError(err)
+	assert.Equal("alice", claims.Subject)
+	assert.Equal("alice", claims.PreferredUsername)
+	_, err = p.Exchange(code, verifier, "the nonce")
+	assert.Error(err, "Codes should only be used once")
+
+	code, _ = authorize(t, p.AuthCodeURL("the state", "the nonce", verifier))
+	_, err = p.Exchange(code, verifier, "another nonce")
+	assert.Error(err, "The nonce should be checked")
+}
+
+func TestVerify(t *testing.T) {
+	p, mock, teardown := setup(t)
+	defer teardown()
+	now := time.Now()
+	cases := []struct {
+		name   string
+		claims oidc.Claims
+		valid  bool
+	}{
+		{"valid", oidc.Claims{Subject: "alice"}, true},
+		{"no subject", oidc.Claims{}, false},
+		{"other issuer", oidc.Claims{Subject: "alice", Issuer: "https://evil.example.com"}, false},
+		{"other audience", oidc.Claims{Subject: "alice", Audience: []string{"other"}}, false},
+		{"many audiences", oidc.Claims{Subject: "alice", Audience: []string{"other", clientID}}, false},
+		{"many audiences authorized", oidc.Claims{Subject: "alice", Audience: []string{"other", clientID}, AuthorizedParty: clientID}, true},
+		{"expired", oidc.Claims{Subject: "alice", Expiry: now.Add(-2 * oidc.Leeway).Unix()}, false},
+		{"expired within the leeway", oidc.Claims{Subject: "alice", Expiry: now.Add(-oidc.Leeway / 2).Unix()}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := mock.IDToken(tc.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = p.Verify(token, now)
+			assert.Equal(t, tc.valid, err == nil, "error: %v", err)
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	assert := assert.New(t)
+	p, mock, teardown := setup(t)
+	defer teardown()
+	token, err := mock.IDToken(oidc.Claims{Subject: "alice"})
+	assert.NoError(err)
+	parts := strings.Split(token, ".")
+
+	other, err := mock.IDToken(oidc.Claims{Subject: "mallory"})
+	assert.NoError(err)
+	tampered := strings.Split(other, ".")[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+	_, err = p.Verify(tampered, time.Now())
+	assert.Error(err, "The signature of other claims should not be valid")
+
+	unsigned := "eyJhbGciOiJub25lIn0." + parts[1] + "."
+	_, err = p.Verify(unsigned, time.Now())
+	assert.Error(err, "Unsigned tokens should not be valid")
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	srv, mock, err := oidctest.NewServer(clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	_, err = oidc.Discover(oidc.Config{Issuer: mock.Issuer + "/other", ClientID: clientID}, nil)
+	assert.Error(t, err)
+}
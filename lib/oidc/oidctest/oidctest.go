@@ -0,0 +1,213 @@
+// Package oidctest is a minimal OpenID Connect provider to try and test the login
+// without a real one. It approves every authorization request at once, logging in
+// as the user of the login_hint parameter, or as the default user without it.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/j4rv/cah/lib/oidc"
+)
+
+const keyID = "oidctest"
+
+// DefaultUser is the subject of the users logging in without a login_hint
+const DefaultUser = "mock-user"
+
+// Provider implements the discovery, authorization, token and keys endpoints.
+// Issuer needs to be the URL the provider is served from.
+type Provider struct {
+	Issuer   string
+	ClientID string
+	// ClientSecret is checked if it is not empty
+	ClientSecret string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+type authorization struct {
+	subject     string
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+func New(issuer, clientID string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{Issuer: issuer, ClientID: clientID, key: key, codes: map[string]authorization{}}, nil
+}
+
+// NewServer starts a provider on a local port, close it when done
+func NewServer(clientID string) (*httptest.Server, *Provider, error) {
+	p, err := New("", clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := httptest.NewServer(p)
+	p.Issuer = srv.URL
+	return srv, p, nil
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.Issuer,
+			"authorization_endpoint":                p.Issuer + "/authorize",
+			"token_endpoint":                        p.Issuer + "/token",
+			"jwks_uri":                              p.Issuer + "/keys",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/authorize":
+		p.authorize(w, req)
+	case "/token":
+		p.token(w, req)
+	case "/keys":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": keyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			}},
+		})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// authorize redirects back to the client with a code, as if the user logged in
+func (p *Provider) authorize(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != p.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	subject := q.Get("login_hint")
+	if subject == "" {
+		subject = DefaultUser
+	}
+	code, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		subject:     subject,
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	p.mu.Unlock()
+	v := redirectURI.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	redirectURI.RawQuery = v.Encode()
+	http.Redirect(w, req, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code once, checking the PKCE verifier
+func (p *Provider) token(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	clientID, secret, hasBasic := req.BasicAuth()
+	if !hasBasic {
+		clientID = req.Form.Get("client_id")
+	}
+	if clientID != p.ClientID || (p.ClientSecret != "" && secret != p.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	p.mu.Lock()
+	auth, ok := p.codes[req.Form.Get("code")]
+	delete(p.codes, req.Form.Get("code"))
+	p.mu.Unlock()
+	if !ok || req.Form.Get("grant_type") != "authorization_code" || req.Form.Get("redirect_uri") != auth.redirectURI ||
+		oidc.Challenge(req.Form.Get("code_verifier")) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	idToken, err := p.IDToken(oidc.Claims{
+		Subject:           auth.subject,
+		Nonce:             auth.nonce,
+		PreferredUsername: auth.subject,
+		Email:             auth.subject + "@example.com",
+		EmailVerified:     true,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// IDToken signs an ID token with the claims. The issuer, audience and times
+// are filled in if they are empty.
+func (p *Provider) IDToken(c oidc.Claims) (string, error) {
+	now := time.Now()
+	if c.Issuer == "" {
+		c.Issuer = p.Issuer
+	}
+	if len(c.Audience) == 0 {
+		c.Audience = []string{p.ClientID}
+	}
+	if c.IssuedAt == 0 {
+		c.IssuedAt = now.Unix()
+	}
+	if c.Expiry == 0 {
+		c.Expiry = now.Add(time.Hour).Unix()
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,97 @@
+// Package throttle slows down repeated failures, like wrong passwords, with an exponential backoff.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter counts the failures of each key, like a username or an IP.
+// After Free failures, every new failure doubles the time to wait before trying again,
+// starting at Base and up to Max, which works as a temporary lockout.
+// Keys without failures during Max are forgotten. It is safe for concurrent use.
+type Limiter struct {
+	Free int
+	Base time.Duration
+	Max  time.Duration
+
+	mu   sync.Mutex
+	keys map[string]*entry
+	// forgotAt is the last time old keys were forgotten
+	forgotAt time.Time
+}
+
+type entry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+func New(free int, base, max time.Duration) *Limiter {
+	return &Limiter{Free: free, Base: base, Max: max, keys: map[string]*entry{}}
+}
+
+// Wait returns how long the key has to wait before trying again, zero if it can try now
+func (l *Limiter) Wait(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.keys[key]
+	if !ok || !now.Before(e.until) {
+		return 0
+	}
+	return e.until.Sub(now)
+}
+
+// Fail records a failure of the key and returns how long it has to wait now
+func (l *Limiter) Fail(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.forgotAt) > l.Max {
+		l.forget(now)
+	}
+	e, ok := l.keys[key]
+	if !ok {
+		e = &entry{}
+		l.keys[key] = e
+	}
+	e.failures++
+	e.last = now
+	if e.failures <= l.Free {
+		return 0
+	}
+	wait := l.Max
+	// Shifting more than the bits of a Duration would overflow
+	if exp := uint(e.failures - l.Free - 1); exp < 32 && l.Base<<exp < l.Max {
+		wait = l.Base << exp
+	}
+	e.until = now.Add(wait)
+	return wait
+}
+
+// Failures returns the recent failures of the key
+func (l *Limiter) Failures(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.keys[key]; ok {
+		return e.failures
+	}
+	return 0
+}
+
+// Reset forgets the failures of the key, like after a successful login
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.keys, key)
+}
+
+// forget removes the keys that did not fail in a while, so the map does not keep growing.
+// Fail only calls it once every Max, since it goes through all the keys.
+func (l *Limiter) forget(now time.Time) {
+	l.forgotAt = now
+	for k, e := range l.keys {
+		if now.Sub(e.last) > l.Max && !now.Before(e.until) {
+			delete(l.keys, k)
+		}
+	}
+}
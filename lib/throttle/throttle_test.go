@@ -0,0 +1,46 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+	l := New(3, time.Second, 10*time.Second)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(time.Duration(0), l.Fail("user", now), "The first failures should be free")
+	}
+	assert.Equal(time.Duration(0), l.Wait("user", now))
+	assert.Equal(time.Second, l.Fail("user", now))
+	assert.Equal(time.Second, l.Wait("user", now))
+	assert.Equal(500*time.Millisecond, l.Wait("user", now.Add(500*time.Millisecond)))
+	assert.Equal(time.Duration(0), l.Wait("user", now.Add(time.Second)))
+	assert.Equal(2*time.Second, l.Fail("user", now))
+	assert.Equal(4*time.Second, l.Fail("user", now))
+	assert.Equal(8*time.Second, l.Fail("user", now))
+	assert.Equal(10*time.Second, l.Fail("user", now), "The wait should not be longer than the max")
+	for i := 0; i < 100; i++ {
+		l.Fail("user", now)
+	}
+	assert.Equal(10*time.Second, l.Wait("user", now), "Many failures should not overflow")
+	assert.Equal(time.Duration(0), l.Wait("other", now), "Keys should not affect each other")
+
+	l.Reset("user")
+	assert.Equal(time.Duration(0), l.Wait("user", now))
+	assert.Equal(0, l.Failures("user"))
+}
+
+func TestForget(t *testing.T) {
+	assert := assert.New(t)
+	l := New(1, time.Second, time.Minute)
+	now := time.Now()
+	l.Fail("old", now)
+	l.Fail("other", now.Add(2*time.Minute))
+	assert.Equal(0, l.Failures("old"), "Keys without failures during the max wait should be forgotten")
+	assert.Equal(1, l.Failures("other"))
+}
@@ -0,0 +1,60 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The SHA1 test vectors of RFC 6238, appendix B
+func TestRFC6238Vectors(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, expected := range vectors {
+		step := Step(time.Unix(unix, 0))
+		assert.Equal(expected, code(key, uint64(step), 8), "time %d", unix)
+	}
+	secret := base32.StdEncoding.EncodeToString(key)
+	c, err := Code(secret, time.Unix(59, 0))
+	assert.NoError(err)
+	assert.Equal("287082", c, "Six digits codes are the last digits of the eight digits ones")
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	secret, err := GenerateSecret()
+	assert.NoError(err)
+	now := time.Now()
+	c, err := Code(secret, now)
+	assert.NoError(err)
+
+	step, ok := Validate(secret, c, now)
+	assert.True(ok)
+	assert.Equal(Step(now), step)
+	_, ok = Validate(secret, c, now.Add(Period))
+	assert.True(ok, "Codes from the previous step should be accepted")
+	_, ok = Validate(secret, c, now.Add(3*Period))
+	assert.False(ok, "Old codes should not be accepted")
+	_, ok = Validate(secret, "12345", now)
+	assert.False(ok)
+	_, ok = Validate("not base32!", c, now)
+	assert.False(ok)
+	_, ok = Validate(strings.ToLower(secret), c, now)
+	assert.True(ok, "Secrets should not be case sensitive")
+}
+
+func TestURI(t *testing.T) {
+	assert.Equal(t, "otpauth://totp/J4RV%27s%20CAH:Red?algorithm=SHA1&digits=6&issuer=J4RV%27s+CAH&period=30&secret=ABC",
+		URI("J4RV's CAH", "Red", "ABC"))
+}
@@ -0,0 +1,26 @@
+// Command oidc_mock runs a local OpenID Connect provider to try the login without a real one.
+// Every login is approved at once, as the user of the login_hint parameter or as "mock-user".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/j4rv/cah/lib/oidc/oidctest"
+)
+
+func main() {
+	port := flag.Int("port", 9000, "port to serve the provider")
+	clientID := flag.String("client-id", "cah", "client ID accepted by the provider")
+	flag.Parse()
+
+	issuer := fmt.Sprintf("http://localhost:%d", *port)
+	p, err := oidctest.New(issuer, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Mock OpenID Connect provider running, use OIDC_ISSUER=%s OIDC_CLIENT_ID=%s", issuer, *clientID)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), p))
+}
@@ -1,9 +1,9 @@
 package cah
 
 import (
-	"errors"
-	"fmt"
 	"sort"
+
+	"github.com/j4rv/cah/lib/i18n"
 )
 
 type Player struct {
@@ -24,8 +24,7 @@ func NewPlayer(u User) *Player {
 
 func (p *Player) RemoveCardFromHand(i int) error {
 	if i < 0 || i >= len(p.Hand) {
-		msg := fmt.Sprintf("Index out of bounds. Index: %d, Hand size: %d", i, len(p.Hand))
-		return errors.New(msg)
+		return i18n.Errorf("Index out of bounds. Index: %d, Hand size: %d", i, len(p.Hand))
 	}
 	p.Hand = append(p.Hand[:i], p.Hand[i+1:]...)
 	return nil
@@ -36,7 +35,7 @@ func (p *Player) ExtractCardsFromHand(indexes []int) ([]*WhiteCard, error) {
 
 	for iter, index := range indexes {
 		if index < 0 || index >= len(p.Hand) {
-			return nil, fmt.Errorf("Non valid white card index: %d", index)
+			return nil, i18n.Errorf("Non valid white card index: %d", index)
 		}
 		c := p.Hand[index]
 		ret[iter] = c
@@ -0,0 +1,97 @@
+package cah
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// blank is the placeholder for white cards in the black card texts
+const blank = "_"
+
+// lowerCaseStarters are the first words of white cards that are lower cased when
+// the card fills a blank in the middle of a sentence. Other words keep their case,
+// since they may be names.
+var lowerCaseStarters = map[string]bool{
+	"a": true, "an": true, "the": true, "some": true, "my": true, "your": true,
+	"his": true, "her": true, "our": true, "their": true, "this": true, "that": true,
+	"these": true, "those": true, "being": true, "getting": true, "having": true,
+	"un": true, "una": true, "unos": true, "unas": true, "el": true, "la": true,
+	"los": true, "las": true, "mi": true, "mis": true, "tu": true, "tus": true, "su": true, "sus": true,
+}
+
+// ComposeSentence fills the blanks of the black card with the white cards, in order.
+// The "\n" escapes of the card files become line breaks.
+// Answers starting a sentence are capitalized, and the trailing period of an answer
+// is dropped unless it ends the sentence.
+// Black cards without blanks, and white cards left after filling every blank,
+// get the answers appended at the end.
+func ComposeSentence(b *BlackCard, whites []*WhiteCard) string {
+	text := UnescapeLineBreaks(b.Text)
+	var sb strings.Builder
+	i := 0
+	for {
+		pos := strings.Index(text, blank)
+		if pos == -1 || i == len(whites) {
+			break
+		}
+		before, after := text[:pos], text[pos+len(blank):]
+		sb.WriteString(before)
+		answer := UnescapeLineBreaks(whites[i].Text)
+		if startsSentence(sb.String()) {
+			answer = upperFirst(answer)
+		} else {
+			answer = lowerFirstWord(answer)
+		}
+		if strings.TrimSpace(after) != "" {
+			answer = strings.TrimSuffix(answer, ".")
+		}
+		sb.WriteString(answer)
+		text = after
+		i++
+	}
+	sb.WriteString(text)
+	for ; i < len(whites); i++ {
+		if sb.Len() != 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(upperFirst(UnescapeLineBreaks(whites[i].Text)))
+	}
+	return sb.String()
+}
+
+// UnescapeLineBreaks turns the "\n" escapes of the card files into line breaks
+func UnescapeLineBreaks(text string) string {
+	return strings.Replace(text, `\n`, "\n", -1)
+}
+
+// startsSentence reports if the text written so far ends a sentence
+func startsSentence(written string) bool {
+	trimmed := strings.TrimRightFunc(written, func(r rune) bool {
+		return r == ' ' || r == '"' || r == '\'' || r == '¡' || r == '¿'
+	})
+	if trimmed == "" {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(trimmed)
+	return last == '.' || last == '?' || last == '!' || last == '\n' || last == ':'
+}
+
+func upperFirst(text string) string {
+	r, size := utf8.DecodeRuneInString(text)
+	if size == 0 {
+		return text
+	}
+	return string(unicode.ToUpper(r)) + text[size:]
+}
+
+func lowerFirstWord(text string) string {
+	firstWord := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(firstWord) == 0 || !lowerCaseStarters[strings.ToLower(firstWord[0])] {
+		return text
+	}
+	r, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToLower(r)) + text[size:]
+}
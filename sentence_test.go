@@ -0,0 +1,44 @@
+package cah
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposeSentence(t *testing.T) {
+	whites := func(texts ...string) []*WhiteCard {
+		ret := make([]*WhiteCard, len(texts))
+		for i, t := range texts {
+			ret[i] = &WhiteCard{Text: t}
+		}
+		return ret
+	}
+	tests := []struct {
+		black  string
+		whites []*WhiteCard
+		want   string
+	}{
+		{"I got 99 problems but _ ain't one.", whites("A bag of magic beans."),
+			"I got 99 problems but a bag of magic beans ain't one."},
+		{"Maybe she's born with it. Maybe it's _.", whites("Barack Obama."),
+			"Maybe she's born with it. Maybe it's Barack Obama."},
+		{"_ would be woefully incomplete without _.", whites("the KKK.", "Being on fire."),
+			"The KKK would be woefully incomplete without being on fire."},
+		{"What's that smell?", whites("Being on fire."),
+			"What's that smell? Being on fire."},
+		{"The time has come to commence Operation _", whites("Puppies!"),
+			"The time has come to commence Operation Puppies!"},
+		{`She's up all night for good fun.\nI'm up all night for _.`, whites("A sausage festival."),
+			"She's up all night for good fun.\nI'm up all night for a sausage festival."},
+		{"My new favorite porn star is Joey \"_\" McGee.", whites("The Pope."),
+			"My new favorite porn star is Joey \"the Pope\" McGee."},
+		{"Step 1: _. Step 2: _.", whites("Daddy issues."),
+			"Step 1: Daddy issues. Step 2: _."},
+		{"_ is a slippery slope that leads to _.", whites("A sad handjob.", "Un pingüino.", "Extra card."),
+			"A sad handjob is a slippery slope that leads to un pingüino. Extra card."},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ComposeSentence(&BlackCard{Text: tt.black}, tt.whites), tt.black)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+// adminOnly protects the admin API, only admins get past it
+func adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u, err := userFromSession(w, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if !isAdmin(u) {
+			log.Printf("SECURITY User %s with id %d tried to use the admin API: %s", u.Username, u.ID, req.URL.Path)
+			http.Error(w, "Only admins can use the admin API", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+/*
+USERS
+*/
+
+// adminUsers expects the optional query parameters query, offset and limit
+func adminUsers(w http.ResponseWriter, req *http.Request) error {
+	params := req.URL.Query()
+	q := cah.UserQuery{Query: params.Get("query")}
+	var err error
+	if q.Offset, err = intParam(params.Get("offset")); err != nil {
+		return errors.New("The offset needs to be a number")
+	}
+	if q.Limit, err = intParam(params.Get("limit")); err != nil {
+		return errors.New("The limit needs to be a number")
+	}
+	users, err := usecase.User.List(q)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, users)
+	return nil
+}
+
+type setRolePayload struct {
+	Role cah.Role `json:"role"`
+}
+
+func adminSetRole(w http.ResponseWriter, req *http.Request) error {
+	admin, target, err := adminTarget(w, req)
+	if err != nil {
+		return err
+	}
+	// Decode user's payload
+	var payload setRolePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	log.Printf("Admin %s sets the role of user %s with id %d to %s", admin.Username, target.Username, target.ID, payload.Role)
+	return usecase.User.SetRole(target.ID, payload.Role)
+}
+
+type resetPasswordResponse struct {
+	Password string `json:"password"`
+}
+
+// adminResetPassword sets a random password for the user and returns it,
+// so the admin can send it to the user. The user is logged out everywhere.
+func adminResetPassword(w http.ResponseWriter, req *http.Request) error {
+	admin, target, err := adminTarget(w, req)
+	if err != nil {
+		return err
+	}
+	pass, err := usecase.User.ResetPassword(target.ID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Admin %s reset the password of user %s with id %d", admin.Username, target.Username, target.ID)
+	revokeOtherLogins(target.ID, 0, 0)
+	writeResponse(w, resetPasswordResponse{Password: pass})
+	return nil
+}
+
+type banPayload struct {
+	Banned bool `json:"banned"`
+}
+
+// adminBan bans or unbans the user, banning also logs them out
+func adminBan(w http.ResponseWriter, req *http.Request) error {
+	admin, target, err := adminTarget(w, req)
+	if err != nil {
+		return err
+	}
+	// Decode user's payload
+	var payload banPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	log.Printf("Admin %s sets banned to %t for user %s with id %d", admin.Username, payload.Banned, target.Username, target.ID)
+	if err := usecase.User.Ban(target.ID, payload.Banned); err != nil {
+		return err
+	}
+	if payload.Banned && serverSessions() {
+		return usecase.Session.RevokeAll(target.ID)
+	}
+	return nil
+}
+
+/*
+GAMES
+*/
+
+func adminGames(w http.ResponseWriter, req *http.Request) error {
+	games := usecase.Game.All()
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].ID < games[j].ID
+	})
+	lang := requestLanguage(w, req)
+	response := make([]gameRoomResponse, len(games))
+	for i, g := range games {
+		response[i] = gameToResponse(g, lang)
+	}
+	writeResponse(w, response)
+	return nil
+}
+
+// adminEndGame finishes a started game, like one where the players left
+func adminEndGame(w http.ResponseWriter, req *http.Request) error {
+	admin, err := userFromSession(w, req)
+	if err != nil {
+		return err
+	}
+	g, err := gameFromRequest(req)
+	if err != nil {
+		return err
+	}
+	if g.State == nil || g.State.ID == 0 {
+		return errors.New("Only started games can be ended")
+	}
+	log.Printf("Admin %s ends the game '%s' with id %d", admin.Username, g.Name, g.ID)
+	if err := usecase.GameState.End(g.State); err != nil {
+		return err
+	}
+	gameStateUpdated(g.State)
+	return nil
+}
+
+/*
+EXPANSIONS
+*/
+
+// adminExpansions lists every expansion, even the ones hidden by the exclude-tags flag
+func adminExpansions(w http.ResponseWriter, req *http.Request) error {
+	exps := usecase.Card.Expansions()
+	sort.Slice(exps, func(i, j int) bool {
+		return exps[i].Name < exps[j].Name
+	})
+	writeResponse(w, exps)
+	return nil
+}
+
+// Utils
+
+// adminTarget returns the logged admin and the user of the request path.
+// Admins can not change themselves, so they can not lock themselves out by mistake.
+func adminTarget(w http.ResponseWriter, req *http.Request) (cah.User, cah.User, error) {
+	admin, err := userFromSession(w, req)
+	if err != nil {
+		return admin, cah.User{}, err
+	}
+	id, err := strconv.Atoi(mux.Vars(req)["userID"])
+	if err != nil {
+		return admin, cah.User{}, errors.New("The user ID needs to be a number")
+	}
+	target, ok := usecase.User.ByID(id)
+	if !ok {
+		return admin, target, i18n.Errorf("No user found with ID %d", id)
+	}
+	if target.ID == admin.ID {
+		return admin, target, errors.New("You cannot change your own role or ban yourself")
+	}
+	return admin, target, nil
+}
@@ -0,0 +1,321 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+/*
+SEARCH CARDS
+*/
+
+// searchCards expects the query parameters q, expansion (can be repeated),
+// type ("white" or "black"), offset and limit. All of them are optional.
+func searchCards(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	params := req.URL.Query()
+	search := cah.CardSearch{
+		Query:      params.Get("q"),
+		Expansions: params["expansion"],
+		Type:       params.Get("type"),
+		// Only moderators can find the disabled cards
+		IncludeDisabled: params.Get("disabled") == "true" && isModerator(u),
+	}
+	if search.Type != "" && search.Type != cah.WhiteCardType && search.Type != cah.BlackCardType {
+		return errors.New("The card type needs to be 'white' or 'black'")
+	}
+	if search.Offset, err = intParam(params.Get("offset")); err != nil {
+		return errors.New("The offset needs to be a number")
+	}
+	if search.Limit, err = intParam(params.Get("limit")); err != nil {
+		return errors.New("The limit needs to be a number")
+	}
+	res, err := usecase.Card.Search(search)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, res)
+	return nil
+}
+
+/*
+SET CARD TAGS
+*/
+
+type setCardTagsPayload struct {
+	ID   int      `json:"id"`
+	Tags []string `json:"tags"`
+}
+
+func setCardTags(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	if !isModerator(u) {
+		http.Error(w, "Only moderators can change the card tags", http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload setCardTagsPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	log.Printf("User '%s' sets the tags of card %d to %v", u.Username, payload.ID, payload.Tags)
+	return usecase.Card.SetTags(payload.ID, payload.Tags)
+}
+
+/*
+CARD REPORTS AND MODERATION
+*/
+
+type reportCardPayload struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func reportCard(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload reportCardPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	return usecase.Card.ReportCard(u, payload.ID, payload.Reason)
+}
+
+type cardReportsResponse struct {
+	Card    cardResponse     `json:"card"`
+	Reports []cah.CardReport `json:"reports"`
+}
+
+type cardResponse struct {
+	ID        int      `json:"id"`
+	Type      string   `json:"type"`
+	Text      string   `json:"text"`
+	Expansion string   `json:"expansion"`
+	Blanks    int      `json:"blanks,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
+// cardReports returns the moderation queue: the reported cards, in the order
+// they were first reported, with their pending reports
+func cardReports(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	if !isModerator(u) {
+		http.Error(w, "Only moderators can review the card reports", http.StatusForbidden)
+		return nil
+	}
+	response := []*cardReportsResponse{}
+	byCard := map[int]*cardReportsResponse{}
+	for _, r := range usecase.Card.CardReports() {
+		if _, ok := byCard[r.CardID]; !ok {
+			card, err := cardToResponse(r.CardID)
+			if err != nil {
+				// Cards from deleted expansions are not listed
+				continue
+			}
+			byCard[r.CardID] = &cardReportsResponse{Card: card}
+			response = append(response, byCard[r.CardID])
+		}
+		byCard[r.CardID].Reports = append(byCard[r.CardID].Reports, r)
+	}
+	writeResponse(w, response)
+	return nil
+}
+
+type moderateCardPayload struct {
+	ID       int       `json:"id"`
+	Disabled *bool     `json:"disabled,omitempty"`
+	Text     *string   `json:"text,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"`
+	Dismiss  bool      `json:"dismiss,omitempty"`
+}
+
+// moderateCard applies the changes present in the payload to the card, resolving its reports.
+// With dismiss the reports are resolved without changing the card.
+func moderateCard(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	if !isModerator(u) {
+		http.Error(w, "Only moderators can moderate cards", http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload moderateCardPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	if payload.Dismiss {
+		log.Printf("User '%s' dismisses the reports of card %d", u.Username, payload.ID)
+		return usecase.Card.DismissReports(payload.ID)
+	}
+	if payload.Text != nil {
+		log.Printf("User '%s' changes the text of card %d to '%s'", u.Username, payload.ID, *payload.Text)
+		if err := usecase.Card.EditCard(payload.ID, *payload.Text); err != nil {
+			return err
+		}
+	}
+	if payload.Tags != nil {
+		log.Printf("User '%s' sets the tags of card %d to %v", u.Username, payload.ID, *payload.Tags)
+		if err := usecase.Card.SetTags(payload.ID, *payload.Tags); err != nil {
+			return err
+		}
+	}
+	if payload.Disabled != nil {
+		log.Printf("User '%s' sets card %d disabled to %t", u.Username, payload.ID, *payload.Disabled)
+		if err := usecase.Card.DisableCard(payload.ID, *payload.Disabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+PERSONAL BAN LIST
+*/
+
+type bannedCardsResponse struct {
+	Whites []cah.WhiteCard `json:"whites"`
+	Blacks []cah.BlackCard `json:"blacks"`
+}
+
+func bannedCards(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	response := bannedCardsResponse{Whites: []cah.WhiteCard{}, Blacks: []cah.BlackCard{}}
+	for _, id := range usecase.Card.BannedCards(u) {
+		// Cards from deleted expansions are not listed
+		if wc, err := usecase.Card.WhitesByID(id); err == nil {
+			response.Whites = append(response.Whites, dereferenceWhiteCards(wc)...)
+		}
+		if bc, err := usecase.Card.BlacksByID(id); err == nil {
+			response.Blacks = append(response.Blacks, dereferenceBlackCards(bc)...)
+		}
+	}
+	writeResponse(w, response)
+	return nil
+}
+
+type cardIDPayload struct {
+	ID int `json:"id"`
+}
+
+func banCard(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload cardIDPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	return usecase.Card.BanCard(u, payload.ID)
+}
+
+func unbanCard(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload cardIDPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	return usecase.Card.UnbanCard(u, payload.ID)
+}
+
+// Utils
+
+// cardsByID splits the IDs into white and black cards, failing if any of them does not exist or is disabled
+func cardsByID(ids []int) ([]*cah.WhiteCard, []*cah.BlackCard, error) {
+	whites := []*cah.WhiteCard{}
+	blacks := []*cah.BlackCard{}
+	for _, id := range ids {
+		if wc, err := usecase.Card.WhitesByID(id); err == nil {
+			if wc[0].Disabled {
+				return nil, nil, i18n.Errorf("The card with ID %d was disabled by a moderator", id)
+			}
+			whites = append(whites, wc...)
+			continue
+		}
+		bc, err := usecase.Card.BlacksByID(id)
+		if err != nil {
+			return nil, nil, i18n.Errorf("No card found with ID %d", id)
+		}
+		if bc[0].Disabled {
+			return nil, nil, i18n.Errorf("The card with ID %d was disabled by a moderator", id)
+		}
+		blacks = append(blacks, bc...)
+	}
+	return whites, blacks, nil
+}
+
+func cardToResponse(id int) (cardResponse, error) {
+	if wc, err := usecase.Card.WhitesByID(id); err == nil {
+		c := wc[0]
+		return cardResponse{ID: c.ID, Type: cah.WhiteCardType, Text: c.Text, Expansion: c.Expansion, Tags: c.Tags, Disabled: c.Disabled}, nil
+	}
+	bc, err := usecase.Card.BlacksByID(id)
+	if err != nil {
+		return cardResponse{}, err
+	}
+	c := bc[0]
+	return cardResponse{ID: c.ID, Type: cah.BlackCardType, Text: c.Text, Expansion: c.Expansion, Blanks: c.Blanks, Tags: c.Tags, Disabled: c.Disabled}, nil
+}
+
+// intParam parses an optional numeric query parameter, empty values are zero
+func intParam(val string) (int, error) {
+	if val == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(val)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/j4rv/cah"
+)
+
+const maxUploadSize = 4 << 20 // 4 MiB
+
+/*
+LIST EXPANSIONS
+*/
+
+// listExpansions returns the expansions that can be used in games, with their card counts,
+// so owners know if their selection is big enough. Expects the optional query parameter language, like "es"
+func listExpansions(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	_, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	exps := allowedExpansions(req.URL.Query().Get("language"))
+	sort.Slice(exps, func(i, j int) bool {
+		return exps[i].Name < exps[j].Name
+	})
+	writeResponse(w, exps)
+	return nil
+}
+
+/*
+UPLOAD EXPANSION
+*/
+
+// uploadExpansion expects a multipart form with a "name" field and either
+// a "zip" file or both "white" and "black" files.
+// The "language", "description" and comma separated "tags" fields are optional.
+func uploadExpansion(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	req.Body = http.MaxBytesReader(w, req.Body, maxUploadSize)
+	if err := req.ParseMultipartForm(maxUploadSize); err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	info := cah.Expansion{
+		Name:        req.FormValue("name"),
+		Language:    req.FormValue("language"),
+		Description: req.FormValue("description"),
+		Tags:        strings.Split(req.FormValue("tags"), ","),
+	}
+	if zfile, header, err := req.FormFile("zip"); err == nil {
+		defer zfile.Close()
+		return usecase.Card.UploadZip(u, info, zfile, header.Size)
+	}
+	wfile, _, err := req.FormFile("white")
+	if err != nil {
+		return errors.New("Missing the white cards file")
+	}
+	defer wfile.Close()
+	bfile, _, err := req.FormFile("black")
+	if err != nil {
+		return errors.New("Missing the black cards file")
+	}
+	defer bfile.Close()
+	return usecase.Card.Upload(u, info, wfile, bfile)
+}
+
+/*
+RENAME EXPANSION
+*/
+
+type renameExpansionPayload struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName"`
+}
+
+func renameExpansion(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload renameExpansionPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	if err := checkCanManageExpansion(u, payload.Name); err != nil {
+		return err
+	}
+	log.Printf("User '%s' renames expansion '%s' to '%s'", u.Username, payload.Name, payload.NewName)
+	return usecase.Card.RenameExpansion(payload.Name, payload.NewName)
+}
+
+/*
+DELETE EXPANSION
+*/
+
+type deleteExpansionPayload struct {
+	Name string `json:"name"`
+}
+
+func deleteExpansion(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload deleteExpansionPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	if err := checkCanManageExpansion(u, payload.Name); err != nil {
+		return err
+	}
+	log.Printf("User '%s' deletes expansion '%s'", u.Username, payload.Name)
+	return usecase.Card.DeleteExpansion(payload.Name)
+}
+
+// Utils
+
+// checkCanManageExpansion returns an error unless the user uploaded the expansion or is an admin
+func checkCanManageExpansion(u cah.User, name string) error {
+	e, err := usecase.Card.Expansion(name)
+	if err != nil {
+		return err
+	}
+	if isAdmin(u) {
+		return nil
+	}
+	if e.OwnerID == 0 || e.OwnerID != u.ID {
+		return errors.New("Only the expansion owner or an admin can change it")
+	}
+	return nil
+}
@@ -0,0 +1,581 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+/*
+	PRESENCE
+*/
+
+// presenceTimeout is how long users are online after their last request, without an open websocket
+const presenceTimeout = 2 * time.Minute
+
+// presence counts the open websockets of each user and remembers their last request
+var presence = struct {
+	sync.Mutex
+	connections map[int]int
+	lastSeen    map[int]time.Time
+	// prunedAt is the last time the old requests were forgotten
+	prunedAt time.Time
+}{connections: map[int]int{}, lastSeen: map[int]time.Time{}}
+
+func presenceSeen(userID int) {
+	presence.Lock()
+	defer presence.Unlock()
+	now := time.Now()
+	presence.lastSeen[userID] = now
+	// Requests older than the timeout do not count, so they are forgotten once in a while
+	if now.Sub(presence.prunedAt) > presenceTimeout {
+		for id, seen := range presence.lastSeen {
+			if now.Sub(seen) >= presenceTimeout {
+				delete(presence.lastSeen, id)
+			}
+		}
+		presence.prunedAt = now
+	}
+}
+
+// presenceConnected counts a new websocket of the user, telling their friends if they just came online
+func presenceConnected(u cah.User) {
+	presence.Lock()
+	presence.connections[u.ID]++
+	first := presence.connections[u.ID] == 1
+	presence.Unlock()
+	if first {
+		notifyFriends(u, userEvent{Type: "online", User: &eventUser{ID: u.ID, Username: u.Username}})
+	}
+}
+
+// presenceDisconnected is called when a websocket of the user closes
+func presenceDisconnected(u cah.User) {
+	presence.Lock()
+	presence.connections[u.ID]--
+	last := presence.connections[u.ID] <= 0
+	if last {
+		delete(presence.connections, u.ID)
+	}
+	presence.lastSeen[u.ID] = time.Now()
+	presence.Unlock()
+	if last {
+		notifyFriends(u, userEvent{Type: "offline", User: &eventUser{ID: u.ID, Username: u.Username}})
+	}
+}
+
+func isOnline(userID int) bool {
+	presence.Lock()
+	defer presence.Unlock()
+	return presence.connections[userID] > 0 || time.Since(presence.lastSeen[userID]) < presenceTimeout
+}
+
+/*
+	USER EVENTS
+*/
+
+// userEvent is sent through the user websocket: friend requests, invites and friends going online or offline
+type userEvent struct {
+	Type string     `json:"type"`
+	User *eventUser `json:"user,omitempty"`
+	// Game is the lobby of the invites
+	Game *gameRoomResponse `json:"game,omitempty"`
+}
+
+type eventUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+const userEventsBuffer = 16
+
+var userListeners = struct {
+	sync.Mutex
+	byUser map[int][]chan userEvent
+}{byUser: map[int][]chan userEvent{}}
+
+func startUserListening(userID int, events chan userEvent) {
+	userListeners.Lock()
+	defer userListeners.Unlock()
+	userListeners.byUser[userID] = append(userListeners.byUser[userID], events)
+}
+
+func stopUserListening(userID int, events chan userEvent) {
+	userListeners.Lock()
+	defer userListeners.Unlock()
+	var removed []chan userEvent
+	for _, listener := range userListeners.byUser[userID] {
+		if listener != events {
+			removed = append(removed, listener)
+		}
+	}
+	if len(removed) == 0 {
+		delete(userListeners.byUser, userID)
+		return
+	}
+	userListeners.byUser[userID] = removed
+}
+
+// notifyUser sends the event to the open websockets of the user.
+// Events are dropped for the websockets that are not keeping up.
+func notifyUser(userID int, e userEvent) {
+	userListeners.Lock()
+	defer userListeners.Unlock()
+	for _, listener := range userListeners.byUser[userID] {
+		select {
+		case listener <- e:
+		default:
+		}
+	}
+}
+
+func notifyFriends(u cah.User, e userEvent) {
+	if usecase.Friend == nil || u.Guest {
+		return
+	}
+	friends, err := usecase.Friend.List(u)
+	if err != nil {
+		log.Printf("ERROR while getting the friends of user %d: %s", u.ID, err)
+		return
+	}
+	for _, f := range friends.Friends {
+		notifyUser(f.UserID, e)
+	}
+}
+
+// userWebsocket sends the events of the logged user, and keeps them online while it is open
+func userWebsocket(w http.ResponseWriter, req *http.Request) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	u, err := userFromSession(w, req)
+	if err != nil {
+		return
+	}
+	events := make(chan userEvent, userEventsBuffer)
+	startUserListening(u.ID, events)
+	defer stopUserListening(u.ID, events)
+	presenceConnected(u)
+	defer presenceDisconnected(u)
+
+	// Clients do not send anything, reading is only needed to notice when they leave
+	closed := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case e := <-events:
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+/*
+	FRIENDS
+*/
+
+type friendResponse struct {
+	cah.Friend
+	Online bool `json:"online"`
+	// Game is the lobby or game in progress the friend is in
+	Game *gameRoomResponse `json:"game,omitempty"`
+}
+
+type friendsResponse struct {
+	Friends  []friendResponse `json:"friends"`
+	Incoming []cah.Friend     `json:"incoming"`
+	Outgoing []cah.Friend     `json:"outgoing"`
+	Blocked  []cah.Friend     `json:"blocked"`
+}
+
+// friends lists the friends of the user with their presence, and the pending requests
+func friends(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	list, err := usecase.Friend.List(u)
+	if err != nil {
+		return err
+	}
+	lang := requestLanguage(w, req)
+	games := activeGamesByUser()
+	res := friendsResponse{
+		Friends:  make([]friendResponse, len(list.Friends)),
+		Incoming: list.Incoming,
+		Outgoing: list.Outgoing,
+		Blocked:  list.Blocked,
+	}
+	for i, f := range list.Friends {
+		res.Friends[i] = friendResponse{Friend: f, Online: isOnline(f.UserID)}
+		if g, ok := games[f.UserID]; ok {
+			gr := gameToResponse(g, lang)
+			res.Friends[i].Game = &gr
+		}
+	}
+	writeResponse(w, res)
+	return nil
+}
+
+// friendPayload selects the other user by ID or, for friend requests, by username
+type friendPayload struct {
+	UserID   int    `json:"userID"`
+	Username string `json:"username,omitempty"`
+}
+
+func friendRequest(w http.ResponseWriter, req *http.Request) error {
+	u, payload, err := friendAction(w, req)
+	if err != nil || u.ID == 0 {
+		return err
+	}
+	if payload.UserID == 0 && payload.Username != "" {
+		other, ok := usecase.User.ByName(payload.Username)
+		if !ok {
+			return errors.New("No user found with that username")
+		}
+		payload.UserID = other.ID
+	}
+	wasRequested := hasIncomingRequest(u, payload.UserID)
+	if err := usecase.Friend.Request(u, payload.UserID); err != nil {
+		return err
+	}
+	eventType := "friend-request"
+	if wasRequested {
+		eventType = "friend-accepted"
+	}
+	notifyUser(payload.UserID, userEvent{Type: eventType, User: &eventUser{ID: u.ID, Username: u.Username}})
+	return nil
+}
+
+func acceptFriend(w http.ResponseWriter, req *http.Request) error {
+	u, payload, err := friendAction(w, req)
+	if err != nil || u.ID == 0 {
+		return err
+	}
+	if err := usecase.Friend.Accept(u, payload.UserID); err != nil {
+		return err
+	}
+	notifyUser(payload.UserID, userEvent{Type: "friend-accepted", User: &eventUser{ID: u.ID, Username: u.Username}})
+	return nil
+}
+
+func declineFriend(w http.ResponseWriter, req *http.Request) error {
+	u, payload, err := friendAction(w, req)
+	if err != nil || u.ID == 0 {
+		return err
+	}
+	return usecase.Friend.Decline(u, payload.UserID)
+}
+
+func removeFriend(w http.ResponseWriter, req *http.Request) error {
+	u, payload, err := friendAction(w, req)
+	if err != nil || u.ID == 0 {
+		return err
+	}
+	return usecase.Friend.Remove(u, payload.UserID)
+}
+
+func blockUser(w http.ResponseWriter, req *http.Request) error {
+	u, payload, err := friendAction(w, req)
+	if err != nil || u.ID == 0 {
+		return err
+	}
+	if err := usecase.Friend.Block(u, payload.UserID); err != nil {
+		return err
+	}
+	removeInvitesBetween(u.ID, payload.UserID)
+	return nil
+}
+
+func unblockUser(w http.ResponseWriter, req *http.Request) error {
+	u, payload, err := friendAction(w, req)
+	if err != nil || u.ID == 0 {
+		return err
+	}
+	return usecase.Friend.Unblock(u, payload.UserID)
+}
+
+/*
+	LOBBY INVITES
+*/
+
+// lobbyInvite lets a friend join a lobby, even if it has a password.
+// Only the owner sends them, since they decide who plays without knowing the password.
+type lobbyInvite struct {
+	GameID int       `json:"-"`
+	From   eventUser `json:"from"`
+	At     time.Time `json:"at"`
+}
+
+// lobbyInvites holds the invites of each invited user. They only last while the game has not started.
+var lobbyInvites = struct {
+	sync.Mutex
+	byUser map[int][]lobbyInvite
+}{byUser: map[int][]lobbyInvite{}}
+
+type inviteFriendPayload struct {
+	GameID int `json:"gameID"`
+	UserID int `json:"userID"`
+}
+
+// inviteFriend invites a friend of the owner to their lobby
+func inviteFriend(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload inviteFriendPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	g, err := usecase.Game.ByID(payload.GameID)
+	if err != nil {
+		return i18n.Errorf("Could not get game with id %d", payload.GameID)
+	}
+	if g.Owner.ID != u.ID {
+		return errors.New("Only the game owner can invite friends")
+	}
+	if g.State.Phase != cah.NotStarted {
+		return errors.New("You can only invite friends to games that did not start yet")
+	}
+	if !usecase.Friend.AreFriends(u.ID, payload.UserID) {
+		return i18n.Errorf("No friend found with ID %d", payload.UserID)
+	}
+	invite := lobbyInvite{GameID: g.ID, From: eventUser{ID: u.ID, Username: u.Username}, At: time.Now()}
+	lobbyInvites.Lock()
+	invites := []lobbyInvite{invite}
+	for _, other := range lobbyInvites.byUser[payload.UserID] {
+		if other.GameID != g.ID {
+			invites = append(invites, other)
+		}
+	}
+	lobbyInvites.byUser[payload.UserID] = invites
+	lobbyInvites.Unlock()
+
+	gr := gameToResponse(g, requestLanguage(w, req))
+	notifyUser(payload.UserID, userEvent{Type: "invite", User: &invite.From, Game: &gr})
+	return nil
+}
+
+type inviteResponse struct {
+	lobbyInvite
+	Game gameRoomResponse `json:"game"`
+}
+
+// invites lists the invites of the user to lobbies that did not start yet, the newest first
+func invites(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	lang := requestLanguage(w, req)
+	res := []inviteResponse{}
+	for _, inv := range pendingInvites(u.ID) {
+		g, err := usecase.Game.ByID(inv.GameID)
+		if err != nil {
+			continue
+		}
+		res = append(res, inviteResponse{lobbyInvite: inv, Game: gameToResponse(g, lang)})
+	}
+	writeResponse(w, res)
+	return nil
+}
+
+type invitePayload struct {
+	GameID int `json:"gameID"`
+}
+
+// acceptInvite joins the lobby of the invite, skipping its password
+func acceptInvite(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload invitePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	inv, ok := takeInvite(u.ID, payload.GameID)
+	// The invite does not count anymore if they stopped being friends since
+	if !ok || !usecase.Friend.AreFriends(u.ID, inv.From.ID) {
+		return i18n.Errorf("No invite found to the game with id %d", payload.GameID)
+	}
+	g, err := usecase.Game.ByID(payload.GameID)
+	if err != nil || g.State.Phase != cah.NotStarted {
+		return i18n.Errorf("No invite found to the game with id %d", payload.GameID)
+	}
+	return usecase.Game.UserJoins(u, g)
+}
+
+func declineInvite(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload invitePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	if _, ok := takeInvite(u.ID, payload.GameID); !ok {
+		return i18n.Errorf("No invite found to the game with id %d", payload.GameID)
+	}
+	return nil
+}
+
+// Utils
+
+// friendAction returns the logged user and the payload of the friend endpoints.
+// A zero user means the response was already sent.
+func friendAction(w http.ResponseWriter, req *http.Request) (cah.User, friendPayload, error) {
+	var payload friendPayload
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return cah.User{}, payload, nil
+	}
+	// Decode user's payload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return u, payload, errors.New("Misconstructed payload")
+	}
+	return u, payload, nil
+}
+
+func hasIncomingRequest(u cah.User, fromID int) bool {
+	list, err := usecase.Friend.List(u)
+	if err != nil {
+		return false
+	}
+	for _, f := range list.Incoming {
+		if f.UserID == fromID {
+			return true
+		}
+	}
+	return false
+}
+
+// activeGamesByUser returns the lobby or game in progress of each user
+func activeGamesByUser() map[int]cah.Game {
+	res := map[int]cah.Game{}
+	for _, g := range usecase.Game.All() {
+		if g.State != nil && g.State.Phase == cah.Finished {
+			continue
+		}
+		for _, u := range g.Users {
+			// Games in progress are more interesting than lobbies
+			if prev, ok := res[u.ID]; ok && prev.State.Phase != cah.NotStarted {
+				continue
+			}
+			res[u.ID] = g
+		}
+	}
+	return res
+}
+
+func inGame(userID int, g cah.Game) bool {
+	for _, u := range g.Users {
+		if u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingInvites returns the invites of the user, forgetting the ones of games that started or were deleted
+func pendingInvites(userID int) []lobbyInvite {
+	lobbyInvites.Lock()
+	defer lobbyInvites.Unlock()
+	pending := []lobbyInvite{}
+	for _, inv := range lobbyInvites.byUser[userID] {
+		g, err := usecase.Game.ByID(inv.GameID)
+		if err != nil || g.State.Phase != cah.NotStarted || inGame(userID, g) {
+			continue
+		}
+		pending = append(pending, inv)
+	}
+	setInvites(userID, pending)
+	return pending
+}
+
+// takeInvite removes the invite of the user to the game and returns it, if there was one
+func takeInvite(userID, gameID int) (lobbyInvite, bool) {
+	lobbyInvites.Lock()
+	defer lobbyInvites.Unlock()
+	var taken lobbyInvite
+	found := false
+	rest := []lobbyInvite{}
+	for _, inv := range lobbyInvites.byUser[userID] {
+		if inv.GameID == gameID {
+			taken, found = inv, true
+			continue
+		}
+		rest = append(rest, inv)
+	}
+	setInvites(userID, rest)
+	return taken, found
+}
+
+// removeInvitesBetween forgets the invites the users sent to each other, like when one blocks the other
+func removeInvitesBetween(userID, otherID int) {
+	lobbyInvites.Lock()
+	defer lobbyInvites.Unlock()
+	for _, pair := range [][2]int{{userID, otherID}, {otherID, userID}} {
+		rest := []lobbyInvite{}
+		for _, inv := range lobbyInvites.byUser[pair[0]] {
+			if inv.From.ID != pair[1] {
+				rest = append(rest, inv)
+			}
+		}
+		setInvites(pair[0], rest)
+	}
+}
+
+// setInvites replaces the invites of the user, forgetting the user when none are left.
+// lobbyInvites must be locked.
+func setInvites(userID int, invites []lobbyInvite) {
+	if len(invites) == 0 {
+		delete(lobbyInvites.byUser, userID)
+		return
+	}
+	lobbyInvites.byUser[userID] = invites
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+/*
+	GAME INVITE LINKS
+*/
+
+// gameInviteResponse Link is relative to the server, so clients add their host to share it
+type gameInviteResponse struct {
+	cah.GameInvite
+	Link string `json:"link"`
+}
+
+func inviteToResponse(inv cah.GameInvite) gameInviteResponse {
+	return gameInviteResponse{GameInvite: inv, Link: "/game/invite/" + inv.Token}
+}
+
+// gameInvites lists the invite links of the game that can still be used
+func gameInvites(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	g, err := gameFromRequest(req)
+	if err != nil {
+		return err
+	}
+	invites, err := usecase.GameInvite.ByGame(u, g)
+	if err != nil {
+		return err
+	}
+	res := make([]gameInviteResponse, len(invites))
+	for i := range invites {
+		res[i] = inviteToResponse(invites[i])
+	}
+	writeResponse(w, res)
+	return nil
+}
+
+// createGameInvitePayload ExpiresIn is in seconds, zero uses the default expiry.
+// Zero MaxUses means the link can be used any number of times.
+type createGameInvitePayload struct {
+	ExpiresIn int `json:"expiresIn,omitempty"`
+	MaxUses   int `json:"maxUses,omitempty"`
+}
+
+func createGameInvite(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	g, err := gameFromRequest(req)
+	if err != nil {
+		return err
+	}
+	// Decode user's payload
+	var payload createGameInvitePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	inv, err := usecase.GameInvite.Create(u, g, time.Duration(payload.ExpiresIn)*time.Second, payload.MaxUses)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, inviteToResponse(inv))
+	return nil
+}
+
+type revokeGameInvitePayload struct {
+	ID int `json:"id"`
+}
+
+func revokeGameInvite(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	g, err := gameFromRequest(req)
+	if err != nil {
+		return err
+	}
+	// Decode user's payload
+	var payload revokeGameInvitePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	return usecase.GameInvite.Revoke(u, g, payload.ID)
+}
+
+// gameInviteLink joins the game of the invite, without asking for its password. Like joinGameLink,
+// users that are not logged in go through the login page first, where they can also play as guests.
+// The invite is only used once the user joins, so the login does not waste its uses.
+func gameInviteLink(w http.ResponseWriter, req *http.Request) {
+	lang := requestLanguage(w, req)
+	token := mux.Vars(req)["token"]
+	inv, err := usecase.GameInvite.Check(token)
+	if err != nil {
+		// Players that already joined can keep using the link to go back to the game
+		if u, uErr := userFromSession(w, req); uErr == nil && inv.GameID != 0 {
+			if g, gErr := usecase.Game.ByID(inv.GameID); gErr == nil && inGame(u.ID, g) {
+				http.Redirect(w, req, fmt.Sprintf("/game/room/%d", g.ID), http.StatusFound)
+				return
+			}
+		}
+		log.Printf("Unusable invite link from %s: %s", clientIP(req), err)
+		http.Error(w, i18n.TranslateError(lang, err), http.StatusGone)
+		return
+	}
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Redirect(w, req, "/login?next="+url.QueryEscape(req.URL.Path), http.StatusFound)
+		return
+	}
+	g, err := usecase.Game.ByID(inv.GameID)
+	if err != nil {
+		http.Error(w, i18n.Sprintf(lang, "Could not get game with id %d", inv.GameID), http.StatusGone)
+		return
+	}
+	room := fmt.Sprintf("/game/room/%d", g.ID)
+	if inGame(u.ID, g) {
+		http.Redirect(w, req, room, http.StatusFound)
+		return
+	}
+	if g.State.Phase != cah.NotStarted {
+		http.Error(w, i18n.T(lang, "This game already started"), http.StatusGone)
+		return
+	}
+	inv, err = usecase.GameInvite.Redeem(token)
+	if err != nil {
+		http.Error(w, i18n.TranslateError(lang, err), http.StatusGone)
+		return
+	}
+	if err := usecase.Game.UserJoins(u, g); err != nil {
+		log.Printf("User %d could not join the game %d with the invite link %d: %s", u.ID, g.ID, inv.ID, err)
+		// The invite was not really used, so it keeps its use
+		if err := usecase.GameInvite.Unredeem(inv); err != nil {
+			log.Printf("ERROR while giving back the use of the invite link %d: %s", inv.ID, err)
+		}
+		http.Error(w, i18n.TranslateError(lang, err), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, req, room, http.StatusFound)
+}
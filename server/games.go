@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
 )
 
 const minWhites = 34
@@ -22,6 +24,8 @@ const maxHandSize = 30
 OPEN GAMES LIST
 */
 
+// gameRoomResponse Phase is meant to be compared by the clients,
+// PhaseName is the same phase in the user's language
 type gameRoomResponse struct {
 	ID          int      `json:"id"`
 	Name        string   `json:"name"`
@@ -29,6 +33,7 @@ type gameRoomResponse struct {
 	HasPassword bool     `json:"hasPassword"`
 	Players     []string `json:"players"`
 	Phase       string   `json:"phase"`
+	PhaseName   string   `json:"phaseName"`
 	StateID     int      `json:"stateID"`
 }
 
@@ -37,14 +42,15 @@ func roomState(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
-	writeResponse(w, gameToResponse(g))
+	writeResponse(w, gameToResponse(g, requestLanguage(w, req)))
 	return nil
 }
 
 func openGames(w http.ResponseWriter, req *http.Request) error {
 	response := []gameRoomResponse{}
+	lang := requestLanguage(w, req)
 	for _, g := range usecase.Game.AllOpen() {
-		response = append(response, gameToResponse(g))
+		response = append(response, gameToResponse(g, lang))
 	}
 	writeResponse(w, response)
 	return nil
@@ -57,14 +63,15 @@ func inProgressGames(w http.ResponseWriter, req *http.Request) error {
 		http.Error(w, err.Error(), http.StatusForbidden)
 	}
 	response := []gameRoomResponse{}
+	lang := requestLanguage(w, req)
 	for _, g := range usecase.Game.InProgressForUser(u) {
-		response = append(response, gameToResponse(g))
+		response = append(response, gameToResponse(g, lang))
 	}
 	writeResponse(w, response)
 	return nil
 }
 
-func gameToResponse(g cah.Game) gameRoomResponse {
+func gameToResponse(g cah.Game, lang string) gameRoomResponse {
 	players := make([]string, len(g.Users))
 	for i := range g.Users {
 		players[i] = g.Users[i].Username
@@ -76,6 +83,7 @@ func gameToResponse(g cah.Game) gameRoomResponse {
 		HasPassword: g.Password != "",
 		Players:     players,
 		Phase:       g.State.Phase.String(),
+		PhaseName:   i18n.T(lang, g.State.Phase.String()),
 		StateID:     g.State.ID,
 	}
 }
@@ -109,6 +117,28 @@ func createGame(w http.ResponseWriter, req *http.Request) error {
 	return nil
 }
 
+// joinGameLink is the link to share a game. Users that are not logged in go through the
+// login page first, where they can also play as guests. Open games without a password are
+// joined right away, the other ones are only shown.
+func joinGameLink(w http.ResponseWriter, req *http.Request) {
+	g, err := gameFromRequest(req)
+	if err != nil {
+		http.Redirect(w, req, afterLoginRedirect, http.StatusFound)
+		return
+	}
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Redirect(w, req, "/login?next="+url.QueryEscape(req.URL.Path), http.StatusFound)
+		return
+	}
+	if g.State.Phase == cah.NotStarted && g.Password == "" {
+		if err := usecase.Game.UserJoins(u, g); err != nil {
+			log.Printf("ERROR while user %d joined the game %d through a link: %s", u.ID, g.ID, err)
+		}
+	}
+	http.Redirect(w, req, fmt.Sprintf("/game/room/%d", g.ID), http.StatusFound)
+}
+
 /*
 JOIN GAME
 */
@@ -151,8 +181,20 @@ type startGamePayload struct {
 	HandSize        int      `json:"handSize"`
 	RandomFirstCzar bool     `json:"randomFirstCzar,omitempty"`
 	MaxRounds       int      `json:"maxRounds"`
+	IncludeCards    []int    `json:"includeCards,omitempty"`
+	ExcludeCards    []int    `json:"excludeCards,omitempty"`
+	// ExpansionWeights are optional, if used every selected expansion needs a weight
+	ExpansionWeights map[string]float64 `json:"expansionWeights,omitempty"`
+	Seed             *int64             `json:"seed,omitempty"`
+	ExcludeTags      []string           `json:"excludeTags,omitempty"`
+	FamilyFriendly   bool               `json:"familyFriendly,omitempty"`
+	// PreferUnseen puts the cards the players have not seen recently at the top of the decks
+	PreferUnseen bool `json:"preferUnseen,omitempty"`
 }
 
+// familyFriendlyTags are the tags excluded by the family friendly option
+var familyFriendlyTags = []string{cah.NSFWTag, cah.PoliticalTag, cah.LanguageTag}
+
 func startGame(w http.ResponseWriter, req *http.Request) error {
 	// User is logged
 	u, err := userFromSession(w, req)
@@ -173,10 +215,10 @@ func startGame(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
-	if g.Owner != u {
+	if g.Owner.ID != u.ID {
 		return errors.New("Only the game owner can start the game")
 	}
-	opts, err := optionsFromCreateRequest(payload)
+	opts, err := optionsFromCreateRequest(payload, g)
 	if err != nil {
 		return err
 	}
@@ -188,24 +230,20 @@ func startGame(w http.ResponseWriter, req *http.Request) error {
 	return nil
 }
 
-func optionsFromCreateRequest(payload startGamePayload) ([]cah.Option, error) {
-	ret := []cah.Option{}
-	// EXPANSIONS
-	exps := payload.Expansions
-	blacks := usecase.Card.ExpansionBlacks(exps...)
-	whites := usecase.Card.ExpansionWhites(exps...)
-	if len(blacks) < minBlacks {
-		return ret, fmt.Errorf("Not enough black cards to play a game. Please select more expansions. The amount of Black cards in selected expansions is %d, but the minimum is %d", len(blacks), minBlacks)
+func optionsFromCreateRequest(payload startGamePayload, g cah.Game) ([]cah.Option, error) {
+	// DECKS
+	ret, err := deckOptions(payload, g.Owner, g.Users)
+	if err != nil {
+		return ret, err
 	}
-	if len(whites) < minWhites {
-		return ret, fmt.Errorf("Not enough white cards to play a game. Please select more expansions. The amount of White cards in selected expansions is %d, but the minimum is %d", len(whites), minWhites)
+	decks := dryRunDecks(ret)
+	if errs := deckSizeErrors(len(decks.WhiteDeck), len(decks.BlackDeck)); len(errs) != 0 {
+		return ret, errs[0]
 	}
-	ret = append(ret, usecase.Game.Options().BlackDeck(blacks))
-	ret = append(ret, usecase.Game.Options().WhiteDeck(whites))
 	// HAND SIZE
 	handS := payload.HandSize
 	if handS < minHandSize || handS > maxHandSize {
-		return ret, fmt.Errorf("Hand size needs to be a number between %d and %d (both included).", minHandSize, maxHandSize)
+		return ret, i18n.Errorf("Hand size needs to be a number between %d and %d (both included).", minHandSize, maxHandSize)
 	}
 	ret = append(ret, usecase.Game.Options().HandSize(handS))
 	// RANDOM FIRST CZAR?
@@ -216,20 +254,168 @@ func optionsFromCreateRequest(payload startGamePayload) ([]cah.Option, error) {
 	return ret, nil
 }
 
-func availableExpansions(w http.ResponseWriter, req *http.Request) error {
+// deckOptions returns the options that build the game decks: the cards from the expansions
+// plus the included cards, minus the excluded cards and the cards banned by the owner.
+// The players are used to put the cards they have not seen first.
+func deckOptions(payload startGamePayload, owner cah.User, players []cah.User) ([]cah.Option, error) {
+	ret := []cah.Option{}
+	opts := usecase.Game.Options()
+	// WEIGHTS AND SEED
+	if len(payload.ExpansionWeights) != 0 {
+		if err := checkExpansionWeights(payload.Expansions, payload.ExpansionWeights); err != nil {
+			return ret, err
+		}
+		opts = opts.DeckWeights(payload.ExpansionWeights)
+	}
+	if payload.Seed != nil {
+		opts = opts.Seeded(*payload.Seed)
+	}
+	// EXCLUDED CARDS AND TAGS
+	// They are left out before sampling, so the weights and the seed apply to the cards that can be in the decks
+	excluded := append(payload.ExcludeCards, usecase.Card.BannedCards(owner)...)
+	tags := append(normalizedTags(payload.ExcludeTags), serverExcludedTags()...)
+	if payload.FamilyFriendly {
+		tags = append(tags, familyFriendlyTags...)
+	}
+	opts = opts.Excluding(excluded, tags)
+	// EXPANSIONS
+	exps := payload.Expansions
+	ret = append(ret, opts.BlackDeck(usecase.Card.ExpansionBlacks(exps...)))
+	ret = append(ret, opts.WhiteDeck(usecase.Card.ExpansionWhites(exps...)))
+	// INCLUDED CARDS
+	if len(payload.IncludeCards) != 0 {
+		whites, blacks, err := cardsByID(payload.IncludeCards)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, opts.IncludeWhites(whites))
+		ret = append(ret, opts.IncludeBlacks(blacks))
+	}
+	// The exclusions also apply to the included cards
+	if len(excluded) != 0 {
+		ret = append(ret, opts.ExcludeCards(excluded...))
+	}
+	if len(tags) != 0 {
+		ret = append(ret, opts.ExcludeTags(tags...))
+	}
+	// UNSEEN CARDS FIRST
+	if payload.PreferUnseen && len(players) != 0 {
+		ret = append(ret, opts.PreferUnseen(usecase.Stats.RecentlySeen(players...)...))
+	}
+	return ret, nil
+}
+
+// dryRunDecks applies the deck options to an empty game state, to check the resulting decks
+func dryRunDecks(deckOpts []cah.Option) *cah.GameState {
+	s := &cah.GameState{}
+	for _, opt := range deckOpts {
+		opt(s)
+	}
+	return s
+}
+
+/*
+EXPANSIONS PREVIEW
+*/
+
+type expansionsPreviewResponse struct {
+	Whites    int         `json:"whites"`
+	Blacks    int         `json:"blacks"`
+	Picks     map[int]int `json:"picks"`
+	MinWhites int         `json:"minWhites"`
+	MinBlacks int         `json:"minBlacks"`
+	Valid     bool        `json:"valid"`
+	Errors    []string    `json:"errors"`
+}
+
+// expansionsPreview computes the deck totals for the deck options of a start game payload,
+// so the game owner knows if they are enough before starting the game
+func expansionsPreview(w http.ResponseWriter, req *http.Request) error {
 	// User is logged
-	_, err := userFromSession(w, req)
+	u, err := userFromSession(w, req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	var payload startGamePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	// The order of the cards does not change the totals, so the players are not needed
+	opts, err := deckOptions(payload, u, nil)
+	if err != nil {
+		return err
+	}
+	decks := dryRunDecks(opts)
+	response := expansionsPreviewResponse{
+		Whites:    len(decks.WhiteDeck),
+		Blacks:    len(decks.BlackDeck),
+		Picks:     map[int]int{},
+		MinWhites: minWhites,
+		MinBlacks: minBlacks,
+		Errors:    []string{},
 	}
-	exps := usecase.Card.AvailableExpansions()
-	sort.Strings(exps)
-	writeResponse(w, exps)
+	for _, c := range decks.BlackDeck {
+		response.Picks[c.Blanks]++
+	}
+	lang := requestLanguage(w, req)
+	for _, err := range deckSizeErrors(len(decks.WhiteDeck), len(decks.BlackDeck)) {
+		response.Errors = append(response.Errors, i18n.TranslateError(lang, err))
+	}
+	response.Valid = len(response.Errors) == 0
+	writeResponse(w, response)
 	return nil
 }
 
 // Utils
 
+// allowedExpansions returns the expansions not excluded by the server's excluded tags.
+// With a language, only the expansions in that language are returned.
+func allowedExpansions(language string) []cah.Expansion {
+	ret := []cah.Expansion{}
+	excluded := serverExcludedTags()
+	language = strings.ToLower(strings.TrimSpace(language))
+	for _, e := range usecase.Card.Expansions() {
+		if cah.HasTag(e.Tags, excluded...) {
+			continue
+		}
+		if language != "" && e.Language != language {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+func checkExpansionWeights(exps []string, weights map[string]float64) error {
+	selected := map[string]bool{}
+	for _, e := range exps {
+		selected[e] = true
+		if weights[e] <= 0 {
+			return i18n.Errorf("The expansion '%s' needs a weight greater than zero", e)
+		}
+	}
+	for e := range weights {
+		if !selected[e] {
+			return i18n.Errorf("The expansion '%s' has a weight but it was not selected", e)
+		}
+	}
+	return nil
+}
+
+func deckSizeErrors(whites, blacks int) []error {
+	errs := []error{}
+	if blacks < minBlacks {
+		errs = append(errs, i18n.Errorf("Not enough black cards to play a game. Please select more expansions. The amount of Black cards in selected expansions is %d, but the minimum is %d", blacks, minBlacks))
+	}
+	if whites < minWhites {
+		errs = append(errs, i18n.Errorf("Not enough white cards to play a game. Please select more expansions. The amount of White cards in selected expansions is %d, but the minimum is %d", whites, minWhites))
+	}
+	return errs
+}
+
 func gameFromRequest(req *http.Request) (cah.Game, error) {
 	strID := mux.Vars(req)["gameID"]
 	id, err := strconv.Atoi(strID)
@@ -238,7 +424,7 @@ func gameFromRequest(req *http.Request) (cah.Game, error) {
 	}
 	g, err := usecase.Game.ByID(id)
 	if err != nil {
-		return g, fmt.Errorf("Could not get game with id %d", id)
+		return g, i18n.Errorf("Could not get game with id %d", id)
 	}
 	return g, nil
 }
@@ -8,9 +8,12 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/cardimg"
+	"github.com/j4rv/cah/lib/i18n"
 )
 
 /*
@@ -36,11 +39,15 @@ type fullPlayerInfo struct {
 type sinnerPlay struct {
 	ID         int             `json:"id"`
 	WhiteCards []cah.WhiteCard `json:"whiteCards"`
+	Sentence   string          `json:"sentence"`
 }
 
+// gameStateResponse Phase is meant to be compared by the clients,
+// PhaseName is the same phase in the user's language
 type gameStateResponse struct {
 	ID              int            `json:"id"`
 	Phase           string         `json:"phase"`
+	PhaseName       string         `json:"phaseName"`
 	Players         []playerInfo   `json:"players"`
 	CurrCzarID      int            `json:"currentCzarID"`
 	BlackCardInPlay cah.BlackCard  `json:"blackCardInPlay"`
@@ -50,6 +57,7 @@ type gameStateResponse struct {
 	MyPlayer        fullPlayerInfo `json:"myPlayer"`
 	CurrRound       int            `json:"currRound"`
 	MaxRounds       int            `json:"maxRounds"`
+	Rounds          []*cah.Round   `json:"rounds"`
 }
 
 var gameStateListeners = make(map[int][]*chan *cah.GameState)
@@ -97,6 +105,10 @@ func gameStateWebsocket(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	presenceConnected(u)
+	defer presenceDisconnected(u)
+
+	lang := requestLanguage(w, req)
 	eventListener := make(chan *cah.GameState)
 	startListening(gsID, &eventListener)
 	log.Println("User started listening:", u.Username, "game:", gsID)
@@ -104,7 +116,7 @@ func gameStateWebsocket(w http.ResponseWriter, req *http.Request) {
 	defer log.Println("User stopped listening:", u.Username, "game:", gsID)
 
 	for {
-		err = conn.WriteJSON(newGameStateResponse(gameState, p))
+		err = conn.WriteJSON(newGameStateResponse(gameState, p, lang))
 		if err != nil {
 			return
 		}
@@ -125,14 +137,15 @@ func gameStateForUser(w http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return err
 	}
-	writeResponse(w, newGameStateResponse(gameState, p))
+	writeResponse(w, newGameStateResponse(gameState, p, requestLanguage(w, req)))
 	return nil
 }
 
-func newGameStateResponse(gs *cah.GameState, player *cah.Player) *gameStateResponse {
+func newGameStateResponse(gs *cah.GameState, player *cah.Player, lang string) *gameStateResponse {
 	return &gameStateResponse{
 		ID:              gs.ID,
 		Phase:           gs.Phase.String(),
+		PhaseName:       i18n.T(lang, gs.Phase.String()),
 		Players:         playersInfoFromGame(gs),
 		CurrCzarID:      gs.Players[gs.CurrCzarIndex].User.ID,
 		BlackCardInPlay: *gs.BlackCardInPlay,
@@ -142,6 +155,7 @@ func newGameStateResponse(gs *cah.GameState, player *cah.Player) *gameStateRespo
 		MyPlayer:        newFullPlayerInfo(*player),
 		CurrRound:       gs.CurrRound,
 		MaxRounds:       gs.MaxRounds,
+		Rounds:          roundsFromGame(gs),
 	}
 }
 
@@ -184,6 +198,7 @@ func sinnerPlaysFromGame(gs *cah.GameState) []sinnerPlay {
 		ret[i] = sinnerPlay{
 			ID:         p.User.ID,
 			WhiteCards: dereferenceWhiteCards(p.WhiteCardsInPlay),
+			Sentence:   cah.ComposeSentence(gs.BlackCardInPlay, p.WhiteCardsInPlay),
 		}
 	}
 	rand.Shuffle(len(ret), func(i, j int) {
@@ -192,6 +207,58 @@ func sinnerPlaysFromGame(gs *cah.GameState) []sinnerPlay {
 	return ret
 }
 
+func roundsFromGame(gs *cah.GameState) []*cah.Round {
+	if gs.Rounds == nil {
+		return []*cah.Round{}
+	}
+	return gs.Rounds
+}
+
+/*
+ROUND IMAGE
+*/
+
+// roundImage renders the black card and the winning white cards of a finished round as a PNG
+func roundImage(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	gs, err := gameStateFromRequest(req)
+	if err != nil {
+		return err
+	}
+	if _, err := player(gs, u); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(mux.Vars(req)["round"])
+	if err != nil {
+		return errors.New("The round needs to be a number")
+	}
+	var round *cah.Round
+	for _, r := range gs.Rounds {
+		if r.Number == number {
+			round = r
+		}
+	}
+	if round == nil {
+		return i18n.Errorf("The round %d has not finished yet", number)
+	}
+	cards := []cardimg.Card{{
+		Text:   strings.Replace(cah.UnescapeLineBreaks(round.BlackCard.Text), "_", "______", -1),
+		Footer: round.BlackCard.Expansion,
+		Black:  true,
+	}}
+	for _, c := range round.WhiteCards {
+		cards = append(cards, cardimg.Card{Text: cah.UnescapeLineBreaks(c.Text), Footer: c.Expansion})
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="round-%d.png"`, number))
+	return cardimg.WritePNG(w, cards)
+}
+
 /*
 CHOOSE WINNER
 */
@@ -295,7 +362,7 @@ func gameStateFromRequest(req *http.Request) (*cah.GameState, error) {
 	}
 	g, err := usecase.GameState.ByID(id)
 	if err != nil {
-		return g, fmt.Errorf("Could not get game state from request. ID: %d", id)
+		return g, i18n.Errorf("Could not get game state from request. ID: %d", id)
 	}
 	return g, nil
 }
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+	"github.com/j4rv/cah/lib/oidc"
+)
+
+/*
+	OPENID CONNECT LOGIN
+*/
+
+// oidcTimeout is how long users have to log in with the provider
+const oidcTimeout = 10 * time.Minute
+
+// oidcRecentLogin is how long a login with the provider confirms who the user is,
+// for the users without a password that change it or delete their account
+const oidcRecentLogin = 10 * time.Minute
+
+// oidcSessionKeys hold the login in progress in the cookie session
+var oidcSessionKeys = []string{"oidc_state", "oidc_nonce", "oidc_verifier", "oidc_next", "oidc_started", "oidc_link"}
+
+var oidcConfig oidc.Config
+
+// oidcName is shown in the login button, like "Log in with ACME SSO"
+var oidcName string
+
+var oidcMu sync.Mutex
+var oidcProviderCache *oidc.Provider
+
+// initOIDC reads the provider configuration from the environment, the login is enabled if OIDC_ISSUER is set
+func initOIDC() {
+	oidcConfig = oidc.Config{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if len(oidcConfig.Scopes) == 0 {
+		oidcConfig.Scopes = []string{"profile", "email"}
+	}
+	oidcName = os.Getenv("OIDC_NAME")
+	if oidcName == "" {
+		oidcName = "SSO"
+	}
+	if oidcConfig.Issuer != "" && (oidcConfig.ClientID == "" || oidcConfig.RedirectURL == "") {
+		log.Println("OpenID Connect login disabled, it needs the environment variables OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+		oidcConfig.Issuer = ""
+	}
+}
+
+func oidcEnabled() bool {
+	return oidcConfig.Issuer != ""
+}
+
+// oidcProvider returns the provider, reading its discovery document on first use,
+// so the server can start even if the provider is down
+func oidcProvider() (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProviderCache != nil {
+		return oidcProviderCache, nil
+	}
+	p, err := oidc.Discover(oidcConfig, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	oidcProviderCache = p
+	return p, nil
+}
+
+// oidcLogin sends the user to the provider. The optional query parameter next is where to go after logging in.
+func oidcLogin(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	oidcStart(0, w, req)
+}
+
+// oidcLink sends a logged user to the provider, to link their account with it
+func oidcLink(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	oidcStart(u.ID, w, req)
+}
+
+// oidcStart remembers the state, nonce and PKCE verifier of the login, and redirects to the provider.
+// A non zero linkUserID links the identity to that user instead of logging in.
+func oidcStart(linkUserID int, w http.ResponseWriter, req *http.Request) {
+	if !oidcEnabled() {
+		http.NotFound(w, req)
+		return
+	}
+	p, err := oidcProvider()
+	if err != nil {
+		log.Printf("ERROR while reading the OpenID Connect provider configuration: %s", err)
+		oidcFailed(w, req)
+		return
+	}
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.Error(w, "Could not start the login", http.StatusInternalServerError)
+		return
+	}
+	session := getSession(w, req)
+	session.Values["oidc_state"] = state
+	session.Values["oidc_nonce"] = nonce
+	session.Values["oidc_verifier"] = verifier
+	session.Values["oidc_next"] = afterLogin(req)
+	session.Values["oidc_started"] = time.Now().Unix()
+	session.Values["oidc_link"] = linkUserID
+	if err := session.Save(req, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, req, p.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// oidcCallback finishes the login once the provider sends the user back with a code
+func oidcCallback(w http.ResponseWriter, req *http.Request) {
+	if !oidcEnabled() {
+		http.NotFound(w, req)
+		return
+	}
+	session := getSession(w, req)
+	state, _ := session.Values["oidc_state"].(string)
+	nonce, _ := session.Values["oidc_nonce"].(string)
+	verifier, _ := session.Values["oidc_verifier"].(string)
+	next, _ := session.Values["oidc_next"].(string)
+	started, _ := session.Values["oidc_started"].(int64)
+	linkUserID, _ := session.Values["oidc_link"].(int)
+	for _, k := range oidcSessionKeys {
+		delete(session.Values, k)
+	}
+	session.Save(req, w)
+	if localPath(next) == "" {
+		next = afterLoginRedirect
+	}
+
+	q := req.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 ||
+		time.Since(time.Unix(started, 0)) > oidcTimeout {
+		log.Printf("SECURITY OpenID Connect callback with an unexpected state from %s", clientIP(req))
+		addFlashMsg(i18n.T(requestLanguage(w, req), "Your login expired, please log in again."), loginFlashKey, w, req)
+		http.Redirect(w, req, "/login", http.StatusFound)
+		return
+	}
+	if errCode := q.Get("error"); errCode != "" {
+		log.Printf("OpenID Connect provider returned the error '%s': %s", errCode, q.Get("error_description"))
+		oidcFailed(w, req)
+		return
+	}
+	p, err := oidcProvider()
+	if err != nil {
+		log.Printf("ERROR while reading the OpenID Connect provider configuration: %s", err)
+		oidcFailed(w, req)
+		return
+	}
+	claims, err := p.Exchange(q.Get("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("SECURITY OpenID Connect login failed from %s: %s", clientIP(req), err)
+		oidcFailed(w, req)
+		return
+	}
+	identity := cah.ExternalIdentity{Issuer: claims.Issuer, Subject: claims.Subject, Username: oidcUsername(claims)}
+
+	if linkUserID != 0 {
+		oidcFinishLink(linkUserID, identity, next, w, req)
+		return
+	}
+	u, err := usecase.User.LoginExternal(identity)
+	if err != nil {
+		addFlashMsg(i18n.TranslateError(requestLanguage(w, req), err), loginFlashKey, w, req)
+		http.Redirect(w, req, "/login", http.StatusFound)
+		return
+	}
+	if u.Banned {
+		log.Printf("SECURITY Banned user %s with id %d tried to log in from %s", u.Username, u.ID, clientIP(req))
+		addFlashMsg(i18n.T(requestLanguage(w, req), bannedMsg), loginFlashKey, w, req)
+		http.Redirect(w, req, "/login", http.StatusFound)
+		return
+	}
+	// Kept after entering the two-factor code too, since sessionStart does not remove it
+	session.Values["oidc_login_user"] = u.ID
+	session.Values["oidc_login_at"] = time.Now().Unix()
+	if usecase.TwoFactor.Enabled(u.ID) {
+		twoFactorStart(u, false, next, w, req)
+		return
+	}
+	log.Printf("User %s with id %d just logged in with OpenID Connect!", u.Username, u.ID)
+	if err := sessionStart(u, false, w, req); err != nil {
+		return
+	}
+	http.Redirect(w, req, next, http.StatusFound)
+}
+
+// oidcFinishLink links the identity to the user who started the link, if they are still logged in
+func oidcFinishLink(userID int, identity cah.ExternalIdentity, next string, w http.ResponseWriter, req *http.Request) {
+	u, err := userFromSession(w, req)
+	if err != nil || u.ID != userID {
+		addFlashMsg(i18n.T(requestLanguage(w, req), "Your login expired, please log in again."), loginFlashKey, w, req)
+		http.Redirect(w, req, "/login", http.StatusFound)
+		return
+	}
+	if err := usecase.User.LinkExternal(u, identity); err != nil {
+		http.Error(w, i18n.TranslateError(requestLanguage(w, req), err), http.StatusPreconditionFailed)
+		return
+	}
+	http.Redirect(w, req, next, http.StatusFound)
+}
+
+// checkRecentOIDCLogin confirms who the user is when their account has no password,
+// asking them to log in with the provider again if they did not recently
+func checkRecentOIDCLogin(u cah.User, w http.ResponseWriter, req *http.Request) error {
+	if usecase.User.HasPassword(u) {
+		return nil
+	}
+	session := getSession(w, req)
+	userID, _ := session.Values["oidc_login_user"].(int)
+	at, _ := session.Values["oidc_login_at"].(int64)
+	if userID != u.ID || time.Since(time.Unix(at, 0)) > oidcRecentLogin {
+		return i18n.Errorf("Please log in with %s again to confirm it is you", oidcName)
+	}
+	return nil
+}
+
+func oidcFailed(w http.ResponseWriter, req *http.Request) {
+	addFlashMsg(i18n.Sprintf(requestLanguage(w, req), "Could not log in with %s, please try again.", oidcName), loginFlashKey, w, req)
+	http.Redirect(w, req, "/login", http.StatusFound)
+}
+
+// oidcUsername returns the name suggested for new users: the preferred username,
+// the name or the part of the email before the @, in that order
+func oidcUsername(c oidc.Claims) string {
+	if c.PreferredUsername != "" {
+		return c.PreferredUsername
+	}
+	if c.Name != "" {
+		return c.Name
+	}
+	if at := strings.Index(c.Email, "@"); at > 0 {
+		return c.Email[:at]
+	}
+	return ""
+}
@@ -6,8 +6,10 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -18,6 +20,11 @@ var usingTLS bool
 var devMode bool
 var serverCert, serverPK string
 var publicDir string
+var adminNames string
+var excludedTags string
+var useServerSessions bool
+var trustedProxy bool
+var adminsNeed2FA bool
 
 var usecase cah.Usecases
 
@@ -28,6 +35,7 @@ var upgrader = websocket.Upgrader{
 
 func init() {
 	initCertificateStuff()
+	initOIDC()
 	parseFlags()
 }
 
@@ -48,12 +56,18 @@ func parseFlags() {
 	flag.IntVar(&secureport, "secureport", 443, "Server port for serving HTTPS")
 	flag.BoolVar(&devMode, "dev", false, "Activates development mode")
 	flag.StringVar(&publicDir, "dir", "frontend/build", "the directory to serve files from. Defaults to 'frontend/build'")
+	flag.StringVar(&adminNames, "admins", "", "comma separated list of usernames given the admin role on startup")
+	flag.StringVar(&excludedTags, "exclude-tags", "", "comma separated list of card tags excluded from every game, like 'nsfw,political'")
+	flag.BoolVar(&useServerSessions, "server-sessions", false, "stores the sessions in the database, so users can list and revoke them")
+	flag.BoolVar(&trustedProxy, "trusted-proxy", false, "trusts the client IP sent by a proxy in the X-Forwarded-For header, like the Heroku router")
+	flag.BoolVar(&adminsNeed2FA, "admins-need-2fa", false, "admins only get their privileges after enabling two-factor authentication")
 	flag.Parse()
 }
 
 // Start creates and starts the server with the provided usecases
 func Start(uc cah.Usecases) {
 	usecase = uc
+	promoteAdmins()
 
 	router := mux.NewRouter()
 	//Any non found paths should redirect to index. React-router will handle those.
@@ -62,6 +76,8 @@ func Start(uc cah.Usecases) {
 	setRestRouterHandlers(router)
 	setTemplateRouterHandlers(router)
 
+	go collectGuests(guestCollectInterval)
+
 	//Static files handler
 	fs := http.FileServer(http.Dir(publicDir))
 	router.PathPrefix("/static").Handler(fs)
@@ -80,8 +96,34 @@ func setRestRouterHandlers(r *mux.Router) {
 		s := restRouter.PathPrefix("/user").Subrouter()
 		s.HandleFunc("/login", processLogin).Methods("POST")
 		s.HandleFunc("/register", processRegister).Methods("POST")
+		s.HandleFunc("/guest", processGuest).Methods("POST")
+		s.HandleFunc("/login/2fa", processTwoFactor).Methods("POST")
+		s.Handle("/2fa", srvHandler(twoFactorStatus)).Methods("GET")
+		s.Handle("/2fa/enroll", srvHandler(twoFactorEnroll)).Methods("POST")
+		s.Handle("/2fa/confirm", srvHandler(twoFactorConfirm)).Methods("POST")
+		s.Handle("/2fa/disable", srvHandler(twoFactorDisable)).Methods("POST")
+		s.Handle("/upgrade", srvHandler(upgradeGuest)).Methods("POST")
 		s.HandleFunc("/logout", processLogout).Methods("POST", "GET")
 		s.HandleFunc("/valid-cookie", validCookie).Methods("GET")
+		s.Handle("/banned-cards", srvHandler(bannedCards)).Methods("GET")
+		s.Handle("/ban-card", srvHandler(banCard)).Methods("POST")
+		s.Handle("/unban-card", srvHandler(unbanCard)).Methods("POST")
+		s.Handle("/set-locale", srvHandler(setLocale)).Methods("POST")
+		s.Handle("/change-password", srvHandler(changePassword)).Methods("POST")
+		s.Handle("/change-username", srvHandler(changeUsername)).Methods("POST")
+		s.Handle("/delete", srvHandler(deleteAccount)).Methods("POST")
+		s.Handle("/languages", srvHandler(languages)).Methods("GET")
+		s.Handle("/{userID}/stats", srvHandler(userStats)).Methods("GET")
+		s.Handle("/sessions", srvHandler(userSessions)).Methods("GET")
+		s.Handle("/sessions/revoke", srvHandler(revokeSessions)).Methods("POST")
+		s.Handle("/force-logout", srvHandler(forceLogout)).Methods("POST")
+		s.HandleFunc("/oidc/login", oidcLogin).Methods("GET")
+		s.HandleFunc("/oidc/link", oidcLink).Methods("GET")
+		s.HandleFunc("/oidc/callback", oidcCallback).Methods("GET")
+		s.HandleFunc("/websocket", userWebsocket).Methods("GET")
+		s.Handle("/tokens", srvHandler(apiTokens)).Methods("GET")
+		s.Handle("/tokens/create", srvHandler(createAPIToken)).Methods("POST")
+		s.Handle("/tokens/revoke", srvHandler(revokeAPIToken)).Methods("POST")
 	}
 
 	{
@@ -91,9 +133,68 @@ func setRestRouterHandlers(r *mux.Router) {
 		s.Handle("/list-in-progress", srvHandler(inProgressGames)).Methods("GET")
 		s.Handle("/create", srvHandler(createGame)).Methods("POST")
 		s.Handle("/join", srvHandler(joinGame)).Methods("POST")
+		s.Handle("/{gameID}/invites", srvHandler(gameInvites)).Methods("GET")
+		s.Handle("/{gameID}/invites/create", srvHandler(createGameInvite)).Methods("POST")
+		s.Handle("/{gameID}/invites/revoke", srvHandler(revokeGameInvite)).Methods("POST")
 		//s.Handle("/Leave", srvHandler(playCards)).Methods("POST")
 		s.Handle("/start", srvHandler(startGame)).Methods("POST")
-		s.Handle("/available-expansions", srvHandler(availableExpansions)).Methods("GET")
+		s.Handle("/available-expansions", srvHandler(listExpansions)).Methods("GET")
+		s.Handle("/expansions-preview", srvHandler(expansionsPreview)).Methods("POST")
+	}
+
+	{
+		s := restRouter.PathPrefix("/cards").Subrouter()
+		s.Handle("/search", srvHandler(searchCards)).Methods("GET")
+		s.Handle("/set-tags", srvHandler(setCardTags)).Methods("POST")
+		s.Handle("/report", srvHandler(reportCard)).Methods("POST")
+		s.Handle("/reports", srvHandler(cardReports)).Methods("GET")
+		s.Handle("/moderate", srvHandler(moderateCard)).Methods("POST")
+	}
+
+	{
+		s := restRouter.PathPrefix("/expansion").Subrouter()
+		s.Handle("/list", srvHandler(listExpansions)).Methods("GET")
+		s.Handle("/upload", srvHandler(uploadExpansion)).Methods("POST")
+		s.Handle("/rename", srvHandler(renameExpansion)).Methods("POST")
+		s.Handle("/delete", srvHandler(deleteExpansion)).Methods("POST")
+	}
+
+	{
+		s := restRouter.PathPrefix("/stats").Subrouter()
+		s.Handle("/card/{cardID}", srvHandler(cardStats)).Methods("GET")
+		s.Handle("/expansions", srvHandler(expansionStats)).Methods("GET")
+		s.Handle("/expansion/{expansion}/cards", srvHandler(expansionCardStats)).Methods("GET")
+		s.Handle("/leaderboard", srvHandler(leaderboard)).Methods("GET")
+	}
+
+	{
+		s := restRouter.PathPrefix("/friends").Subrouter()
+		s.Handle("", srvHandler(friends)).Methods("GET")
+		s.Handle("/request", srvHandler(friendRequest)).Methods("POST")
+		s.Handle("/accept", srvHandler(acceptFriend)).Methods("POST")
+		s.Handle("/decline", srvHandler(declineFriend)).Methods("POST")
+		s.Handle("/remove", srvHandler(removeFriend)).Methods("POST")
+		s.Handle("/block", srvHandler(blockUser)).Methods("POST")
+		s.Handle("/unblock", srvHandler(unblockUser)).Methods("POST")
+		s.Handle("/invite", srvHandler(inviteFriend)).Methods("POST")
+		s.Handle("/invites", srvHandler(invites)).Methods("GET")
+		s.Handle("/invites/accept", srvHandler(acceptInvite)).Methods("POST")
+		s.Handle("/invites/decline", srvHandler(declineInvite)).Methods("POST")
+	}
+
+	{
+		s := restRouter.PathPrefix("/admin").Subrouter()
+		s.Use(adminOnly)
+		s.Handle("/users", srvHandler(adminUsers)).Methods("GET")
+		s.Handle("/users/{userID}/role", srvHandler(adminSetRole)).Methods("POST")
+		s.Handle("/users/{userID}/reset-password", srvHandler(adminResetPassword)).Methods("POST")
+		s.Handle("/users/{userID}/ban", srvHandler(adminBan)).Methods("POST")
+		s.Handle("/games", srvHandler(adminGames)).Methods("GET")
+		s.Handle("/games/{gameID}/end", srvHandler(adminEndGame)).Methods("POST")
+		s.Handle("/expansions", srvHandler(adminExpansions)).Methods("GET")
+		s.Handle("/expansions/upload", srvHandler(uploadExpansion)).Methods("POST")
+		s.Handle("/expansions/rename", srvHandler(renameExpansion)).Methods("POST")
+		s.Handle("/expansions/delete", srvHandler(deleteExpansion)).Methods("POST")
 	}
 
 	{
@@ -102,6 +203,7 @@ func setRestRouterHandlers(r *mux.Router) {
 		s.Handle("/state", srvHandler(gameStateForUser)).Methods("GET")
 		s.Handle("/choose-winner", srvHandler(chooseWinner)).Methods("POST")
 		s.Handle("/play-cards", srvHandler(playCards)).Methods("POST")
+		s.Handle("/round/{round}/image.png", srvHandler(roundImage)).Methods("GET")
 	}
 
 }
@@ -109,6 +211,9 @@ func setRestRouterHandlers(r *mux.Router) {
 func setTemplateRouterHandlers(r *mux.Router) {
 	r.HandleFunc("/", loginPageHandler)
 	r.HandleFunc("/login", loginPageHandler)
+	r.HandleFunc("/login/2fa", twoFactorPageHandler)
+	r.HandleFunc("/game/join/{gameID}", joinGameLink)
+	r.HandleFunc("/game/invite/{token}", gameInviteLink)
 }
 
 func StartServer(r *mux.Router) {
@@ -139,11 +244,27 @@ func serveFrontend(path string) func(http.ResponseWriter, *http.Request) {
 	}
 }
 
+// serverExcludedTags returns the tags from the exclude-tags flag
+func serverExcludedTags() []string {
+	return normalizedTags(strings.Split(excludedTags, ","))
+}
+
+// normalizedTags lowercases and trims the tags the way the cards store them, leaving out empty ones
+func normalizedTags(tags []string) []string {
+	ret := []string{}
+	for _, t := range tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
 type srvHandler func(http.ResponseWriter, *http.Request) error
 
 func (fn srvHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if err := fn(w, req); err != nil {
 		log.Printf("ServeHTTP error: %s", err)
-		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		http.Error(w, i18n.TranslateError(requestLanguage(w, req), err), http.StatusPreconditionFailed)
 	}
 }
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+/*
+CARD STATS
+*/
+
+func cardStats(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	_, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	id, err := strconv.Atoi(mux.Vars(req)["cardID"])
+	if err != nil {
+		return errors.New("The card ID needs to be a number")
+	}
+	stats, err := usecase.Stats.Card(id)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, stats)
+	return nil
+}
+
+// expansionCardStats returns the stats of the white cards of an expansion, best cards first
+func expansionCardStats(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	_, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	stats, err := usecase.Stats.ExpansionCards(mux.Vars(req)["expansion"])
+	if err != nil {
+		return err
+	}
+	writeResponse(w, stats)
+	return nil
+}
+
+/*
+USER STATS
+*/
+
+type userStatsResponse struct {
+	Username string `json:"username"`
+	cah.UserStats
+}
+
+func userStats(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	_, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	id, err := strconv.Atoi(mux.Vars(req)["userID"])
+	if err != nil {
+		return errors.New("The user ID needs to be a number")
+	}
+	u, ok := usecase.User.ByID(id)
+	if !ok {
+		return i18n.Errorf("No user found with ID %d", id)
+	}
+	stats, err := usecase.Stats.User(u.ID)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, userStatsResponse{Username: u.Username, UserStats: stats})
+	return nil
+}
+
+/*
+EXPANSION STATS
+*/
+
+// expansionStats expects the optional query parameter expansion (can be repeated),
+// without it the stats of every expansion are returned
+func expansionStats(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	_, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	exps := req.URL.Query()["expansion"]
+	if len(exps) == 0 {
+		for _, e := range allowedExpansions("") {
+			exps = append(exps, e.Name)
+		}
+		sort.Strings(exps)
+	}
+	writeResponse(w, usecase.Stats.Expansions(exps...))
+	return nil
+}
+
+/*
+LEADERBOARD
+*/
+
+// leaderboardWindows are the time windows of the leaderboard, a zero duration means all-time
+var leaderboardWindows = map[string]time.Duration{
+	"all": 0,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// leaderboard expects the optional query parameters window ("all", "7d" or "30d"),
+// user (can be repeated, to rank only a group of players), offset and limit
+func leaderboard(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	_, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	params := req.URL.Query()
+	var q cah.LeaderboardQuery
+	window := params.Get("window")
+	if window == "" {
+		window = "all"
+	}
+	d, ok := leaderboardWindows[window]
+	if !ok {
+		return i18n.Errorf("The leaderboard window '%s' is not valid", window)
+	}
+	if d != 0 {
+		q.Since = time.Now().Add(-d)
+	}
+	for _, param := range params["user"] {
+		id, err := strconv.Atoi(param)
+		if err != nil {
+			return errors.New("The user ID needs to be a number")
+		}
+		q.UserIDs = append(q.UserIDs, id)
+	}
+	if q.Offset, err = intParam(params.Get("offset")); err != nil {
+		return errors.New("The offset needs to be a number")
+	}
+	if q.Limit, err = intParam(params.Get("limit")); err != nil {
+		return errors.New("The limit needs to be a number")
+	}
+	board, err := usecase.Stats.Leaderboard(q)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, board)
+	return nil
+}
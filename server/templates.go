@@ -21,10 +21,12 @@ var tmplBase = []string{
 
 const (
 	loginPageTmpl tmplID = iota
+	loginTwoFactorPageTmpl
 )
 
 var templateFiles = map[tmplID][]string{
-	loginPageTmpl: append(tmplBase, tmplDir+"login.gohtml"),
+	loginPageTmpl:          append(tmplBase, tmplDir+"login.gohtml"),
+	loginTwoFactorPageTmpl: append(tmplBase, tmplDir+"login-2fa.gohtml"),
 }
 
 // Functions to be called from outside this file to render the templates:
@@ -1,16 +1,29 @@
 package server
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+	"github.com/j4rv/cah/lib/throttle"
 )
 
 const wrongUserOrPassMsg = "The username or password you entered is incorrect."
+const bannedMsg = "Your account has been banned."
 const afterLoginRedirect = "/game/list/open"
 
 const sessionAge = 60 * 15                    // 15 min
@@ -22,8 +35,56 @@ const rememberMeSessionAge = 60 * 60 * 24 * 7 // 1 week
 
 const loginFlashKey = "login-flash"
 
+// guestLifetime is how long guests last after their last request before being deleted, as long as their session
+const guestLifetime = rememberMeSessionAge * time.Second
+const guestCollectInterval = time.Hour
+
+// guestSeenInterval is how often the last request of a guest is stored
+const guestSeenInterval = 10 * time.Minute
+
+type loginPageData struct {
+	Flashes []interface{}
+	// Next is where to go after logging in, like a game join link
+	Next string
+	// OIDCName is the name of the OpenID Connect provider, empty if it is not configured
+	OIDCName string
+}
+
 func loginPageHandler(w http.ResponseWriter, req *http.Request) {
-	execTemplate(loginPageTmpl, w, getFlashes(loginFlashKey, w, req))
+	data := loginPageData{
+		Flashes: getFlashes(loginFlashKey, w, req),
+		Next:    localPath(req.URL.Query().Get("next")),
+	}
+	if oidcEnabled() {
+		data.OIDCName = oidcName
+	}
+	execTemplate(loginPageTmpl, w, data)
+}
+
+// loginPage returns the login page URL, keeping where to go after logging in
+func loginPage(req *http.Request) string {
+	next := localPath(req.Form.Get("next"))
+	if next == "" {
+		return "/login"
+	}
+	return "/login?next=" + url.QueryEscape(next)
+}
+
+// afterLogin returns where to go after logging in
+func afterLogin(req *http.Request) string {
+	if next := localPath(req.Form.Get("next")); next != "" {
+		return next
+	}
+	return afterLoginRedirect
+}
+
+// localPath returns the path if it is inside this server, so the login form
+// can not be used to redirect users to other sites. Otherwise it returns an empty string.
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return ""
+	}
+	return path
 }
 
 func processLogin(w http.ResponseWriter, req *http.Request) {
@@ -34,18 +95,37 @@ func processLogin(w http.ResponseWriter, req *http.Request) {
 		http.Redirect(w, req, "/login", http.StatusFound)
 		return
 	}
+	ip := clientIP(req)
+	if wait := loginWait(username[0], ip); wait > 0 {
+		log.Printf("SECURITY Throttled login attempt for user '%s' from %s", username[0], ip)
+		addFlashMsg(i18n.TranslateError(requestLanguage(w, req), tooManyAttempts(wait)), loginFlashKey, w, req)
+		http.Redirect(w, req, loginPage(req), http.StatusFound)
+		return
+	}
 	u, ok := usecase.User.Login(username[0], password[0])
 	if !ok {
-		addFlashMsg(wrongUserOrPassMsg, loginFlashKey, w, req)
-		http.Redirect(w, req, "/login", http.StatusFound)
+		loginFailed(username[0], ip)
+		addFlashMsg(i18n.T(requestLanguage(w, req), wrongUserOrPassMsg), loginFlashKey, w, req)
+		http.Redirect(w, req, loginPage(req), http.StatusFound)
+		return
+	}
+	if u.Banned {
+		log.Printf("SECURITY Banned user %s with id %d tried to log in from %s", u.Username, u.ID, ip)
+		addFlashMsg(i18n.T(requestLanguage(w, req), bannedMsg), loginFlashKey, w, req)
+		http.Redirect(w, req, loginPage(req), http.StatusFound)
+		return
+	}
+	if usecase.TwoFactor.Enabled(u.ID) {
+		twoFactorStart(u, len(req.Form["rememberme"]) == 1, afterLogin(req), w, req)
 		return
 	}
 	log.Printf("User %s with id %d just logged in!", u.Username, u.ID)
+	loginSucceeded(username[0])
 	if err := sessionStart(u, len(req.Form["rememberme"]) == 1, w, req); err != nil {
 		return
 	}
 	// everything ok, back to index with your brand new session!
-	http.Redirect(w, req, afterLoginRedirect, http.StatusFound)
+	http.Redirect(w, req, afterLogin(req), http.StatusFound)
 }
 
 func processRegister(w http.ResponseWriter, req *http.Request) {
@@ -58,8 +138,8 @@ func processRegister(w http.ResponseWriter, req *http.Request) {
 	}
 	u, err := usecase.User.Register(username[0], password[0])
 	if err != nil {
-		addFlashMsg(err.Error(), loginFlashKey, w, req)
-		http.Redirect(w, req, "/login", http.StatusFound)
+		addFlashMsg(i18n.TranslateError(requestLanguage(w, req), err), loginFlashKey, w, req)
+		http.Redirect(w, req, loginPage(req), http.StatusFound)
 		return
 	}
 	log.Printf("User %s with id %d just registered!", u.Username, u.ID)
@@ -67,11 +147,40 @@ func processRegister(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	// everything ok, back to index with your brand new session!
-	http.Redirect(w, req, afterLoginRedirect, http.StatusFound)
+	http.Redirect(w, req, afterLogin(req), http.StatusFound)
+}
+
+// processGuest logs in a new guest with the chosen nickname.
+// Guests can not log in again, so their session lasts as long as "remember me" sessions.
+func processGuest(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	nickname := req.Form["nickname"]
+	if len(nickname) != 1 {
+		http.Error(w, "Unexpected amount of form vals.", http.StatusUnauthorized)
+		return
+	}
+	u, err := usecase.User.RegisterGuest(nickname[0])
+	if err != nil {
+		addFlashMsg(i18n.TranslateError(requestLanguage(w, req), err), loginFlashKey, w, req)
+		http.Redirect(w, req, loginPage(req), http.StatusFound)
+		return
+	}
+	log.Printf("Guest %s with id %d just joined!", u.Username, u.ID)
+	if err := sessionStart(u, true, w, req); err != nil {
+		return
+	}
+	http.Redirect(w, req, afterLogin(req), http.StatusFound)
 }
 
 func processLogout(w http.ResponseWriter, req *http.Request) {
 	session := getSession(w, req)
+	if serverSessions() {
+		if s, ok := serverSession(session); ok {
+			if err := usecase.Session.Revoke(s.UserID, s.ID); err != nil {
+				log.Printf("ERROR while revoking the session %d: %s", s.ID, err)
+			}
+		}
+	}
 	session.Values = make(map[interface{}]interface{})
 	session.Options.MaxAge = -1
 	err := session.Save(req, w)
@@ -85,9 +194,22 @@ func processLogout(w http.ResponseWriter, req *http.Request) {
 func sessionStart(u cah.User, rememberme bool, w http.ResponseWriter, req *http.Request) error {
 	session := getSession(w, req)
 	session.Values["user_id"] = u.ID
+	for _, k := range twoFactorSessionKeys {
+		delete(session.Values, k)
+	}
+	age := sessionAge
 	if rememberme {
+		age = rememberMeSessionAge
 		session.Options.MaxAge = rememberMeSessionAge
 	}
+	if serverSessions() {
+		_, token, err := usecase.Session.Start(u, time.Duration(age)*time.Second, req.UserAgent(), clientIP(req))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		session.Values["session_token"] = token
+	}
 	err := session.Save(req, w)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -96,13 +218,743 @@ func sessionStart(u cah.User, rememberme bool, w http.ResponseWriter, req *http.
 	return nil
 }
 
+type validCookieResponse struct {
+	cah.User
+	Stats cah.UserStats `json:"stats"`
+}
+
 func validCookie(w http.ResponseWriter, req *http.Request) {
 	u, err := userFromSession(w, req)
 	if err != nil {
 		http.Error(w, "you dont own a valid cookie", http.StatusUnauthorized)
 		return
 	}
+	stats, err := usecase.Stats.User(u.ID)
+	if err != nil {
+		log.Printf("ERROR while getting the stats of user %d: %s", u.ID, err)
+	}
+	writeResponse(w, validCookieResponse{User: u, Stats: stats})
+}
+
+/*
+	ACCOUNT
+*/
+
+type changePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func changePassword(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload changePasswordPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	if err := checkRecentOIDCLogin(u, w, req); err != nil {
+		return err
+	}
+	err = checkingPassword(u, req, func() error {
+		return usecase.User.ChangePassword(u, payload.CurrentPassword, payload.NewPassword)
+	})
+	if err != nil {
+		return err
+	}
+	keepSession, keepToken := currentLogin(w, req)
+	revokeOtherLogins(u.ID, keepSession, keepToken)
+	return nil
+}
+
+type changeUsernamePayload struct {
+	Username string `json:"username"`
+}
+
+// changeUsername renames the logged user and returns the updated user
+func changeUsername(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload changeUsernamePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	u, err = usecase.User.ChangeUsername(u, payload.Username)
+	if err != nil {
+		return err
+	}
 	writeResponse(w, u)
+	return nil
+}
+
+type deleteAccountPayload struct {
+	Password string `json:"password"`
+}
+
+// deleteAccount deletes the logged user, takes them out of their games and logs them out
+func deleteAccount(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload deleteAccountPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	if err := checkRecentOIDCLogin(u, w, req); err != nil {
+		return err
+	}
+	err = checkingPassword(u, req, func() error {
+		return usecase.User.Delete(u, payload.Password)
+	})
+	if err != nil {
+		return err
+	}
+	removeDeletedUser(u)
+	session := getSession(w, req)
+	session.Values = make(map[interface{}]interface{})
+	session.Options.MaxAge = -1
+	return session.Save(req, w)
+}
+
+/*
+	TWO-FACTOR AUTHENTICATION
+*/
+
+const twoFactorFlashKey = "2fa-flash"
+
+// twoFactorTimeout is how long users have to enter their code after entering their password
+const twoFactorTimeout = 5 * time.Minute
+
+// twoFactorSessionKeys hold the login waiting for the code in the cookie session
+var twoFactorSessionKeys = []string{"2fa_user_id", "2fa_rememberme", "2fa_started", "2fa_next"}
+
+// twoFactorStart remembers that the user entered their password, and asks for their code.
+// Next is where to go after entering the code.
+func twoFactorStart(u cah.User, rememberme bool, next string, w http.ResponseWriter, req *http.Request) {
+	session := getSession(w, req)
+	session.Values["2fa_user_id"] = u.ID
+	session.Values["2fa_rememberme"] = rememberme
+	session.Values["2fa_started"] = time.Now().Unix()
+	session.Values["2fa_next"] = next
+	if err := session.Save(req, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, req, "/login/2fa", http.StatusFound)
+}
+
+func twoFactorPageHandler(w http.ResponseWriter, req *http.Request) {
+	execTemplate(loginTwoFactorPageTmpl, w, getFlashes(twoFactorFlashKey, w, req))
+}
+
+// processTwoFactor finishes the login started by processLogin, if the code is right.
+// Wrong codes count as failed logins.
+func processTwoFactor(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	lang := requestLanguage(w, req)
+	session := getSession(w, req)
+	id, _ := session.Values["2fa_user_id"].(int)
+	started, _ := session.Values["2fa_started"].(int64)
+	rememberme, _ := session.Values["2fa_rememberme"].(bool)
+	next, _ := session.Values["2fa_next"].(string)
+	u, ok := usecase.User.ByID(id)
+	if !ok || time.Since(time.Unix(started, 0)) > twoFactorTimeout {
+		addFlashMsg(i18n.T(lang, "Your login expired, please log in again."), loginFlashKey, w, req)
+		http.Redirect(w, req, "/login", http.StatusFound)
+		return
+	}
+	ip := clientIP(req)
+	if wait := loginWait(u.Username, ip); wait > 0 {
+		log.Printf("SECURITY Throttled two-factor code for user '%s' from %s", u.Username, ip)
+		addFlashMsg(i18n.TranslateError(lang, tooManyAttempts(wait)), twoFactorFlashKey, w, req)
+		http.Redirect(w, req, "/login/2fa", http.StatusFound)
+		return
+	}
+	if !usecase.TwoFactor.Verify(u.ID, req.Form.Get("code")) {
+		log.Printf("SECURITY Wrong two-factor code for user '%s' from %s", u.Username, ip)
+		loginFailed(u.Username, ip)
+		addFlashMsg(i18n.T(lang, "The code you entered is incorrect."), twoFactorFlashKey, w, req)
+		http.Redirect(w, req, "/login/2fa", http.StatusFound)
+		return
+	}
+	log.Printf("User %s with id %d just logged in with two-factor authentication!", u.Username, u.ID)
+	loginSucceeded(u.Username)
+	if err := sessionStart(u, rememberme, w, req); err != nil {
+		return
+	}
+	if localPath(next) == "" {
+		next = afterLoginRedirect
+	}
+	http.Redirect(w, req, localPath(next), http.StatusFound)
+}
+
+type twoFactorResponse struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recoveryCodes"`
+}
+
+func twoFactorStatus(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	n, err := usecase.TwoFactor.RecoveryCodes(u.ID)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, twoFactorResponse{Enabled: usecase.TwoFactor.Enabled(u.ID), RecoveryCodes: n})
+	return nil
+}
+
+// twoFactorEnroll returns a new secret and its otpauth URI, to be confirmed with twoFactorConfirm
+func twoFactorEnroll(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	enrollment, err := usecase.TwoFactor.Enroll(u)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, enrollment)
+	return nil
+}
+
+type twoFactorCodePayload struct {
+	Code string `json:"code"`
+}
+
+// twoFactorConfirm enables the two-factor authentication and returns the recovery codes,
+// which are only shown this time
+func twoFactorConfirm(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload twoFactorCodePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	var codes []string
+	err = checkingPassword(u, req, func() (err error) {
+		codes, err = usecase.TwoFactor.Confirm(u, payload.Code)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	writeResponse(w, codes)
+	return nil
+}
+
+func twoFactorDisable(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload twoFactorCodePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	return checkingPassword(u, req, func() error {
+		return usecase.TwoFactor.Disable(u, payload.Code)
+	})
+}
+
+/*
+	LOGIN THROTTLING
+*/
+
+// accountLimiter slows down guessing the password of an account, locking it for 15 minutes at most.
+// ipLimiter allows more attempts, since many users can share an IP, but it
+// stops a single IP from trying a few passwords on every account.
+var accountLimiter = throttle.New(5, 30*time.Second, 15*time.Minute)
+var ipLimiter = throttle.New(20, 30*time.Second, time.Hour)
+
+func accountKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginWait returns how long the account or the IP have to wait before trying to log in again
+func loginWait(username, ip string) time.Duration {
+	now := time.Now()
+	wait := accountLimiter.Wait(accountKey(username), now)
+	if ipWait := ipLimiter.Wait(ip, now); ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+// checkingPassword throttles the actions that check the current password or a two-factor code
+// of the user like the logins, so a stolen session or API token can not be used to guess them
+func checkingPassword(u cah.User, req *http.Request, action func() error) error {
+	ip := clientIP(req)
+	if wait := loginWait(u.Username, ip); wait > 0 {
+		log.Printf("SECURITY Throttled password check for user '%s' from %s", u.Username, ip)
+		return tooManyAttempts(wait)
+	}
+	err := action()
+	if err == cah.ErrWrongPassword || err == cah.ErrWrongCode {
+		log.Printf("SECURITY Wrong password or code check for user '%s' from %s", u.Username, ip)
+		loginFailed(u.Username, ip)
+	}
+	return err
+}
+
+func loginFailed(username, ip string) {
+	now := time.Now()
+	if wait := accountLimiter.Fail(accountKey(username), now); wait > 0 {
+		log.Printf("SECURITY User '%s' locked for %s after %d failed logins, the last one from %s",
+			username, wait, accountLimiter.Failures(accountKey(username)), ip)
+	}
+	if wait := ipLimiter.Fail(ip, now); wait > 0 {
+		log.Printf("SECURITY IP %s locked for %s after %d failed logins, the last one as '%s'",
+			ip, wait, ipLimiter.Failures(ip), username)
+	}
+}
+
+// loginSucceeded forgets the failures of the account. The IP failures are kept,
+// otherwise logging in to an own account would allow guessing other passwords.
+func loginSucceeded(username string) {
+	accountLimiter.Reset(accountKey(username))
+}
+
+func tooManyAttempts(wait time.Duration) error {
+	minutes := int(math.Ceil(wait.Minutes()))
+	return i18n.Errorf("Too many failed login attempts. Please try again in %d minute(s).", minutes)
+}
+
+// removeDeletedUser takes a deleted user out of their games and revokes their sessions
+func removeDeletedUser(u cah.User) {
+	if err := usecase.Game.RemoveUser(u); err != nil {
+		log.Printf("ERROR while removing the deleted user %d from their games: %s", u.ID, err)
+	}
+	if serverSessions() {
+		if err := usecase.Session.RevokeAll(u.ID); err != nil {
+			log.Printf("ERROR while revoking the sessions of the deleted user %d: %s", u.ID, err)
+		}
+	}
+}
+
+// currentLogin returns the IDs of the server side session or the API token of the request, 0 if it has none
+func currentLogin(w http.ResponseWriter, req *http.Request) (sessionID, tokenID int) {
+	if token, ok := bearerToken(req); ok {
+		t, _ := usecase.APIToken.ByToken(token)
+		return 0, t.ID
+	}
+	if serverSessions() {
+		s, _ := serverSession(getSession(w, req))
+		return s.ID, 0
+	}
+	return 0, 0
+}
+
+// revokeOtherLogins revokes the sessions and API tokens of the user but the ones kept,
+// so whoever had them can not keep using the account once its password changed
+func revokeOtherLogins(userID, keepSession, keepToken int) {
+	if serverSessions() {
+		sessions, err := usecase.Session.ByUser(userID)
+		if err != nil {
+			log.Printf("ERROR while listing the sessions of the user %d: %s", userID, err)
+		}
+		ids := []int{}
+		for _, s := range sessions {
+			if s.ID != keepSession {
+				ids = append(ids, s.ID)
+			}
+		}
+		if len(ids) != 0 {
+			if err := usecase.Session.Revoke(userID, ids...); err != nil {
+				log.Printf("ERROR while revoking the sessions of the user %d: %s", userID, err)
+			}
+		}
+	}
+	tokens, err := usecase.APIToken.ByUser(userID)
+	if err != nil {
+		log.Printf("ERROR while listing the API tokens of the user %d: %s", userID, err)
+	}
+	for _, t := range tokens {
+		if t.ID == keepToken {
+			continue
+		}
+		if err := usecase.APIToken.Revoke(userID, t.ID); err != nil {
+			log.Printf("ERROR while revoking the API token %d of the user %d: %s", t.ID, userID, err)
+		}
+	}
+}
+
+/*
+	GUESTS
+*/
+
+type upgradeGuestPayload struct {
+	// Username is optional, without it the guest keeps their nickname
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// upgradeGuest turns the logged guest into a full user and returns the updated user
+func upgradeGuest(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload upgradeGuestPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	u, err = usecase.User.Upgrade(u, payload.Username, payload.Password)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, u)
+	return nil
+}
+
+// guestsSeen holds the last time the request of each guest was stored
+var guestsSeen = struct {
+	sync.Mutex
+	at map[int]time.Time
+}{at: map[int]time.Time{}}
+
+// guestSeen stores that the guest is still active, once every guestSeenInterval
+func guestSeen(u cah.User) {
+	if !u.Guest {
+		return
+	}
+	guestsSeen.Lock()
+	now := time.Now()
+	if now.Sub(guestsSeen.at[u.ID]) < guestSeenInterval {
+		guestsSeen.Unlock()
+		return
+	}
+	guestsSeen.at[u.ID] = now
+	guestsSeen.Unlock()
+	if err := usecase.User.GuestSeen(u); err != nil {
+		log.Printf("ERROR while storing that the guest %d was seen: %s", u.ID, err)
+	}
+}
+
+// collectGuests deletes the guests that were inactive for longer than their session every interval
+func collectGuests(interval time.Duration) {
+	for {
+		guests, err := usecase.User.DeleteGuests(time.Now().Add(-guestLifetime))
+		if err != nil {
+			log.Printf("ERROR while deleting the inactive guests: %s", err)
+		}
+		for _, g := range guests {
+			log.Printf("Deleted the guest %s with id %d", g.Username, g.ID)
+			removeDeletedUser(g)
+			guestsSeen.Lock()
+			delete(guestsSeen.at, g.ID)
+			guestsSeen.Unlock()
+		}
+		time.Sleep(interval)
+	}
+}
+
+/*
+	LOCALE
+*/
+
+type setLocalePayload struct {
+	Locale string `json:"locale"`
+}
+
+// setLocale changes the language of the user, an empty locale goes back to the browser language
+func setLocale(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload setLocalePayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	return usecase.User.SetLocale(u, payload.Locale)
+}
+
+func languages(w http.ResponseWriter, req *http.Request) error {
+	writeResponse(w, i18n.Languages())
+	return nil
+}
+
+// requestLanguage returns the language chosen by the logged user,
+// or the one preferred by the browser if the user did not choose any
+func requestLanguage(w http.ResponseWriter, req *http.Request) string {
+	if u, err := userFromSession(w, req); err == nil && u.Locale != "" {
+		return u.Locale
+	}
+	return i18n.Match(req.Header.Get("Accept-Language"))
+}
+
+// isAdmin reports if the user has the admin role. Guests are never admins.
+// With the admins-need-2fa flag, admins also need two-factor authentication.
+func isAdmin(u cah.User) bool {
+	if u.Guest {
+		return false
+	}
+	if adminsNeed2FA && !usecase.TwoFactor.Enabled(u.ID) {
+		return false
+	}
+	return u.Role == cah.RoleAdmin
+}
+
+// promoteAdmins gives the admin role to the users of the admins flag, which is useful to create the first admin.
+// It only runs on startup: usernames can change, so they are not trusted on every request.
+func promoteAdmins() {
+	for _, name := range strings.Split(adminNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		u, ok := usecase.User.ByName(name)
+		if !ok || u.Guest {
+			log.Printf("Could not give admin privileges to '%s', there is no user with that name", name)
+			continue
+		}
+		if u.Role == cah.RoleAdmin {
+			continue
+		}
+		if err := usecase.User.SetRole(u.ID, cah.RoleAdmin); err != nil {
+			log.Printf("ERROR while giving admin privileges to '%s': %s", name, err)
+			continue
+		}
+		log.Printf("User '%s' with id %d is now an admin", u.Username, u.ID)
+	}
+}
+
+// isModerator reports if the user can moderate the cards, which admins can do too
+func isModerator(u cah.User) bool {
+	return (u.Role == cah.RoleModerator && !u.Guest) || isAdmin(u)
+}
+
+/*
+	SERVER SESSIONS
+*/
+
+type sessionResponse struct {
+	cah.Session
+	Current bool `json:"current"`
+}
+
+// userSessions lists the sessions of the user, marking the one used by the request
+func userSessions(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	if !serverSessions() {
+		return errors.New("Server side sessions are disabled")
+	}
+	current, _ := serverSession(getSession(w, req))
+	sessions, err := usecase.Session.ByUser(u.ID)
+	if err != nil {
+		return err
+	}
+	res := make([]sessionResponse, len(sessions))
+	for i, s := range sessions {
+		res[i] = sessionResponse{Session: s, Current: s.ID == current.ID}
+	}
+	writeResponse(w, res)
+	return nil
+}
+
+type revokeSessionsPayload struct {
+	IDs []int `json:"ids"`
+	// Others revokes every session but the one used by the request
+	Others bool `json:"others"`
+}
+
+func revokeSessions(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	if !serverSessions() {
+		return errors.New("Server side sessions are disabled")
+	}
+	// Decode user's payload
+	var payload revokeSessionsPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	ids := payload.IDs
+	if payload.Others {
+		current, _ := serverSession(getSession(w, req))
+		sessions, err := usecase.Session.ByUser(u.ID)
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			if s.ID != current.ID {
+				ids = append(ids, s.ID)
+			}
+		}
+	}
+	return usecase.Session.Revoke(u.ID, ids...)
+}
+
+type forceLogoutPayload struct {
+	UserID int `json:"userID"`
+}
+
+// forceLogout revokes every session of a user, only admins can use it
+func forceLogout(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	if !isAdmin(u) {
+		http.Error(w, "Only admins can force a logout", http.StatusForbidden)
+		return nil
+	}
+	if !serverSessions() {
+		return errors.New("Server side sessions are disabled")
+	}
+	// Decode user's payload
+	var payload forceLogoutPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	target, ok := usecase.User.ByID(payload.UserID)
+	if !ok {
+		return i18n.Errorf("No user found with ID %d", payload.UserID)
+	}
+	log.Printf("Admin %s forced the logout of user %s with id %d", u.Username, target.Username, target.ID)
+	return usecase.Session.RevokeAll(target.ID)
+}
+
+/*
+	API TOKENS
+*/
+
+func apiTokens(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	tokens, err := usecase.APIToken.ByUser(u.ID)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, tokens)
+	return nil
+}
+
+type createAPITokenPayload struct {
+	Name string `json:"name"`
+}
+
+type createAPITokenResponse struct {
+	cah.APIToken
+	// Token is the secret, it is only shown once
+	Token string `json:"token"`
+}
+
+// createAPIToken needs a cookie login, so a leaked token can not be used to create more of them
+func createAPIToken(w http.ResponseWriter, req *http.Request) error {
+	if _, ok := bearerToken(req); ok {
+		http.Error(w, "API tokens cannot create other tokens", http.StatusForbidden)
+		return nil
+	}
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload createAPITokenPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	t, token, err := usecase.APIToken.Create(u, payload.Name)
+	if err != nil {
+		return err
+	}
+	log.Printf("SECURITY User %s with id %d created the API token %d '%s'", u.Username, u.ID, t.ID, t.Name)
+	writeResponse(w, createAPITokenResponse{APIToken: t, Token: token})
+	return nil
+}
+
+type revokeAPITokenPayload struct {
+	ID int `json:"id"`
+}
+
+func revokeAPIToken(w http.ResponseWriter, req *http.Request) error {
+	// User is logged
+	u, err := userFromSession(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	// Decode user's payload
+	var payload revokeAPITokenPayload
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return errors.New("Misconstructed payload")
+	}
+	log.Printf("SECURITY User %s with id %d revokes the API token %d", u.Username, u.ID, payload.ID)
+	return usecase.APIToken.Revoke(u.ID, payload.ID)
 }
 
 /*
@@ -112,33 +964,143 @@ func validCookie(w http.ResponseWriter, req *http.Request) {
 var cookies *sessions.CookieStore
 
 func init() {
-	skey := securecookie.GenerateRandomKey(64)
-	encKey := securecookie.GenerateRandomKey(32)
-	cookies = sessions.NewCookieStore(skey, encKey)
+	keyPairs, err := sessionKeyPairs(os.Getenv("SESSION_KEYS"))
+	if err != nil {
+		log.Fatal("Invalid SESSION_KEYS environment variable: ", err)
+	}
+	if len(keyPairs) == 0 {
+		log.Println("Session keys not found, every session will be lost on restart. Environment variable: SESSION_KEYS")
+		keyPairs = [][]byte{securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32)}
+	}
+	cookies = sessions.NewCookieStore(keyPairs...)
 	cookies.MaxAge(sessionAge) //15m
 }
 
+// sessionKeyPairs parses a comma separated list of "hashKey:encryptionKey" pairs encoded in base64.
+// New cookies are signed with the first pair, the other ones are only used to read cookies
+// signed before rotating the keys. Hash keys should have 64 bytes and encryption keys 32.
+func sessionKeyPairs(keys string) ([][]byte, error) {
+	ret := [][]byte{}
+	for _, pair := range strings.Split(keys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, errors.New("every key pair needs a hash key and an encryption key separated by ':'")
+		}
+		hashKey, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("hash key is not valid base64: %s", err)
+		}
+		if len(hashKey) < 32 {
+			return nil, errors.New("hash keys need at least 32 bytes")
+		}
+		encKey, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("encryption key is not valid base64: %s", err)
+		}
+		if l := len(encKey); l != 16 && l != 24 && l != 32 {
+			return nil, errors.New("encryption keys need 16, 24 or 32 bytes")
+		}
+		ret = append(ret, hashKey, encKey)
+	}
+	return ret, nil
+}
+
+// serverSessions reports if the sessions are stored in the server, so they can be listed and revoked
+func serverSessions() bool {
+	return useServerSessions && usecase.Session != nil
+}
+
+// serverSession returns the server side session of the cookie, if it is still valid
+func serverSession(session *sessions.Session) (cah.Session, bool) {
+	token, ok := session.Values["session_token"].(string)
+	if !ok {
+		return cah.Session{}, false
+	}
+	return usecase.Session.ByToken(token)
+}
+
+// clientIP returns the IP of the request without the port. Behind a trusted proxy, like
+// the Heroku router, it is the last IP of the X-Forwarded-For header, the one added by the proxy.
+// The previous ones are sent by the client, so they can not be trusted.
+func clientIP(req *http.Request) string {
+	if trustedProxy {
+		forwarded := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
 func userFromSession(w http.ResponseWriter, req *http.Request) (cah.User, error) {
+	if token, ok := bearerToken(req); ok {
+		return userFromAPIToken(token)
+	}
 	session := getSession(w, req)
 	val, ok := session.Values["user_id"]
 	if !ok {
-		return cah.User{}, fmt.Errorf("Tried to get user from session without an id")
+		return cah.User{}, errors.New("Tried to get user from session without an id")
 	}
 	id, ok := val.(int)
 	if !ok {
 		log.Printf("Session with non int id value: '%v'", session.Values)
-		return cah.User{}, fmt.Errorf("Session with non int id value")
+		return cah.User{}, errors.New("Session with non int id value")
 	}
 	u, ok := usecase.User.ByID(id)
 	if !ok {
-		return u, fmt.Errorf("No user found with ID %d", id)
+		return u, i18n.Errorf("No user found with ID %d", id)
+	}
+	if u.Banned {
+		return cah.User{}, errors.New(bannedMsg)
+	}
+	if serverSessions() {
+		if s, ok := serverSession(session); !ok || s.UserID != id {
+			return cah.User{}, errors.New("Your session expired or was revoked")
+		}
 	}
 	session.Save(req, w)
+	presenceSeen(u.ID)
+	guestSeen(u)
 	return u, nil
 }
 
+// userFromAPIToken returns the owner of the API token, for requests sent by bots and scripts
+func userFromAPIToken(token string) (cah.User, error) {
+	t, ok := usecase.APIToken.ByToken(token)
+	if !ok {
+		return cah.User{}, errors.New("Your API token is not valid or was revoked")
+	}
+	u, ok := usecase.User.ByID(t.UserID)
+	if !ok {
+		return u, i18n.Errorf("No user found with ID %d", t.UserID)
+	}
+	if u.Banned {
+		return cah.User{}, errors.New(bannedMsg)
+	}
+	presenceSeen(u.ID)
+	return u, nil
+}
+
+// bearerToken returns the token of the "Authorization: Bearer" header, if the request has one
+func bearerToken(req *http.Request) (string, bool) {
+	auth := req.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
 func getSession(w http.ResponseWriter, req *http.Request) *sessions.Session {
-	// The CookieStore keys change on every server startup, so we ignore "cookies.Get" errors
+	// Cookies signed with keys that are no longer used give errors, so we ignore "cookies.Get" errors
 	session, _ := cookies.Get(req, "session_token")
 	return session
 }
@@ -0,0 +1,41 @@
+package cah
+
+import (
+	"time"
+)
+
+// SessionStore keeps the server side sessions. Tokens are stored hashed,
+// so a leaked database can not be used to log in as its users.
+type SessionStore interface {
+	Create(s Session) (Session, error)
+	ByToken(tokenHash string) (Session, error)
+	ByUser(userID int) ([]Session, error)
+	Touch(id int, lastSeen, expiresAt time.Time) error
+	Delete(ids ...int) error
+	DeleteByUser(userID int) error
+	DeleteExpired(now time.Time) error
+}
+
+type SessionUsecases interface {
+	// Start creates a session for the user and returns it along with its secret token
+	Start(u User, age time.Duration, userAgent, ip string) (s Session, token string, err error)
+	// ByToken returns the session of the token, if it was not revoked and did not expire
+	ByToken(token string) (Session, bool)
+	ByUser(userID int) ([]Session, error)
+	Revoke(userID int, ids ...int) error
+	RevokeAll(userID int) error
+}
+
+// Session is a server side session, which can be listed and revoked.
+// Age is how long the session lasts without being used.
+type Session struct {
+	ID         int           `json:"id" db:"session"`
+	UserID     int           `json:"-" db:"user"`
+	TokenHash  string        `json:"-" db:"token"`
+	UserAgent  string        `json:"userAgent" db:"user_agent"`
+	IP         string        `json:"ip" db:"ip"`
+	Age        time.Duration `json:"-" db:"age"`
+	CreatedAt  time.Time     `json:"createdAt" db:"-"`
+	LastSeenAt time.Time     `json:"lastSeenAt" db:"-"`
+	ExpiresAt  time.Time     `json:"expiresAt" db:"-"`
+}
@@ -0,0 +1,117 @@
+package cah
+
+import "time"
+
+// StatsStore keeps how many times the white cards were dealt, played and won,
+// when each user saw each card for the last time, and the results of the finished games
+type StatsStore interface {
+	AddDealt(cardIDs ...int) error
+	AddPlayed(cardIDs ...int) error
+	AddWon(cardIDs ...int) error
+	CardStats(cardIDs ...int) (map[int]CardStats, error)
+	AddSeen(userID int, cardIDs ...int) error
+	Seen(since time.Time, userIDs ...int) ([]int, error)
+	// AddGameResults adds the rating change to the rating of each player, so the changes
+	// of games finished at the same time are not lost. Rating is used for players without one.
+	AddGameResults(results ...GameResult) error
+	UserStats(userID int, favourites int) (UserStats, error)
+	Ratings(userIDs ...int) (map[int]float64, error)
+	Leaderboard(LeaderboardQuery) (Leaderboard, error)
+}
+
+type StatsUsecases interface {
+	Card(cardID int) (CardStats, error)
+	ExpansionCards(expansion string) ([]CardStats, error)
+	Expansions(expansions ...string) []ExpansionStats
+	RecentlySeen(users ...User) []int
+	User(userID int) (UserStats, error)
+	Leaderboard(LeaderboardQuery) (Leaderboard, error)
+}
+
+// CardStats holds the stats of a white card.
+// WinRate is Won divided by Played, and PlayRate is Played divided by Dealt.
+type CardStats struct {
+	CardID    int     `json:"id" db:"card"`
+	Text      string  `json:"text" db:"-"`
+	Expansion string  `json:"expansion" db:"-"`
+	Dealt     int     `json:"dealt" db:"dealt"`
+	Played    int     `json:"played" db:"played"`
+	Won       int     `json:"won" db:"won"`
+	WinRate   float64 `json:"winRate" db:"-"`
+	PlayRate  float64 `json:"playRate" db:"-"`
+}
+
+// ExpansionStats adds up the stats of the white cards of an expansion.
+// NeverWon is the amount of cards that were played but never won.
+type ExpansionStats struct {
+	Name     string  `json:"name"`
+	Cards    int     `json:"cards"`
+	Dealt    int     `json:"dealt"`
+	Played   int     `json:"played"`
+	Won      int     `json:"won"`
+	WinRate  float64 `json:"winRate"`
+	PlayRate float64 `json:"playRate"`
+	NeverWon int     `json:"neverWon"`
+}
+
+// GameResult is the result of a finished game for one of its players.
+// WinningCards are the white cards that won the player's rounds.
+// Rating is the rating of the player after the game, and RatingChange how much it changed.
+type GameResult struct {
+	UserID       int
+	GameStateID  int
+	Won          bool
+	RoundsWon    int
+	CardsPlayed  int
+	WinningCards []int
+	Rating       float64
+	RatingChange float64
+	FinishedAt   time.Time
+}
+
+// UserStats adds up the results of the games a user finished.
+// FavouriteCards are the white cards that won the user the most rounds.
+type UserStats struct {
+	UserID         int             `json:"userID" db:"user"`
+	GamesPlayed    int             `json:"gamesPlayed" db:"games_played"`
+	GamesWon       int             `json:"gamesWon" db:"games_won"`
+	RoundsWon      int             `json:"roundsWon" db:"rounds_won"`
+	CardsPlayed    int             `json:"cardsPlayed" db:"cards_played"`
+	FavouriteCards []FavouriteCard `json:"favouriteCards" db:"-"`
+}
+
+// FavouriteCard is a white card and the amount of rounds it won for a user
+type FavouriteCard struct {
+	CardID    int    `json:"id" db:"card"`
+	Text      string `json:"text" db:"-"`
+	Expansion string `json:"expansion" db:"-"`
+	Wins      int    `json:"wins" db:"wins"`
+}
+
+// LeaderboardQuery selects the players of a leaderboard.
+// Only the games finished after Since are counted, a zero Since counts every game.
+// With UserIDs, only those users are ranked, like the usual players of a game night.
+type LeaderboardQuery struct {
+	Since   time.Time
+	UserIDs []int
+	Offset  int
+	Limit   int
+}
+
+// Leaderboard holds a page of the ranked players, Total is the amount of players in all the pages.
+// All-time leaderboards are sorted by rating, and the ones with a time window by the rating won during it.
+type Leaderboard struct {
+	Total   int                `json:"total"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardEntry counts the games and the rating change inside the leaderboard time window
+type LeaderboardEntry struct {
+	Rank         int     `json:"rank" db:"-"`
+	UserID       int     `json:"userID" db:"user"`
+	Username     string  `json:"username" db:"username"`
+	Rating       float64 `json:"rating" db:"rating"`
+	RatingChange float64 `json:"ratingChange" db:"rating_change"`
+	GamesPlayed  int     `json:"gamesPlayed" db:"games_played"`
+	GamesWon     int     `json:"gamesWon" db:"games_won"`
+}
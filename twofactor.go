@@ -0,0 +1,44 @@
+package cah
+
+import "errors"
+
+// ErrWrongCode is returned when a two-factor code, asked to confirm a change, is not correct
+var ErrWrongCode = errors.New("The code you entered is incorrect.")
+
+// TwoFactorStore keeps the TOTP secrets of the users and their recovery codes, which are stored hashed
+type TwoFactorStore interface {
+	TwoFactor(userID int) (TwoFactor, error)
+	SetTwoFactor(TwoFactor) error
+	DeleteTwoFactor(userID int) error
+	SetRecoveryCodes(userID int, hashes ...string) error
+	// UseRecoveryCode deletes the recovery code, reporting if the user had it
+	UseRecoveryCode(userID int, hash string) (bool, error)
+	RecoveryCodes(userID int) (int, error)
+}
+
+type TwoFactorUsecases interface {
+	// Enroll creates a new secret for the user, which is not used until it is confirmed
+	Enroll(u User) (TwoFactorEnrollment, error)
+	// Confirm enables the two-factor authentication with a code, and returns the recovery codes
+	Confirm(u User, code string) ([]string, error)
+	Disable(u User, code string) error
+	Enabled(userID int) bool
+	// Verify checks a code or a recovery code, which can only be used once
+	Verify(userID int, code string) bool
+	RecoveryCodes(userID int) (int, error)
+}
+
+// TwoFactor is the TOTP configuration of a user. LastStep is the time step of the last code used,
+// so the same code can not be used twice.
+type TwoFactor struct {
+	UserID   int    `db:"user"`
+	Secret   string `db:"secret"`
+	Enabled  bool   `db:"enabled"`
+	LastStep int64  `db:"last_step"`
+}
+
+// TwoFactorEnrollment has what an authenticator app needs, the URI is usually shown as a QR code
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
@@ -5,4 +5,11 @@ type Usecases struct {
 	GameState GameStateUsecases
 	Card      CardUsecases
 	User      UserUsecases
+	Stats     StatsUsecases
+	// Session is optional, without it the sessions only live in the cookies
+	Session    SessionUsecases
+	TwoFactor  TwoFactorUsecases
+	APIToken   APITokenUsecases
+	Friend     FriendUsecases
+	GameInvite GameInviteUsecases
 }
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+// apiTokenPrefix makes the API tokens easy to recognise, like in leaked logs or commits
+const apiTokenPrefix = "cah_"
+const maxAPITokens = 20
+const maxAPITokenNameLength = 64
+
+type apiTokenController struct {
+	store cah.APITokenStore
+}
+
+func NewAPITokenUsecase(store cah.APITokenStore) *apiTokenController {
+	return &apiTokenController{store: store}
+}
+
+// Create makes a new API token for the user. Guests can not have API tokens, since their accounts are temporary.
+func (control apiTokenController) Create(u cah.User, name string) (cah.APIToken, string, error) {
+	if u.Guest {
+		return cah.APIToken{}, "", errors.New("Guests cannot create API tokens")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return cah.APIToken{}, "", errors.New("The token name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > maxAPITokenNameLength {
+		return cah.APIToken{}, "", i18n.Errorf("Token names cannot be longer than %d characters.", maxAPITokenNameLength)
+	}
+	tokens, err := control.store.ByUser(u.ID)
+	if err != nil {
+		return cah.APIToken{}, "", err
+	}
+	if len(tokens) >= maxAPITokens {
+		return cah.APIToken{}, "", i18n.Errorf("You cannot have more than %d API tokens", maxAPITokens)
+	}
+	secret, err := newToken()
+	if err != nil {
+		return cah.APIToken{}, "", err
+	}
+	token := apiTokenPrefix + secret
+	t, err := control.store.Create(cah.APIToken{
+		UserID:    u.ID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		CreatedAt: time.Now(),
+	})
+	return t, token, err
+}
+
+// ByToken returns the API token of the secret, storing when it was used
+func (control apiTokenController) ByToken(token string) (cah.APIToken, bool) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return cah.APIToken{}, false
+	}
+	t, err := control.store.ByToken(hashToken(token))
+	if err != nil {
+		return t, false
+	}
+	now := time.Now()
+	if now.Sub(t.LastUsedAt) >= sessionTouchInterval {
+		t.LastUsedAt = now
+		checkErr(control.store.Touch(t.ID, now), "apiTokenController.ByToken")
+	}
+	return t, true
+}
+
+func (control apiTokenController) ByUser(userID int) ([]cah.APIToken, error) {
+	return control.store.ByUser(userID)
+}
+
+// Revoke deletes an API token of the user, failing if it belongs to someone else
+func (control apiTokenController) Revoke(userID int, id int) error {
+	tokens, err := control.store.ByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ID == id {
+			return control.store.Delete(id)
+		}
+	}
+	return i18n.Errorf("No API token found with ID %d", id)
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokens(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.NewAPITokenStore()
+	control := NewAPITokenUsecase(store)
+	u := cah.User{ID: 1}
+
+	_, _, err := control.Create(cah.User{ID: 2, Guest: true}, "bot")
+	assert.Error(err, "Guests should not create API tokens")
+	_, _, err = control.Create(u, "  ")
+	assert.Error(err)
+	_, _, err = control.Create(u, strings.Repeat("x", maxAPITokenNameLength+1))
+	assert.Error(err)
+
+	created, token, err := control.Create(u, " bot ")
+	assert.NoError(err)
+	assert.Equal("bot", created.Name)
+	assert.True(strings.HasPrefix(token, apiTokenPrefix))
+	stored, err := store.ByUser(u.ID)
+	assert.NoError(err)
+	assert.NotEqual(token, stored[0].TokenHash, "Tokens should be stored hashed")
+	assert.True(created.LastUsedAt.IsZero())
+
+	found, ok := control.ByToken(token)
+	assert.True(ok)
+	assert.Equal(created.ID, found.ID)
+	stored, _ = store.ByUser(u.ID)
+	assert.False(stored[0].LastUsedAt.IsZero(), "The use of the token should be stored")
+	_, ok = control.ByToken(token + "x")
+	assert.False(ok)
+	_, ok = control.ByToken(stored[0].TokenHash)
+	assert.False(ok, "The stored hash should not work as a token")
+
+	assert.Error(control.Revoke(2, created.ID), "Users should not revoke the tokens of others")
+	assert.NoError(control.Revoke(u.ID, created.ID))
+	_, ok = control.ByToken(token)
+	assert.False(ok)
+}
+
+func TestAPITokensLimit(t *testing.T) {
+	assert := assert.New(t)
+	control := NewAPITokenUsecase(mem.NewAPITokenStore())
+	u := cah.User{ID: 1}
+	for i := 0; i < maxAPITokens; i++ {
+		_, _, err := control.Create(u, "bot")
+		assert.NoError(err)
+	}
+	_, _, err := control.Create(u, "bot")
+	assert.Error(err)
+}
@@ -1,22 +1,41 @@
 package usecase
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
 )
 
+const maxExpansionNameLength = 40
+const maxExpansionFileSize = 1 << 20 // 1 MiB
+const maxTagLength = 20
+const maxReportReasonLength = 200
+
 type cardController struct {
-	store cah.CardStore
+	store      cah.CardStore
+	bans       cah.CardBanStore
+	moderation cah.CardModerationStore
+	dir        string
 }
 
-func NewCardUsecase(store cah.CardStore) *cardController {
-	return &cardController{store: store}
+// NewCardUsecase returns the card usecases.
+// Uploaded expansions are stored as folders inside expansionsDir.
+func NewCardUsecase(store cah.CardStore, bans cah.CardBanStore, moderation cah.CardModerationStore, expansionsDir string) *cardController {
+	return &cardController{store: store, bans: bans, moderation: moderation, dir: expansionsDir}
 }
 
 func (cc cardController) AllBlacks() []*cah.BlackCard {
@@ -31,6 +50,14 @@ func (cc cardController) AllWhites() []*cah.WhiteCard {
 	return res
 }
 
+func (cc cardController) WhitesByID(ids ...int) ([]*cah.WhiteCard, error) {
+	return cc.store.WhitesByID(ids...)
+}
+
+func (cc cardController) BlacksByID(ids ...int) ([]*cah.BlackCard, error) {
+	return cc.store.BlacksByID(ids...)
+}
+
 func (cc cardController) ExpansionWhites(exps ...string) []*cah.WhiteCard {
 	res, err := cc.store.ExpansionWhites(exps...)
 	checkErr(err, "cardController.ExpansionWhites")
@@ -49,40 +76,142 @@ func (cc cardController) AvailableExpansions() []string {
 	return res
 }
 
+func (cc cardController) Expansion(name string) (cah.Expansion, error) {
+	return cc.store.Expansion(name)
+}
+
+func (cc cardController) Search(q cah.CardSearch) (cah.CardSearchResult, error) {
+	return cc.store.Search(q)
+}
+
+// BanCard adds a card to the user's ban list.
+// Banned cards are removed from the decks of the games the user owns.
+func (cc cardController) BanCard(u cah.User, cardID int) error {
+	if err := cc.checkCardExists(cardID); err != nil {
+		return err
+	}
+	return cc.bans.Ban(u.ID, cardID)
+}
+
+func (cc cardController) UnbanCard(u cah.User, cardID int) error {
+	return cc.bans.Unban(u.ID, cardID)
+}
+
+func (cc cardController) BannedCards(u cah.User) []int {
+	res, err := cc.bans.Banned(u.ID)
+	checkErr(err, "cardController.BannedCards")
+	return res
+}
+
+// SetTags replaces the tags of a card. The new tags are kept
+// when the card is loaded again, overriding the tags from the expansion files.
+func (cc cardController) SetTags(cardID int, tags []string) error {
+	normalized := normalizeTags(tags)
+	for _, t := range normalized {
+		if len(t) > maxTagLength {
+			return i18n.Errorf("Tags cannot be longer than %d", maxTagLength)
+		}
+	}
+	if err := cc.store.SetTags(cardID, normalized); err != nil {
+		return err
+	}
+	if err := cc.moderation.SetTags(cardID, normalized); err != nil {
+		return err
+	}
+	return cc.moderation.ResolveReports(cardID)
+}
+
+// ReportCard adds the card to the moderation queue, with the reason given by the user
+func (cc cardController) ReportCard(u cah.User, cardID int, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New("Please write the reason of the report")
+	}
+	if len(reason) > maxReportReasonLength {
+		return i18n.Errorf("The reason cannot be longer than %d", maxReportReasonLength)
+	}
+	if err := cc.checkCardExists(cardID); err != nil {
+		return err
+	}
+	return cc.moderation.Report(u.ID, cardID, reason)
+}
+
+// CardReports returns the reports not resolved yet
+func (cc cardController) CardReports() []cah.CardReport {
+	res, err := cc.moderation.Reports()
+	checkErr(err, "cardController.CardReports")
+	return res
+}
+
+// DismissReports resolves the reports of a card without changing it
+func (cc cardController) DismissReports(cardID int) error {
+	return cc.moderation.ResolveReports(cardID)
+}
+
+// EditCard replaces the text of a card, the blanks of black cards are counted again.
+// The new text is kept when the card is loaded again.
+func (cc cardController) EditCard(cardID int, text string) error {
+	text = strings.TrimSpace(text)
+	if err := cc.store.SetText(cardID, text, countBlanks(text)); err != nil {
+		return err
+	}
+	if err := cc.moderation.SetText(cardID, text); err != nil {
+		return err
+	}
+	return cc.moderation.ResolveReports(cardID)
+}
+
+// DisableCard disables or enables a card. Disabled cards are not added to the decks
+// of new games, running games keep the cards they already have in their decks.
+func (cc cardController) DisableCard(cardID int, disabled bool) error {
+	if err := cc.store.SetDisabled(cardID, disabled); err != nil {
+		return err
+	}
+	if err := cc.moderation.SetDisabled(cardID, disabled); err != nil {
+		return err
+	}
+	return cc.moderation.ResolveReports(cardID)
+}
+
+func (cc cardController) checkCardExists(cardID int) error {
+	_, werr := cc.store.WhitesByID(cardID)
+	_, berr := cc.store.BlacksByID(cardID)
+	if werr != nil && berr != nil {
+		return i18n.Errorf("No card found with ID %d", cardID)
+	}
+	return nil
+}
+
+func (cc cardController) Expansions() []cah.Expansion {
+	res, err := cc.store.Expansions()
+	checkErr(err, "cardController.Expansions")
+	return res
+}
+
 // CreateFromReaders creates and stores cards from two readers.
 // The reader should provide a card per line. A line can contain "\n"s for card line breaks.
+// A line can end with the card tags between double brackets, like "Some card. [[nsfw, political]]"
 // Lines containing only whitespace are ignored
 func (cc cardController) CreateFromReaders(wdat, bdat io.Reader, expansionName string) error {
-	// Create cards from files
-	var err error
-	err = doEveryLine(wdat, func(t string) {
-		text := strings.TrimSpace(t)
-		if text == "" || string([]rune(text)[0]) == "#" {
-			return
-		}
-		cc.store.CreateWhite(text, expansionName)
-	})
+	whites, blacks, err := readCards(wdat, bdat, expansionName)
 	if err != nil {
 		return err
 	}
-	err = doEveryLine(bdat, func(t string) {
-		text := strings.TrimSpace(t)
-		if text == "" || string([]rune(text)[0]) == "#" {
-			return
-		}
-		blanks := strings.Count(t, "_")
-		if blanks == 0 {
-			blanks = 1
-		}
-		cc.store.CreateBlack(text, expansionName, blanks)
-	})
+	for _, c := range whites {
+		checkErr(cc.store.CreateWhite(c.Text, expansionName, c.Tags...), "cardController.CreateFromReaders")
+	}
+	for _, c := range blacks {
+		checkErr(cc.store.CreateBlack(c.Text, expansionName, c.Blanks, c.Tags...), "cardController.CreateFromReaders")
+	}
 	log.Println("Successfully loaded cards from expansion " + expansionName)
-	return err
+	return nil
 }
 
 // CreateFromFolder creates and stores cards from an expansion folder
 // That folder should contain two files called 'white.md' and 'black.md'
 // The files content is treated as explained for the CreateCards function
+// An optional 'info.json' file can hold the expansion metadata
+// If the expansion was already stored, its cards are replaced
 func (cc cardController) CreateFromFolder(folderPath, expansionName string) error {
 	wdat, err := os.Open(fmt.Sprintf("%s/white.md", folderPath))
 	defer wdat.Close()
@@ -94,7 +223,416 @@ func (cc cardController) CreateFromFolder(folderPath, expansionName string) erro
 	if err != nil {
 		return err
 	}
-	return cc.CreateFromReaders(wdat, bdat, expansionName)
+	info, err := readExpansionInfo(folderPath)
+	if err != nil {
+		return err
+	}
+	info.Name = expansionName
+	whites, blacks, err := readCards(wdat, bdat, expansionName)
+	if err != nil {
+		return err
+	}
+	for _, c := range whites {
+		c.Tags = normalizeTags(append(c.Tags, info.Tags...))
+	}
+	for _, c := range blacks {
+		c.Tags = normalizeTags(append(c.Tags, info.Tags...))
+	}
+	err = cc.store.ReplaceExpansion(info, whites, blacks)
+	if err != nil {
+		return err
+	}
+	cc.applyModeration()
+	log.Println("Successfully loaded cards from expansion " + expansionName)
+	return nil
+}
+
+// WatchExpansions loads every expansion folder and then keeps polling the
+// expansions folder every interval, so added, modified or removed expansions
+// are updated in the store without restarting the server.
+// Running games keep the cards they already have in their decks.
+// The polling stops when the context is done.
+func (cc cardController) WatchExpansions(ctx context.Context, interval time.Duration) error {
+	if _, err := ioutil.ReadDir(cc.dir); err != nil {
+		return err
+	}
+	known := map[string]string{}
+	cc.syncExpansions(known)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cc.syncExpansions(known)
+			}
+		}
+	}()
+	return nil
+}
+
+// syncExpansions loads the expansion folders whose files changed since the last call
+// and removes the expansions whose folder does not exist anymore.
+// known maps each expansion folder to the signature of its files.
+func (cc cardController) syncExpansions(known map[string]string) {
+	files, err := ioutil.ReadDir(cc.dir)
+	if err != nil {
+		log.Printf("ERROR while reading the expansions folder: %s", err)
+		return
+	}
+	found := map[string]bool{}
+	for _, f := range files {
+		// Hidden folders are used while uploading expansions
+		if !f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		name := f.Name()
+		found[name] = true
+		sig := expansionFolderSignature(filepath.Join(cc.dir, name))
+		if sig == known[name] {
+			continue
+		}
+		known[name] = sig
+		log.Println("Loading cards from", name)
+		err := cc.CreateFromFolder(filepath.Join(cc.dir, name), name)
+		checkErr(err, "cardController.syncExpansions")
+	}
+	for name := range known {
+		if found[name] {
+			continue
+		}
+		delete(known, name)
+		log.Println("Expansion folder removed, unloading", name)
+		// The expansion may have been deleted or renamed already
+		cc.store.DeleteExpansion(name)
+	}
+}
+
+func expansionFolderSignature(folderPath string) string {
+	var sig strings.Builder
+	for _, name := range []string{"white.md", "black.md", expansionInfoFile} {
+		info, err := os.Stat(filepath.Join(folderPath, name))
+		if err != nil {
+			sig.WriteString("-;")
+			continue
+		}
+		fmt.Fprintf(&sig, "%d:%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return sig.String()
+}
+
+// Upload stores a new expansion in the expansions folder and loads its cards.
+// The expansion name, language and description are taken from info.
+// The files content is treated as explained for the CreateCards function
+func (cc cardController) Upload(owner cah.User, info cah.Expansion, wdat, bdat io.Reader) error {
+	name := strings.TrimSpace(info.Name)
+	if err := cc.checkNewExpansionName(name); err != nil {
+		return err
+	}
+	// A random key, so the IDs of its cards are not shared with a renamed expansion that had this name
+	key, err := newToken()
+	if err != nil {
+		return err
+	}
+	info.Key = key
+	// The files are written to a hidden folder first, so the expansions watcher
+	// never sees a half written expansion
+	tmpPath := filepath.Join(cc.dir, "."+name+".upload")
+	folderPath := filepath.Join(cc.dir, name)
+	if err := os.Mkdir(tmpPath, 0755); err != nil {
+		log.Printf("ERROR while creating the expansion folder %s: %s", tmpPath, err)
+		return errors.New("The expansion could not be stored")
+	}
+	info.Name = name
+	info.OwnerID = owner.ID
+	info.Language = strings.ToLower(strings.TrimSpace(info.Language))
+	info.Description = strings.TrimSpace(info.Description)
+	info.Tags = normalizeTags(info.Tags)
+	err = writeExpansionFolder(tmpPath, info, wdat, bdat)
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, folderPath); err != nil {
+		log.Printf("ERROR while moving the expansion folder %s: %s", tmpPath, err)
+		os.RemoveAll(tmpPath)
+		return errors.New("The expansion could not be stored")
+	}
+	if err := cc.CreateFromFolder(folderPath, name); err != nil {
+		os.RemoveAll(folderPath)
+		cc.store.DeleteExpansion(name)
+		return err
+	}
+	log.Printf("User '%s' uploaded the expansion '%s'", owner.Username, name)
+	return nil
+}
+
+// UploadZip works like Upload, but the cards are read from
+// the 'white.md' and 'black.md' files inside a zip file
+func (cc cardController) UploadZip(owner cah.User, info cah.Expansion, zdat io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(zdat, size)
+	if err != nil {
+		return errors.New("The uploaded file is not a valid zip file")
+	}
+	var wfile, bfile *zip.File
+	for _, f := range zr.File {
+		switch strings.ToLower(filepath.Base(f.Name)) {
+		case "white.md", "white.txt":
+			wfile = f
+		case "black.md", "black.txt":
+			bfile = f
+		}
+	}
+	if wfile == nil || bfile == nil {
+		return errors.New("The zip file needs to contain a 'white.md' and a 'black.md' file")
+	}
+	wdat, err := wfile.Open()
+	if err != nil {
+		return err
+	}
+	defer wdat.Close()
+	bdat, err := bfile.Open()
+	if err != nil {
+		return err
+	}
+	defer bdat.Close()
+	return cc.Upload(owner, info, wdat, bdat)
+}
+
+// RenameExpansion renames both the expansion folder (if any) and the stored expansion
+func (cc cardController) RenameExpansion(oldName, newName string) error {
+	name := strings.TrimSpace(newName)
+	if err := cc.checkNewExpansionName(name); err != nil {
+		return err
+	}
+	e, err := cc.store.Expansion(oldName)
+	if err != nil {
+		return err
+	}
+	oldPath := filepath.Join(cc.dir, oldName)
+	if _, err := os.Stat(oldPath); err == nil {
+		// The watcher loads the renamed folder again, its cards need the same key to keep their IDs
+		if err := keepExpansionKey(oldPath, e.Key); err != nil {
+			log.Printf("ERROR while storing the key of the expansion folder %s: %s", oldPath, err)
+			return errors.New("The expansion could not be renamed")
+		}
+		if err := os.Rename(oldPath, filepath.Join(cc.dir, name)); err != nil {
+			log.Printf("ERROR while renaming the expansion folder %s: %s", oldPath, err)
+			return errors.New("The expansion could not be renamed")
+		}
+	}
+	return cc.store.RenameExpansion(oldName, name)
+}
+
+// DeleteExpansion deletes both the expansion folder (if any) and the stored expansion
+func (cc cardController) DeleteExpansion(name string) error {
+	if _, err := cc.store.Expansion(name); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(cc.dir, name)); err != nil {
+		log.Printf("ERROR while deleting the expansion folder %s: %s", name, err)
+		return errors.New("The expansion could not be deleted")
+	}
+	return cc.store.DeleteExpansion(name)
+}
+
+// applyModeration sets again the changes made by moderators,
+// since reloading an expansion creates its cards again
+func (cc cardController) applyModeration() {
+	// Cards from other expansions, or deleted ones, are not found
+	tags, err := cc.moderation.Tags()
+	checkErr(err, "cardController.applyModeration")
+	for id, t := range tags {
+		cc.store.SetTags(id, t)
+	}
+	texts, err := cc.moderation.Texts()
+	checkErr(err, "cardController.applyModeration")
+	for id, t := range texts {
+		cc.store.SetText(id, t, countBlanks(t))
+	}
+	disabled, err := cc.moderation.Disabled()
+	checkErr(err, "cardController.applyModeration")
+	for _, id := range disabled {
+		cc.store.SetDisabled(id, true)
+	}
+}
+
+func (cc cardController) checkNewExpansionName(name string) error {
+	if name == "" {
+		return errors.New("The expansion name cannot be empty")
+	}
+	if len(name) > maxExpansionNameLength {
+		return i18n.Errorf("The expansion name cannot be longer than %d", maxExpansionNameLength)
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\:*?"<>|`) {
+		return errors.New("The expansion name contains non valid characters")
+	}
+	if _, err := cc.store.Expansion(name); err == nil {
+		return errors.New("An expansion with that name already exists. Please try another.")
+	}
+	return nil
+}
+
+const expansionInfoFile = "info.json"
+
+type expansionInfo struct {
+	Key         string   `json:"key,omitempty"`
+	Owner       int      `json:"owner,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+func readExpansionInfo(folderPath string) (cah.Expansion, error) {
+	info, err := readExpansionInfoFile(folderPath)
+	if err != nil {
+		return cah.Expansion{}, err
+	}
+	return cah.Expansion{
+		Key:         info.Key,
+		OwnerID:     info.Owner,
+		Language:    info.Language,
+		Description: info.Description,
+		Tags:        normalizeTags(info.Tags),
+	}, nil
+}
+
+// readExpansionInfoFile returns an empty info if the folder does not have the file
+func readExpansionInfoFile(folderPath string) (expansionInfo, error) {
+	var info expansionInfo
+	dat, err := ioutil.ReadFile(filepath.Join(folderPath, expansionInfoFile))
+	if os.IsNotExist(err) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(dat, &info); err != nil {
+		return info, fmt.Errorf("non valid %s in %s: %s", expansionInfoFile, folderPath, err)
+	}
+	return info, nil
+}
+
+func writeExpansionInfoFile(folderPath string, info expansionInfo) error {
+	dat, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(folderPath, expansionInfoFile), dat, 0644)
+}
+
+// keepExpansionKey stores the key in the info file of the folder if it has none,
+// so its cards keep their IDs when the folder is loaded with another name
+func keepExpansionKey(folderPath, key string) error {
+	info, err := readExpansionInfoFile(folderPath)
+	if err != nil || info.Key != "" {
+		return err
+	}
+	info.Key = key
+	return writeExpansionInfoFile(folderPath, info)
+}
+
+func writeExpansionFolder(folderPath string, e cah.Expansion, wdat, bdat io.Reader) error {
+	files := []struct {
+		name string
+		dat  io.Reader
+	}{
+		{"white.md", wdat},
+		{"black.md", bdat},
+	}
+	for _, f := range files {
+		dat, err := ioutil.ReadAll(io.LimitReader(f.dat, maxExpansionFileSize+1))
+		if err != nil {
+			return err
+		}
+		if len(dat) > maxExpansionFileSize {
+			return i18n.Errorf("The file %s cannot be bigger than %d bytes", f.name, maxExpansionFileSize)
+		}
+		if len(bytes.TrimSpace(dat)) == 0 {
+			return i18n.Errorf("The file %s cannot be empty", f.name)
+		}
+		if err := ioutil.WriteFile(filepath.Join(folderPath, f.name), dat, 0644); err != nil {
+			return err
+		}
+	}
+	return writeExpansionInfoFile(folderPath, expansionInfo{
+		Key:         e.Key,
+		Owner:       e.OwnerID,
+		Language:    e.Language,
+		Description: e.Description,
+		Tags:        e.Tags,
+	})
+}
+
+// readCards reads the cards from two readers as explained for the CreateFromReaders function
+func readCards(wdat, bdat io.Reader, expansionName string) ([]*cah.WhiteCard, []*cah.BlackCard, error) {
+	whites := []*cah.WhiteCard{}
+	err := doEveryCardLine(wdat, func(line string) {
+		text, tags := splitCardTags(line)
+		whites = append(whites, &cah.WhiteCard{Text: text, Expansion: expansionName, Tags: tags})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	blacks := []*cah.BlackCard{}
+	err = doEveryCardLine(bdat, func(line string) {
+		text, tags := splitCardTags(line)
+		blacks = append(blacks, &cah.BlackCard{Text: text, Expansion: expansionName, Blanks: countBlanks(text), Tags: tags})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return whites, blacks, nil
+}
+
+// countBlanks returns the blanks of a black card text, cards without blanks have one at the end
+func countBlanks(text string) int {
+	blanks := strings.Count(text, "_")
+	if blanks == 0 {
+		blanks = 1
+	}
+	return blanks
+}
+
+// splitCardTags separates the card text from the tags between double brackets at the end of the line
+func splitCardTags(line string) (string, []string) {
+	if !strings.HasSuffix(line, "]]") {
+		return line, nil
+	}
+	start := strings.LastIndex(line, "[[")
+	if start == -1 {
+		return line, nil
+	}
+	tags := strings.Split(line[start+2:len(line)-2], ",")
+	return strings.TrimSpace(line[:start]), normalizeTags(tags)
+}
+
+// normalizeTags lower cases and trims the tags, removing empty and repeated ones
+func normalizeTags(tags []string) []string {
+	ret := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+// doEveryCardLine ignores empty and comment lines
+func doEveryCardLine(r io.Reader, fun func(string)) error {
+	return doEveryLine(r, func(t string) {
+		text := strings.TrimSpace(t)
+		if text == "" || string([]rune(text)[0]) == "#" {
+			return
+		}
+		fun(text)
+	})
 }
 
 func doEveryLine(r io.Reader, fun func(string)) error {
@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+)
+
+func getCardUsecase(t *testing.T) (*cardController, func()) {
+	dir, err := ioutil.TempDir("", "cah-expansions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCardUsecase(mem.GetCardStore(), nil, mem.NewModerationStore(), dir), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCardUpload(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	owner := cah.User{ID: 7, Username: "Uploader"}
+
+	info := cah.Expansion{Name: "Uploaded", Language: " ES", Description: "Test cards"}
+	err := uc.Upload(owner, info, strings.NewReader("A white card\nAnother one"), strings.NewReader("A _ black card\n_ and _"))
+	assert.NoError(err)
+	assert.Len(uc.ExpansionWhites("Uploaded"), 2)
+	assert.Len(uc.ExpansionBlacks("Uploaded"), 2)
+	e, err := uc.Expansion("Uploaded")
+	assert.NoError(err)
+	assert.Equal(7, e.OwnerID)
+	assert.Equal("es", e.Language)
+	assert.Equal("Test cards", e.Description)
+	assert.Equal(2, e.Whites)
+	assert.Equal(2, e.Blacks)
+	assert.Equal(map[int]int{1: 1, 2: 1}, e.Picks)
+	_, err = os.Stat(filepath.Join(uc.dir, "Uploaded", "info.json"))
+	assert.NoError(err)
+
+	err = uc.Upload(owner, cah.Expansion{Name: "Uploaded"}, strings.NewReader("Dupe"), strings.NewReader("Dupe _"))
+	assert.Error(err, "Expected an error when uploading an already existing expansion")
+	err = uc.Upload(owner, cah.Expansion{Name: "../escape"}, strings.NewReader("Card"), strings.NewReader("Card _"))
+	assert.Error(err, "Expected an error for a non valid expansion name")
+	err = uc.Upload(owner, cah.Expansion{Name: "Empty"}, strings.NewReader(""), strings.NewReader("Card _"))
+	assert.Error(err, "Expected an error for an empty file")
+	_, err = uc.Expansion("Empty")
+	assert.Error(err, "A failed upload should not be stored")
+}
+
+func TestCardRenameAndDelete(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	owner := cah.User{ID: 7, Username: "Uploader"}
+	assert.NoError(uc.Upload(owner, cah.Expansion{Name: "To rename"}, strings.NewReader("White"), strings.NewReader("Black _")))
+
+	id := uc.ExpansionWhites("To rename")[0].ID
+
+	assert.NoError(uc.RenameExpansion("To rename", "Renamed"))
+	_, err := uc.Expansion("To rename")
+	assert.Error(err)
+	whites := uc.ExpansionWhites("Renamed")
+	assert.Len(whites, 1)
+	assert.Equal("Renamed", whites[0].Expansion)
+	assert.Equal(id, whites[0].ID, "Renamed cards should keep their IDs")
+	_, err = os.Stat(filepath.Join(uc.dir, "Renamed", "white.md"))
+	assert.NoError(err)
+	// Like the watcher does with the renamed folder
+	assert.NoError(uc.CreateFromFolder(filepath.Join(uc.dir, "Renamed"), "Renamed"))
+	assert.Equal(id, uc.ExpansionWhites("Renamed")[0].ID, "Reloaded cards should keep their IDs")
+
+	assert.NoError(uc.DeleteExpansion("Renamed"))
+	assert.Len(uc.ExpansionWhites("Renamed"), 0)
+	_, err = os.Stat(filepath.Join(uc.dir, "Renamed"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestCardRenameFolderWithoutKey(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	folder := filepath.Join(uc.dir, "Keyless")
+	assert.NoError(os.Mkdir(folder, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(folder, "white.md"), []byte("Keyless white"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(folder, "black.md"), []byte("Keyless _"), 0644))
+	assert.NoError(uc.CreateFromFolder(folder, "Keyless"))
+	id := uc.ExpansionBlacks("Keyless")[0].ID
+
+	assert.NoError(uc.RenameExpansion("Keyless", "Keyed"))
+	assert.NoError(uc.CreateFromFolder(filepath.Join(uc.dir, "Keyed"), "Keyed"))
+	assert.Equal(id, uc.ExpansionBlacks("Keyed")[0].ID, "The old name should be kept as the key of the expansion")
+	assert.NoError(uc.DeleteExpansion("Keyed"))
+}
+
+func TestCardSyncExpansions(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	folder := filepath.Join(uc.dir, "Watched")
+	writeFile := func(name, content string) {
+		assert.NoError(ioutil.WriteFile(filepath.Join(folder, name), []byte(content), 0644))
+	}
+	assert.NoError(os.Mkdir(folder, 0755))
+	writeFile("white.md", "First white")
+	writeFile("black.md", "First _")
+
+	known := map[string]string{}
+	uc.syncExpansions(known)
+	deck := uc.ExpansionWhites("Watched")
+	assert.Len(deck, 1)
+
+	writeFile("white.md", "First white\nSecond white, a bit longer")
+	uc.syncExpansions(known)
+	assert.Len(uc.ExpansionWhites("Watched"), 2)
+	assert.Equal("First white", deck[0].Text, "Old decks should keep their cards")
+
+	assert.NoError(os.RemoveAll(folder))
+	uc.syncExpansions(known)
+	_, err := uc.Expansion("Watched")
+	assert.Error(err, "Removed expansion folders should be unloaded")
+}
+
+func TestCardWatchExpansionsStops(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(uc.WatchExpansions(ctx, 10*time.Millisecond))
+	cancel()
+	// Lets a sync that was already running finish
+	time.Sleep(50 * time.Millisecond)
+
+	folder := filepath.Join(uc.dir, "Not watched")
+	assert.NoError(os.Mkdir(folder, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(folder, "white.md"), []byte("Not watched white"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(folder, "black.md"), []byte("Not watched _"), 0644))
+	time.Sleep(50 * time.Millisecond)
+	_, err := uc.Expansion("Not watched")
+	assert.Error(err, "The expansions should not be polled after the context is done")
+}
+
+func TestCardTags(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	info := cah.Expansion{Name: "Tagged", Tags: []string{"Language"}}
+	wdat := strings.NewReader("Clean card.\nDirty card. [[NSFW, political]]")
+	bdat := strings.NewReader("A _ with [[brackets]] inside.")
+	assert.NoError(uc.Upload(cah.User{ID: 1}, info, wdat, bdat))
+
+	whites := uc.ExpansionWhites("Tagged")
+	assert.Equal("Clean card.", whites[0].Text)
+	assert.Equal([]string{"language"}, whites[0].Tags)
+	assert.Equal("Dirty card.", whites[1].Text)
+	assert.Equal([]string{"nsfw", "political", "language"}, whites[1].Tags)
+	blacks := uc.ExpansionBlacks("Tagged")
+	assert.Equal("A _ with [[brackets]] inside.", blacks[0].Text)
+
+	assert.NoError(uc.SetTags(whites[0].ID, []string{"nsfw"}))
+	assert.NoError(uc.CreateFromFolder(filepath.Join(uc.dir, "Tagged"), "Tagged"))
+	reloaded, err := uc.WhitesByID(whites[0].ID)
+	assert.NoError(err)
+	assert.Equal([]string{"nsfw"}, reloaded[0].Tags, "Moderator tags should be kept after reloading")
+}
+
+func TestCardModeration(t *testing.T) {
+	assert := assert.New(t)
+	uc, teardown := getCardUsecase(t)
+	defer teardown()
+	wdat := strings.NewReader("Fine card.\nOffensive card.")
+	bdat := strings.NewReader("Broken _ card.")
+	assert.NoError(uc.Upload(cah.User{ID: 1}, cah.Expansion{Name: "Moderated"}, wdat, bdat))
+	whites := uc.ExpansionWhites("Moderated")
+	black := uc.ExpansionBlacks("Moderated")[0]
+	player := cah.User{ID: 2}
+
+	assert.Error(uc.ReportCard(player, whites[1].ID, "  "))
+	assert.Error(uc.ReportCard(player, 12345, "Does not exist"))
+	assert.NoError(uc.ReportCard(player, whites[1].ID, "Offensive"))
+	assert.NoError(uc.ReportCard(player, black.ID, "Needs two blanks"))
+	assert.Len(uc.CardReports(), 2)
+
+	assert.NoError(uc.DisableCard(whites[1].ID, true))
+	assert.Len(uc.ExpansionWhites("Moderated"), 1)
+	assert.NoError(uc.EditCard(black.ID, "Fixed _ and _ card."))
+	assert.Equal(1, black.Blanks, "Running games should keep the card they have")
+	assert.Equal(2, uc.ExpansionBlacks("Moderated")[0].Blanks)
+	assert.Len(uc.CardReports(), 0)
+	e, err := uc.Expansion("Moderated")
+	assert.NoError(err)
+	assert.Equal(1, e.Whites)
+
+	assert.NoError(uc.CreateFromFolder(filepath.Join(uc.dir, "Moderated"), "Moderated"))
+	assert.Len(uc.ExpansionWhites("Moderated"), 1, "Disabled cards should stay disabled after reloading")
+	reloaded := uc.ExpansionBlacks("Moderated")[0]
+	assert.Equal(black.ID, reloaded.ID)
+	assert.Equal("Fixed _ and _ card.", reloaded.Text)
+	assert.Equal(2, reloaded.Blanks)
+}
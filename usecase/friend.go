@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+// maxFriends limits the friends and pending requests of each user
+const maxFriends = 200
+
+type friendController struct {
+	store cah.FriendStore
+	users cah.UserStore
+}
+
+func NewFriendUsecase(store cah.FriendStore, users cah.UserStore) *friendController {
+	return &friendController{store: store, users: users}
+}
+
+// Request sends a friend request. If the other user already sent one, both become friends.
+// Guests can not have friends, since their accounts are temporary.
+func (control friendController) Request(u cah.User, friendID int) error {
+	friend, err := control.other(u, friendID)
+	if err != nil {
+		return err
+	}
+	if control.status(friend.ID, u.ID) == cah.FriendBlocked || control.status(u.ID, friend.ID) == cah.FriendBlocked {
+		return errors.New("You cannot send a friend request to that user")
+	}
+	switch control.status(u.ID, friend.ID) {
+	case cah.FriendAccepted:
+		return errors.New("You are already friends")
+	case cah.FriendPending:
+		return errors.New("You already sent a friend request to that user")
+	}
+	if control.status(friend.ID, u.ID) == cah.FriendPending {
+		return control.Accept(u, friend.ID)
+	}
+	if err := control.checkLimit(u.ID); err != nil {
+		return err
+	}
+	log.Printf("User '%s' sends a friend request to '%s'", u.Username, friend.Username)
+	return control.store.Set(u.ID, friend.ID, cah.FriendPending, time.Now())
+}
+
+func (control friendController) Accept(u cah.User, requesterID int) error {
+	if control.status(requesterID, u.ID) != cah.FriendPending {
+		return i18n.Errorf("No friend request found from the user with ID %d", requesterID)
+	}
+	if err := control.checkLimit(u.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := control.store.Set(requesterID, u.ID, cah.FriendAccepted, now); err != nil {
+		return err
+	}
+	return control.store.Set(u.ID, requesterID, cah.FriendAccepted, now)
+}
+
+func (control friendController) Decline(u cah.User, requesterID int) error {
+	if control.status(requesterID, u.ID) != cah.FriendPending {
+		return i18n.Errorf("No friend request found from the user with ID %d", requesterID)
+	}
+	return control.store.Delete(requesterID, u.ID)
+}
+
+func (control friendController) Remove(u cah.User, friendID int) error {
+	switch control.status(u.ID, friendID) {
+	case cah.FriendAccepted:
+		if err := control.store.Delete(friendID, u.ID); err != nil {
+			return err
+		}
+		return control.store.Delete(u.ID, friendID)
+	case cah.FriendPending:
+		return control.store.Delete(u.ID, friendID)
+	}
+	return i18n.Errorf("No friend found with ID %d", friendID)
+}
+
+// Block ends the friendship and the requests between both users, and stops new ones.
+// The blocked user is not told about it.
+func (control friendController) Block(u cah.User, userID int) error {
+	blocked, err := control.users.ByID(userID)
+	if err != nil || blocked.ID == u.ID {
+		return i18n.Errorf("No user found with ID %d", userID)
+	}
+	if control.status(blocked.ID, u.ID) != cah.FriendBlocked {
+		if err := control.store.Delete(blocked.ID, u.ID); err != nil {
+			return err
+		}
+	}
+	log.Printf("User '%s' blocks '%s'", u.Username, blocked.Username)
+	return control.store.Set(u.ID, blocked.ID, cah.FriendBlocked, time.Now())
+}
+
+func (control friendController) Unblock(u cah.User, userID int) error {
+	if control.status(u.ID, userID) != cah.FriendBlocked {
+		return i18n.Errorf("No blocked user found with ID %d", userID)
+	}
+	return control.store.Delete(u.ID, userID)
+}
+
+// List returns the friends, requests and blocked users of the user
+func (control friendController) List(u cah.User) (cah.Friends, error) {
+	res := cah.Friends{Friends: []cah.Friend{}, Incoming: []cah.Friend{}, Outgoing: []cah.Friend{}, Blocked: []cah.Friend{}}
+	from, err := control.store.From(u.ID)
+	if err != nil {
+		return res, err
+	}
+	for _, f := range from {
+		friend, ok := control.friend(f.FriendID, f.Since)
+		if !ok {
+			continue
+		}
+		switch f.Status {
+		case cah.FriendAccepted:
+			res.Friends = append(res.Friends, friend)
+		case cah.FriendPending:
+			res.Outgoing = append(res.Outgoing, friend)
+		case cah.FriendBlocked:
+			res.Blocked = append(res.Blocked, friend)
+		}
+	}
+	to, err := control.store.To(u.ID)
+	if err != nil {
+		return res, err
+	}
+	for _, f := range to {
+		if f.Status != cah.FriendPending {
+			continue
+		}
+		if friend, ok := control.friend(f.UserID, f.Since); ok {
+			res.Incoming = append(res.Incoming, friend)
+		}
+	}
+	return res, nil
+}
+
+func (control friendController) AreFriends(userID, otherID int) bool {
+	return control.status(userID, otherID) == cah.FriendAccepted
+}
+
+// internal
+
+// other returns the user to send a request to, as long as both users can have friends
+func (control friendController) other(u cah.User, otherID int) (cah.User, error) {
+	if u.Guest {
+		return cah.User{}, errors.New("Guests cannot have friends")
+	}
+	other, err := control.users.ByID(otherID)
+	if err != nil || other.ID == u.ID {
+		return cah.User{}, i18n.Errorf("No user found with ID %d", otherID)
+	}
+	if other.Guest {
+		return cah.User{}, errors.New("Guests cannot have friends")
+	}
+	return other, nil
+}
+
+// status returns the status of the relation from one user to another, empty if there is none
+func (control friendController) status(from, to int) cah.FriendStatus {
+	f, err := control.store.Get(from, to)
+	if err != nil {
+		return ""
+	}
+	return f.Status
+}
+
+func (control friendController) checkLimit(userID int) error {
+	from, err := control.store.From(userID)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, f := range from {
+		if f.Status != cah.FriendBlocked {
+			n++
+		}
+	}
+	if n >= maxFriends {
+		return i18n.Errorf("You cannot have more than %d friends", maxFriends)
+	}
+	return nil
+}
+
+func (control friendController) friend(userID int, since time.Time) (cah.Friend, bool) {
+	u, err := control.users.ByID(userID)
+	if err != nil {
+		return cah.Friend{}, false
+	}
+	return cah.Friend{UserID: u.ID, Username: u.Username, Since: since}, true
+}
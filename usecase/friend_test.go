@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func getFriendUsecase(t *testing.T, names ...string) (cah.FriendUsecases, []cah.User) {
+	users := getUserUsecase()
+	ret := make([]cah.User, len(names))
+	for i, name := range names {
+		u, err := users.Register(name, "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret[i] = u
+	}
+	return NewFriendUsecase(mem.NewFriendStore(), mem.GetUserStore()), ret
+}
+
+func TestFriendRequests(t *testing.T) {
+	assert := assert.New(t)
+	control, users := getFriendUsecase(t, "Friend A", "Friend B", "Friend C")
+	a, b, c := users[0], users[1], users[2]
+
+	assert.Error(control.Request(a, a.ID), "Users should not befriend themselves")
+	assert.Error(control.Request(a, -1))
+	assert.NoError(control.Request(a, b.ID))
+	assert.Error(control.Request(a, b.ID), "Requests should not be sent twice")
+	assert.False(control.AreFriends(a.ID, b.ID))
+
+	list, err := control.List(b)
+	assert.NoError(err)
+	assert.Len(list.Incoming, 1)
+	assert.Equal("Friend A", list.Incoming[0].Username)
+	list, _ = control.List(a)
+	assert.Len(list.Outgoing, 1)
+
+	assert.Error(control.Accept(a, b.ID), "Only the requested user can accept")
+	assert.NoError(control.Accept(b, a.ID))
+	assert.True(control.AreFriends(a.ID, b.ID))
+	assert.True(control.AreFriends(b.ID, a.ID))
+	assert.Error(control.Request(b, a.ID), "Friends should not send requests")
+
+	assert.NoError(control.Request(c, a.ID))
+	assert.NoError(control.Request(a, c.ID), "A request to someone who asked first should accept theirs")
+	assert.True(control.AreFriends(a.ID, c.ID))
+
+	assert.NoError(control.Remove(a, b.ID))
+	assert.False(control.AreFriends(b.ID, a.ID), "Removing a friend should end the friendship for both")
+
+	assert.NoError(control.Request(b, a.ID))
+	assert.NoError(control.Decline(a, b.ID))
+	list, _ = control.List(a)
+	assert.Len(list.Incoming, 0)
+	assert.Len(list.Friends, 1)
+}
+
+func TestBlockFriend(t *testing.T) {
+	assert := assert.New(t)
+	control, users := getFriendUsecase(t, "Blocker", "Blocked")
+	a, b := users[0], users[1]
+	assert.NoError(control.Request(a, b.ID))
+	assert.NoError(control.Accept(b, a.ID))
+
+	assert.NoError(control.Block(a, b.ID))
+	assert.False(control.AreFriends(a.ID, b.ID))
+	assert.False(control.AreFriends(b.ID, a.ID))
+	assert.Error(control.Request(b, a.ID), "Blocked users should not send requests")
+	assert.Error(control.Request(a, b.ID), "Users should unblock before sending requests")
+	list, _ := control.List(a)
+	assert.Len(list.Blocked, 1)
+	list, _ = control.List(b)
+	assert.Len(list.Friends, 0)
+	assert.Len(list.Blocked, 0, "Blocked users should not see who blocked them")
+
+	assert.NoError(control.Unblock(a, b.ID))
+	assert.Error(control.Unblock(a, b.ID))
+	assert.NoError(control.Request(b, a.ID))
+}
+
+func TestGuestsHaveNoFriends(t *testing.T) {
+	assert := assert.New(t)
+	control, users := getFriendUsecase(t, "Guest friend")
+	guest, err := getUserUsecase().RegisterGuest("Lonely guest")
+	assert.NoError(err)
+	// Other tests count the guests of the shared store
+	defer mem.GetUserStore().Delete(guest.ID)
+	assert.Error(control.Request(guest, users[0].ID))
+	assert.Error(control.Request(users[0], guest.ID))
+}
@@ -2,21 +2,24 @@ package usecase
 
 import (
 	"errors"
-	"fmt"
 	"log"
 	"strings"
 
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
 )
 
 type gameController struct {
 	store   cah.GameStore
 	options Options
+	stats   statsRecorder
 }
 
-func NewGameUsecase(store cah.GameStore) *gameController {
+// NewGameUsecase returns the game usecases, stats can be nil to not record the card stats
+func NewGameUsecase(store cah.GameStore, stats cah.StatsStore) *gameController {
 	return &gameController{
 		store: store,
+		stats: statsRecorder{stats},
 	}
 }
 
@@ -47,6 +50,11 @@ func (control gameController) AllOpen() []cah.Game {
 	return control.store.ByStatePhase(cah.NotStarted)
 }
 
+// All returns every game, whatever its phase
+func (control gameController) All() []cah.Game {
+	return control.store.ByStatePhase(cah.NotStarted, cah.SinnersPlaying, cah.CzarChoosingWinner, cah.Finished)
+}
+
 func (control gameController) InProgressForUser(user cah.User) []cah.Game {
 	gamesInProgress := control.store.ByStatePhase(cah.SinnersPlaying, cah.CzarChoosingWinner)
 	ret := []cah.Game{}
@@ -74,13 +82,13 @@ func (control gameController) UserJoins(user cah.User, game cah.Game) error {
 
 func (control gameController) Start(g cah.Game, state *cah.GameState, opts ...cah.Option) error {
 	if len(g.Users) < 3 {
-		return fmt.Errorf("The minimum amount of players to start a game is 3, got: %d", len(g.Users))
+		return i18n.Errorf("The minimum amount of players to start a game is 3, got: %d", len(g.Users))
 	}
 	if g.State == nil {
-		return fmt.Errorf("Tried to start a game but it does not have any State")
+		return errors.New("Tried to start a game but it does not have any State")
 	}
 	if g.State.ID != 0 {
-		return fmt.Errorf("Tried to start a game but it already has a state. State ID '%d'", g.State.ID)
+		return i18n.Errorf("Tried to start a game but it already has a state. State ID '%d'", g.State.ID)
 	}
 	players := make([]*cah.Player, len(g.Users))
 	for i, u := range g.Users {
@@ -93,7 +101,7 @@ func (control gameController) Start(g cah.Game, state *cah.GameState, opts ...ca
 	if err != nil {
 		return err
 	}
-	playersDraw(state)
+	control.stats.dealt(playersDraw(state))
 	err = control.store.Update(g)
 	if err != nil {
 		return err
@@ -101,6 +109,101 @@ func (control gameController) Start(g cah.Game, state *cah.GameState, opts ...ca
 	return nil
 }
 
+// leaveState takes the player out of a game in progress, ending it if they were the czar
+// or fewer players than the minimum to start a game are left. It returns if they were playing.
+func (control gameController) leaveState(s *cah.GameState, userID int) bool {
+	left := -1
+	for i, p := range s.Players {
+		if p.User.ID == userID {
+			left = i
+		}
+	}
+	if left == -1 {
+		return false
+	}
+	s.Players = append(s.Players[:left], s.Players[left+1:]...)
+	wasCzar := left == s.CurrCzarIndex
+	if left < s.CurrCzarIndex {
+		s.CurrCzarIndex--
+	}
+	if s.CurrCzarIndex >= len(s.Players) {
+		s.CurrCzarIndex = 0
+	}
+	if wasCzar || len(s.Players) < 3 {
+		s.Phase = cah.Finished
+		control.stats.finished(s)
+		return true
+	}
+	if s.Phase == cah.SinnersPlaying && (stateController{}).AllSinnersPlayedTheirCards(s) {
+		s.Phase = cah.CzarChoosingWinner
+	}
+	return true
+}
+
 func (control gameController) Options() cah.GameOptions {
 	return control.options
 }
+
+// deletedUsername replaces the name of deleted users in the games they played
+const deletedUsername = "Deleted user"
+
+// RemoveUser takes a deleted user out of the games. They leave the games that did not start yet,
+// giving the ownership to the next user or deleting the game if nobody is left.
+// In the started games they are anonymised, so their name is not shown anymore, and they leave
+// the games in progress, which end if they were the czar or not enough players are left.
+func (control gameController) RemoveUser(user cah.User) error {
+	anonymous := cah.User{ID: user.ID, Username: deletedUsername}
+	for _, g := range control.store.ByStatePhase(cah.NotStarted) {
+		users := []cah.User{}
+		for _, u := range g.Users {
+			if u.ID != user.ID {
+				users = append(users, u)
+			}
+		}
+		if len(users) == len(g.Users) && g.Owner.ID != user.ID {
+			continue
+		}
+		if len(users) == 0 {
+			if err := control.store.Delete(g.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		g.Users = users
+		if g.Owner.ID == user.ID {
+			g.Owner = users[0]
+			g.UserID = users[0].ID
+		}
+		if err := control.store.Update(g); err != nil {
+			return err
+		}
+	}
+	for _, g := range control.store.ByStatePhase(cah.SinnersPlaying, cah.CzarChoosingWinner, cah.Finished) {
+		found := false
+		for i, u := range g.Users {
+			if u.ID == user.ID {
+				g.Users[i] = anonymous
+				found = true
+			}
+		}
+		if g.Owner.ID == user.ID {
+			g.Owner = anonymous
+			found = true
+		}
+		for _, p := range g.State.Players {
+			if p.User.ID == user.ID {
+				p.User = anonymous
+			}
+		}
+		if g.State.Phase != cah.Finished && control.leaveState(g.State, user.ID) {
+			found = true
+		}
+		if !found {
+			continue
+		}
+		if err := control.store.Update(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
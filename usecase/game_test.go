@@ -1,11 +1,71 @@
 package usecase
 
 import (
+	"testing"
+
 	"github.com/j4rv/cah"
 	"github.com/j4rv/cah/db/mem"
+	"github.com/stretchr/testify/assert"
 )
 
 func getGameUsecase() cah.GameUsecases {
 	store := mem.GetGameStore()
-	return NewGameUsecase(store)
+	return NewGameUsecase(store, nil)
+}
+
+func TestRemoveUser(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.GetGameStore()
+	uc := getGameUsecase()
+	deleted := cah.User{ID: 9001, Username: "Deleted"}
+	other := cah.User{ID: 9002, Username: "Other"}
+	third := cah.User{ID: 9003, Username: "Third"}
+	fourth := cah.User{ID: 9004, Username: "Fourth"}
+
+	lobby := cah.Game{Name: "Lobby", Owner: deleted, UserID: deleted.ID, Users: []cah.User{deleted, other}, State: &cah.GameState{}}
+	alone := cah.Game{Name: "Alone", Owner: deleted, UserID: deleted.ID, Users: []cah.User{deleted}, State: &cah.GameState{}}
+	finished := cah.Game{Name: "Finished", Owner: other, UserID: other.ID, Users: []cah.User{other, deleted},
+		State: &cah.GameState{Phase: cah.Finished, Players: []*cah.Player{cah.NewPlayer(other), cah.NewPlayer(deleted)}}}
+	playing := cah.Game{Name: "Playing", Owner: other, UserID: other.ID, Users: []cah.User{other, deleted, third, fourth},
+		State: &cah.GameState{Phase: cah.SinnersPlaying, CurrCzarIndex: 2, BlackCardInPlay: &cah.BlackCard{Blanks: 1},
+			Players: []*cah.Player{cah.NewPlayer(other), cah.NewPlayer(deleted), cah.NewPlayer(third), cah.NewPlayer(fourth)}}}
+	playing.State.Players[0].WhiteCardsInPlay = []*cah.WhiteCard{{}}
+	playing.State.Players[3].WhiteCardsInPlay = []*cah.WhiteCard{{}}
+	czar := cah.Game{Name: "Czar", Owner: other, UserID: other.ID, Users: []cah.User{other, third, fourth, deleted},
+		State: &cah.GameState{Phase: cah.SinnersPlaying, CurrCzarIndex: 3, BlackCardInPlay: &cah.BlackCard{Blanks: 1},
+			Players: []*cah.Player{cah.NewPlayer(other), cah.NewPlayer(third), cah.NewPlayer(fourth), cah.NewPlayer(deleted)}}}
+	for _, g := range []cah.Game{lobby, alone, finished, playing, czar} {
+		assert.NoError(store.Create(g))
+	}
+	ids := map[string]int{}
+	for _, g := range store.ByStatePhase(cah.NotStarted, cah.SinnersPlaying, cah.Finished) {
+		ids[g.Name] = g.ID
+	}
+
+	assert.NoError(uc.RemoveUser(deleted))
+
+	g, err := uc.ByID(ids["Lobby"])
+	assert.NoError(err)
+	assert.Equal([]cah.User{other}, g.Users, "The user should leave the lobbies")
+	assert.Equal(other.ID, g.Owner.ID, "The next user should own the lobby")
+	_, err = uc.ByID(ids["Alone"])
+	assert.Error(err, "Empty lobbies should be deleted")
+
+	g, err = uc.ByID(ids["Finished"])
+	assert.NoError(err)
+	assert.Equal(other, g.Users[0])
+	assert.Equal(deletedUsername, g.Users[1].Username, "The user should be anonymised in finished games")
+	assert.Equal(deletedUsername, g.State.Players[1].User.Username)
+	assert.Equal(other.Username, g.State.Players[0].User.Username)
+
+	g, err = uc.ByID(ids["Playing"])
+	assert.NoError(err)
+	assert.Len(g.State.Players, 3, "The user should leave the games in progress")
+	assert.Equal(third.ID, g.State.CurrCzar().User.ID, "The czar should stay the same")
+	assert.Equal(cah.CzarChoosingWinner, g.State.Phase, "The czar should choose once the other sinners played")
+
+	g, err = uc.ByID(ids["Czar"])
+	assert.NoError(err)
+	assert.Equal(cah.Finished, g.State.Phase, "The game should end if the czar left")
+	assert.Equal(0, g.State.CurrCzarIndex)
 }
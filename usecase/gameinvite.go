@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+const defaultInviteExpiry = 24 * time.Hour
+const maxInviteExpiry = 7 * 24 * time.Hour
+const maxInviteUses = 1000
+
+// maxGameInvites limits the invites of each game that can still be used
+const maxGameInvites = 20
+
+var errInvalidInvite = errors.New("This invite link is not valid")
+
+type gameInviteController struct {
+	store cah.GameInviteStore
+	key   []byte
+}
+
+// NewGameInviteUsecase returns the invites usecase, signing the tokens with the key.
+// Without a key a random one is used: the invites stop working on restart, along with the games.
+func NewGameInviteUsecase(store cah.GameInviteStore, key []byte) *gameInviteController {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &gameInviteController{store: store, key: key}
+}
+
+func (control gameInviteController) Create(owner cah.User, g cah.Game, expiresIn time.Duration, maxUses int) (cah.GameInvite, error) {
+	if g.Owner.ID != owner.ID {
+		return cah.GameInvite{}, errors.New("Only the owner of the game can manage its invite links")
+	}
+	if g.State != nil && g.State.Phase != cah.NotStarted {
+		return cah.GameInvite{}, errors.New("You can only invite players to games that did not start yet")
+	}
+	if expiresIn == 0 {
+		expiresIn = defaultInviteExpiry
+	}
+	if expiresIn < time.Minute || expiresIn > maxInviteExpiry {
+		return cah.GameInvite{}, i18n.Errorf("Invite links must expire between one minute and %d days", int(maxInviteExpiry.Hours()/24))
+	}
+	if maxUses < 0 || maxUses > maxInviteUses {
+		return cah.GameInvite{}, i18n.Errorf("The maximum uses of an invite link must be between 0 (unlimited) and %d", maxInviteUses)
+	}
+	invites, err := control.ByGame(owner, g)
+	if err != nil {
+		return cah.GameInvite{}, err
+	}
+	if len(invites) >= maxGameInvites {
+		return cah.GameInvite{}, i18n.Errorf("A game cannot have more than %d invite links", maxGameInvites)
+	}
+	now := time.Now()
+	inv, err := control.store.Create(cah.GameInvite{
+		GameID:    g.ID,
+		CreatedAt: now,
+		// Rounded to seconds, which is what the token signs
+		ExpiresAt: now.Add(expiresIn).Truncate(time.Second),
+		MaxUses:   maxUses,
+	})
+	if err != nil {
+		return inv, err
+	}
+	log.Printf("User '%s' created the invite link %d of the game '%s'", owner.Username, inv.ID, g.Name)
+	inv.Token = control.sign(inv)
+	return inv, nil
+}
+
+// ByGame returns the invites of the game that can still be used, deleting the rest
+func (control gameInviteController) ByGame(owner cah.User, g cah.Game) ([]cah.GameInvite, error) {
+	if g.Owner.ID != owner.ID {
+		return nil, errors.New("Only the owner of the game can manage its invite links")
+	}
+	ret := []cah.GameInvite{}
+	for _, inv := range control.store.ByGame(g.ID) {
+		if usable(inv) != nil {
+			control.store.Delete(inv.ID)
+			continue
+		}
+		inv.Token = control.sign(inv)
+		ret = append(ret, inv)
+	}
+	return ret, nil
+}
+
+func (control gameInviteController) Check(token string) (cah.GameInvite, error) {
+	inv, err := control.verify(token)
+	if err != nil {
+		return inv, err
+	}
+	return inv, usable(inv)
+}
+
+func (control gameInviteController) Redeem(token string) (cah.GameInvite, error) {
+	inv, err := control.Check(token)
+	if err != nil {
+		return inv, err
+	}
+	inv, err = control.store.Use(inv.ID)
+	if err != nil {
+		return inv, errors.New("This invite link has no uses left")
+	}
+	return inv, nil
+}
+
+func (control gameInviteController) Unredeem(inv cah.GameInvite) error {
+	return control.store.Unuse(inv.ID)
+}
+
+func (control gameInviteController) Revoke(owner cah.User, g cah.Game, inviteID int) error {
+	if g.Owner.ID != owner.ID {
+		return errors.New("Only the owner of the game can manage its invite links")
+	}
+	inv, err := control.store.ByID(inviteID)
+	if err != nil || inv.GameID != g.ID {
+		return i18n.Errorf("No invite link found with ID %d", inviteID)
+	}
+	log.Printf("User '%s' revoked the invite link %d of the game '%s'", owner.Username, inv.ID, g.Name)
+	return control.store.Revoke(inv.ID)
+}
+
+// internal
+
+// sign returns the token of the invite: its ID, game and expiry, followed by their HMAC.
+// Tokens are not stored, since signing the invite again gives the same token.
+func (control gameInviteController) sign(inv cah.GameInvite) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d.%d", inv.ID, inv.GameID, inv.ExpiresAt.Unix())))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(control.mac(payload))
+}
+
+func (control gameInviteController) mac(payload string) []byte {
+	h := hmac.New(sha256.New, control.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// verify checks the signature of the token and returns its invite
+func (control gameInviteController) verify(token string) (cah.GameInvite, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return cah.GameInvite{}, errInvalidInvite
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, control.mac(parts[0])) {
+		return cah.GameInvite{}, errInvalidInvite
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return cah.GameInvite{}, errInvalidInvite
+	}
+	var id, gameID int
+	var expires int64
+	if _, err := fmt.Sscanf(string(payload), "%d.%d.%d", &id, &gameID, &expires); err != nil {
+		return cah.GameInvite{}, errInvalidInvite
+	}
+	if time.Now().Unix() > expires {
+		return cah.GameInvite{}, errors.New("This invite link expired")
+	}
+	inv, err := control.store.ByID(id)
+	if err != nil || inv.GameID != gameID || inv.ExpiresAt.Unix() != expires {
+		return cah.GameInvite{}, errInvalidInvite
+	}
+	return inv, nil
+}
+
+// usable returns why the invite can not be used, or nil if it can
+func usable(inv cah.GameInvite) error {
+	switch {
+	case inv.Revoked:
+		return errors.New("This invite link was revoked")
+	case time.Now().After(inv.ExpiresAt):
+		return errors.New("This invite link expired")
+	case inv.MaxUses != 0 && inv.Uses >= inv.MaxUses:
+		return errors.New("This invite link has no uses left")
+	}
+	return nil
+}
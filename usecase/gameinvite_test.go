@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func getGameInviteUsecase() gameInviteController {
+	return *NewGameInviteUsecase(mem.GetGameInviteStore(), []byte("test key"))
+}
+
+func inviteTestGame(id int) (cah.User, cah.Game) {
+	owner := cah.User{ID: 1, Username: "Owner"}
+	return owner, cah.Game{ID: id, Owner: owner, Name: "Invites", State: &cah.GameState{}}
+}
+
+func TestGameInviteCreate(t *testing.T) {
+	assert := assert.New(t)
+	control := getGameInviteUsecase()
+	owner, g := inviteTestGame(1001)
+
+	_, err := control.Create(cah.User{ID: 2}, g, 0, 0)
+	assert.Error(err, "Only the owner should create invites")
+	_, err = control.Create(owner, g, time.Second, 0)
+	assert.Error(err)
+	_, err = control.Create(owner, g, 30*24*time.Hour, 0)
+	assert.Error(err)
+	_, err = control.Create(owner, g, 0, -1)
+	assert.Error(err)
+
+	inv, err := control.Create(owner, g, 0, 2)
+	assert.NoError(err)
+	assert.Equal(g.ID, inv.GameID)
+	assert.NotEmpty(inv.Token)
+	assert.WithinDuration(time.Now().Add(defaultInviteExpiry), inv.ExpiresAt, 2*time.Second)
+	invites, err := control.ByGame(owner, g)
+	assert.NoError(err)
+	assert.Len(invites, 1)
+	assert.Equal(inv.Token, invites[0].Token, "Signing the invite again should give the same token")
+	_, err = control.ByGame(cah.User{ID: 2}, g)
+	assert.Error(err)
+
+	started := g
+	started.State = &cah.GameState{Phase: cah.SinnersPlaying}
+	_, err = control.Create(owner, started, 0, 0)
+	assert.Error(err, "Started games should not get invites")
+}
+
+func TestGameInviteRedeem(t *testing.T) {
+	assert := assert.New(t)
+	control := getGameInviteUsecase()
+	owner, g := inviteTestGame(1002)
+	inv, err := control.Create(owner, g, time.Hour, 2)
+	assert.NoError(err)
+
+	_, err = control.Check(inv.Token)
+	assert.NoError(err)
+	used, err := control.Redeem(inv.Token)
+	assert.NoError(err)
+	assert.Equal(1, used.Uses, "Checking should not use the invite")
+	_, err = control.Redeem(inv.Token)
+	assert.NoError(err)
+	_, err = control.Check(inv.Token)
+	assert.Error(err)
+	_, err = control.Redeem(inv.Token)
+	assert.Error(err, "Invites should not be used more than their max uses")
+	assert.NoError(control.Unredeem(used))
+	_, err = control.Redeem(inv.Token)
+	assert.NoError(err, "Unredeeming should give back the use")
+	invites, _ := control.ByGame(owner, g)
+	assert.Len(invites, 0, "Used up invites should not be listed")
+
+	unlimited, err := control.Create(owner, g, time.Hour, 0)
+	assert.NoError(err)
+	for i := 0; i < 5; i++ {
+		_, err = control.Redeem(unlimited.Token)
+		assert.NoError(err)
+	}
+	assert.Error(control.Revoke(owner, cah.Game{ID: 1, Owner: owner}, unlimited.ID), "Invites should only be revoked through their game")
+	assert.Error(control.Revoke(cah.User{ID: 2}, g, unlimited.ID))
+	assert.NoError(control.Revoke(owner, g, unlimited.ID))
+	_, err = control.Redeem(unlimited.Token)
+	assert.Error(err, "Revoked invites should not be used")
+}
+
+func TestGameInviteTokens(t *testing.T) {
+	assert := assert.New(t)
+	control := getGameInviteUsecase()
+	owner, g := inviteTestGame(1003)
+	inv, err := control.Create(owner, g, time.Hour, 0)
+	assert.NoError(err)
+
+	other := NewGameInviteUsecase(mem.GetGameInviteStore(), []byte("other key"))
+	_, err = other.Check(inv.Token)
+	assert.Error(err, "Tokens signed with other keys should not be valid")
+
+	forged := inv
+	forged.ExpiresAt = forged.ExpiresAt.Add(time.Hour)
+	payload := strings.Split(other.sign(forged), ".")[0]
+	signature := strings.Split(inv.Token, ".")[1]
+	_, err = control.Check(payload + "." + signature)
+	assert.Error(err, "Tokens should not be valid after changing their expiry")
+
+	for _, token := range []string{"", ".", "a.b.c", inv.Token + "x"} {
+		_, err = control.Check(token)
+		assert.Error(err)
+	}
+
+	expired, err := mem.GetGameInviteStore().Create(cah.GameInvite{GameID: g.ID, ExpiresAt: time.Now().Add(-time.Minute).Truncate(time.Second)})
+	assert.NoError(err)
+	_, err = control.Check(control.sign(expired))
+	assert.Error(err, "Expired invites should not be valid")
+}
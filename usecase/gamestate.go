@@ -2,12 +2,12 @@ package usecase
 
 import (
 	"errors"
-	"fmt"
 	"log"
 
 	"github.com/j4rv/cah/lib/rng"
 
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
 )
 
 var nilBlackCard = &cah.BlackCard{}
@@ -20,10 +20,12 @@ func (e errorEmptyBlackDeck) Error() string {
 
 type stateController struct {
 	store cah.GameStateStore
+	stats statsRecorder
 }
 
-func NewGameStateUsecase(store cah.GameStateStore) *stateController {
-	return &stateController{store: store}
+// NewGameStateUsecase returns the game state usecases, stats can be nil to not record the card stats
+func NewGameStateUsecase(store cah.GameStateStore, stats cah.StatsStore) *stateController {
+	return &stateController{store: store, stats: statsRecorder{stats}}
 }
 
 func (control stateController) Create() *cah.GameState {
@@ -34,6 +36,7 @@ func (control stateController) Create() *cah.GameState {
 		WhiteDeck:       []*cah.WhiteCard{},
 		BlackDeck:       []*cah.BlackCard{},
 		BlackCardInPlay: nilBlackCard,
+		Rounds:          []*cah.Round{},
 	}
 	ret, err := control.store.Create(ret)
 	if err != nil {
@@ -55,6 +58,7 @@ func (control stateController) End(g *cah.GameState) error {
 	if err != nil {
 		return err
 	}
+	control.stats.finished(g)
 	return nil
 }
 
@@ -70,9 +74,19 @@ func (control stateController) GiveBlackCardToWinner(wID int, g *cah.GameState)
 		}
 	}
 	if winner == nil {
-		return fmt.Errorf("Invalid winner id %d", wID)
+		return i18n.Errorf("Invalid winner id %d", wID)
 	}
 	winner.Points = append(winner.Points, g.BlackCardInPlay)
+	control.stats.won(winner.WhiteCardsInPlay)
+	control.stats.seen(g)
+	g.Rounds = append(g.Rounds, &cah.Round{
+		Number:     g.CurrRound,
+		CzarID:     g.CurrCzar().User.ID,
+		WinnerID:   wID,
+		BlackCard:  g.BlackCardInPlay,
+		WhiteCards: winner.WhiteCardsInPlay,
+		Sentence:   cah.ComposeSentence(g.BlackCardInPlay, winner.WhiteCardsInPlay),
+	})
 	// the rest of the code should be "roundStart" or "startNewRound"
 	if g.MaxRounds > 0 && g.CurrRound >= g.MaxRounds {
 		return control.End(g)
@@ -89,7 +103,7 @@ func (control stateController) GiveBlackCardToWinner(wID int, g *cah.GameState)
 	if err != nil {
 		return err
 	}
-	playersDraw(g)
+	control.stats.dealt(playersDraw(g))
 	err = control.store.Update(g)
 	if err != nil {
 		return err
@@ -99,7 +113,7 @@ func (control stateController) GiveBlackCardToWinner(wID int, g *cah.GameState)
 
 func giveBlackCardToWinnerChecks(w int, s *cah.GameState) error {
 	if s.Phase != cah.CzarChoosingWinner {
-		return fmt.Errorf("Tried to choose a winner in a non valid phase '%d'", s.Phase)
+		return i18n.Errorf("Tried to choose a winner in a non valid phase '%d'", s.Phase)
 	}
 	for i, p := range s.Players {
 		if i == s.CurrCzarIndex {
@@ -118,7 +132,7 @@ func (control stateController) PlayWhiteCards(p int, cs []int, g *cah.GameState)
 		return checkErr
 	}
 	if len(cs) != g.BlackCardInPlay.Blanks {
-		return fmt.Errorf("Invalid amount of white cards to play, expected %d but got %d",
+		return i18n.Errorf("Invalid amount of white cards to play, expected %d but got %d",
 			g.BlackCardInPlay.Blanks,
 			len(cs))
 	}
@@ -132,6 +146,7 @@ func (control stateController) playWhiteCards(p int, cs []int, gs *cah.GameState
 		return err
 	}
 	player.WhiteCardsInPlay = append(player.WhiteCardsInPlay, newCardsPlayed...)
+	control.stats.played(newCardsPlayed)
 	if control.AllSinnersPlayedTheirCards(gs) {
 		gs.Phase = cah.CzarChoosingWinner
 	}
@@ -163,12 +178,17 @@ func (_ stateController) AllSinnersPlayedTheirCards(s *cah.GameState) bool {
 	return true
 }
 
-func playersDraw(s *cah.GameState) {
+// playersDraw fills the hands of the players, returning the drawn cards
+func playersDraw(s *cah.GameState) []*cah.WhiteCard {
+	drawn := []*cah.WhiteCard{}
 	for _, p := range s.Players {
 		for len(p.Hand) < s.HandSize {
-			p.Hand = append(p.Hand, s.DrawWhite())
+			c := s.DrawWhite()
+			p.Hand = append(p.Hand, c)
+			drawn = append(drawn, c)
 		}
 	}
+	return drawn
 }
 
 func putBlackCardInPlay(g *cah.GameState) error {
@@ -12,7 +12,7 @@ var control = stateController{}
 
 func getStateUsecase() cah.GameStateUsecases {
 	store := mem.GetGameStateStore()
-	usecase := NewGameStateUsecase(store)
+	usecase := NewGameStateUsecase(store, nil)
 	return usecase
 }
 
@@ -48,3 +48,30 @@ func TestNextCzar_errors(t *testing.T) {
 	err = control.nextCzar(&s)
 	assert.NotEqual(err, nil, "Expected 'incorrect phase' error but found nil")
 }
+
+func TestGiveBlackCardToWinner_recordsRound(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.GetGameStateStore()
+	uc := NewGameStateUsecase(store, nil)
+	s := getStateFixture()
+	_, err := store.Create(&s)
+	assert.NoError(err)
+	for i, p := range s.Players {
+		p.User.ID = i + 1
+	}
+	s.HandSize = 3
+	s.BlackDeck[0].Text = "_ is the answer."
+	assert.NoError(putBlackCardInPlay(&s))
+	playersDraw(&s)
+	s.Players[2].Hand[0].Text = "The winner."
+	assert.NoError(uc.PlayWhiteCards(1, []int{0}, &s))
+	assert.NoError(uc.PlayWhiteCards(2, []int{0}, &s))
+	assert.NoError(uc.GiveBlackCardToWinner(3, &s))
+
+	assert.Len(s.Rounds, 1)
+	r := s.Rounds[0]
+	assert.Equal(1, r.Number)
+	assert.Equal(1, r.CzarID)
+	assert.Equal(3, r.WinnerID)
+	assert.Equal("The winner is the answer.", r.Sentence)
+}
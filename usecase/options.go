@@ -1,14 +1,22 @@
 package usecase
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"log"
+	"math"
 	"math/rand"
+	"sort"
 
 	"github.com/j4rv/cah"
 )
 
 type Options struct {
-	cards cah.CardUsecases
+	cards        cah.CardUsecases
+	weights      map[string]float64
+	seed         *int64
+	excludedIDs  map[int]bool
+	excludedTags []string
 }
 
 func applyOptions(s *cah.GameState, opts ...cah.Option) {
@@ -17,23 +25,67 @@ func applyOptions(s *cah.GameState, opts ...cah.Option) {
 	}
 }
 
+// DeckWeights returns options whose WhiteDeck and BlackDeck sample the cards so each
+// expansion gets a share of the deck proportional to its weight, like 40 "Vidya" and 60 "Base UK".
+// Cards from expansions without a positive weight are left out.
+func (o Options) DeckWeights(weights map[string]float64) cah.GameOptions {
+	o.weights = weights
+	return o
+}
+
+// Seeded returns options whose decks are sampled and shuffled deterministically.
+// Leaving some cards out of the decks does not change the order of the rest.
+func (o Options) Seeded(seed int64) cah.GameOptions {
+	o.seed = &seed
+	return o
+}
+
+// Excluding returns options whose WhiteDeck and BlackDeck leave out the cards with those IDs or tags
+// before sampling, so the weights and the seed apply to the cards that can be in the decks
+func (o Options) Excluding(ids []int, tags []string) cah.GameOptions {
+	o.excludedIDs = map[int]bool{}
+	for _, id := range ids {
+		o.excludedIDs[id] = true
+	}
+	o.excludedTags = tags
+	return o
+}
+
 func (_ Options) HandSize(size int) cah.Option {
 	return func(s *cah.GameState) {
 		s.HandSize = size
 	}
 }
 
-func (_ Options) WhiteDeck(wd []*cah.WhiteCard) cah.Option {
+func (o Options) WhiteDeck(wd []*cah.WhiteCard) cah.Option {
 	return func(s *cah.GameState) {
-		s.WhiteDeck = wd
-		shuffleW(&s.WhiteDeck)
+		deck := make([]*cah.WhiteCard, 0, len(wd))
+		for _, c := range wd {
+			if !o.excludedIDs[c.ID] && !c.HasTag(o.excludedTags...) {
+				deck = append(deck, c)
+			}
+		}
+		if len(o.weights) != 0 {
+			deck = o.sampleWhites(deck)
+		}
+		o.shuffleW(deck)
+		s.WhiteDeck = deck
 	}
 }
 
-func (_ Options) BlackDeck(bd []*cah.BlackCard) cah.Option {
+func (o Options) BlackDeck(bd []*cah.BlackCard) cah.Option {
 	return func(s *cah.GameState) {
-		s.BlackDeck = bd
-		shuffleB(&s.BlackDeck)
+		deck := make([]*cah.BlackCard, 0, len(bd))
+		for _, c := range bd {
+			if !o.excludedIDs[c.ID] && !c.HasTag(o.excludedTags...) {
+				deck = append(deck, c)
+			}
+		}
+		if len(o.weights) != 0 {
+			deck = o.sampleBlacks(deck)
+		}
+		o.shuffleB(deck)
+		s.BlackDeck = deck
 	}
 }
 
@@ -53,20 +105,248 @@ func (_ Options) MaxRounds(max int) cah.Option {
 	}
 }
 
-func shuffleB(cards *[]*cah.BlackCard) {
-	if cards == nil {
+// IncludeWhites adds the cards to the white deck unless they are already in it, then shuffles it
+func (o Options) IncludeWhites(wc []*cah.WhiteCard) cah.Option {
+	return func(s *cah.GameState) {
+		deck := append([]*cah.WhiteCard{}, s.WhiteDeck...)
+		inDeck := map[int]bool{}
+		for _, c := range deck {
+			inDeck[c.ID] = true
+		}
+		for _, c := range wc {
+			if !inDeck[c.ID] {
+				inDeck[c.ID] = true
+				deck = append(deck, c)
+			}
+		}
+		o.shuffleW(deck)
+		s.WhiteDeck = deck
+	}
+}
+
+// IncludeBlacks adds the cards to the black deck unless they are already in it, then shuffles it
+func (o Options) IncludeBlacks(bc []*cah.BlackCard) cah.Option {
+	return func(s *cah.GameState) {
+		deck := append([]*cah.BlackCard{}, s.BlackDeck...)
+		inDeck := map[int]bool{}
+		for _, c := range deck {
+			inDeck[c.ID] = true
+		}
+		for _, c := range bc {
+			if !inDeck[c.ID] {
+				inDeck[c.ID] = true
+				deck = append(deck, c)
+			}
+		}
+		o.shuffleB(deck)
+		s.BlackDeck = deck
+	}
+}
+
+// ExcludeCards removes the cards with those IDs from both decks.
+// It needs to be applied after the options that fill the decks.
+func (_ Options) ExcludeCards(ids ...int) cah.Option {
+	return func(s *cah.GameState) {
+		excluded := map[int]bool{}
+		for _, id := range ids {
+			excluded[id] = true
+		}
+		whites := make([]*cah.WhiteCard, 0, len(s.WhiteDeck))
+		for _, c := range s.WhiteDeck {
+			if !excluded[c.ID] {
+				whites = append(whites, c)
+			}
+		}
+		blacks := make([]*cah.BlackCard, 0, len(s.BlackDeck))
+		for _, c := range s.BlackDeck {
+			if !excluded[c.ID] {
+				blacks = append(blacks, c)
+			}
+		}
+		s.WhiteDeck = whites
+		s.BlackDeck = blacks
+	}
+}
+
+// ExcludeTags removes the cards with any of those tags from both decks.
+// It needs to be applied after the options that fill the decks.
+func (_ Options) ExcludeTags(tags ...string) cah.Option {
+	return func(s *cah.GameState) {
+		whites := make([]*cah.WhiteCard, 0, len(s.WhiteDeck))
+		for _, c := range s.WhiteDeck {
+			if !c.HasTag(tags...) {
+				whites = append(whites, c)
+			}
+		}
+		blacks := make([]*cah.BlackCard, 0, len(s.BlackDeck))
+		for _, c := range s.BlackDeck {
+			if !c.HasTag(tags...) {
+				blacks = append(blacks, c)
+			}
+		}
+		s.WhiteDeck = whites
+		s.BlackDeck = blacks
+	}
+}
+
+// PreferUnseen moves the cards not in seen to the top of both decks. The unseen and the seen
+// cards keep their shuffled order, so the decks are still random.
+// It needs to be applied after the options that fill and shuffle the decks.
+func (_ Options) PreferUnseen(seen ...int) cah.Option {
+	return func(s *cah.GameState) {
+		isSeen := map[int]bool{}
+		for _, id := range seen {
+			isSeen[id] = true
+		}
+		whites := make([]*cah.WhiteCard, 0, len(s.WhiteDeck))
+		seenWhites := []*cah.WhiteCard{}
+		for _, c := range s.WhiteDeck {
+			if isSeen[c.ID] {
+				seenWhites = append(seenWhites, c)
+			} else {
+				whites = append(whites, c)
+			}
+		}
+		blacks := make([]*cah.BlackCard, 0, len(s.BlackDeck))
+		seenBlacks := []*cah.BlackCard{}
+		for _, c := range s.BlackDeck {
+			if isSeen[c.ID] {
+				seenBlacks = append(seenBlacks, c)
+			} else {
+				blacks = append(blacks, c)
+			}
+		}
+		s.WhiteDeck = append(whites, seenWhites...)
+		s.BlackDeck = append(blacks, seenBlacks...)
+	}
+}
+
+// shuffleW shuffles the cards. Seeded options sort them by a hash of the seed and their IDs instead,
+// so the same seed gives the same order and leaving some cards out does not change the order of the rest.
+func (o Options) shuffleW(cards []*cah.WhiteCard) {
+	if o.seed == nil {
+		rand.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
 		return
 	}
-	for i, j := range rand.Perm(len(*cards)) {
-		(*cards)[i], (*cards)[j] = (*cards)[j], (*cards)[i]
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.ID
+	}
+	sorted := make([]*cah.WhiteCard, len(cards))
+	for i, j := range seededOrder(*o.seed, ids) {
+		sorted[i] = cards[j]
 	}
+	copy(cards, sorted)
 }
 
-func shuffleW(cards *[]*cah.WhiteCard) {
-	if cards == nil {
+// shuffleB shuffles the cards like shuffleW
+func (o Options) shuffleB(cards []*cah.BlackCard) {
+	if o.seed == nil {
+		rand.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
 		return
 	}
-	for i, j := range rand.Perm(len(*cards)) {
-		(*cards)[i], (*cards)[j] = (*cards)[j], (*cards)[i]
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.ID
+	}
+	sorted := make([]*cah.BlackCard, len(cards))
+	for i, j := range seededOrder(*o.seed, ids) {
+		sorted[i] = cards[j]
+	}
+	copy(cards, sorted)
+}
+
+// seededOrder returns the indexes of the IDs sorted by the hash of the seed and each ID
+func seededOrder(seed int64, ids []int) []int {
+	keys := make([]uint64, len(ids))
+	order := make([]int, len(ids))
+	var buf [16]byte
+	for i, id := range ids {
+		binary.LittleEndian.PutUint64(buf[:8], uint64(seed))
+		binary.LittleEndian.PutUint64(buf[8:], uint64(id))
+		sum := sha256.Sum256(buf[:])
+		keys[i] = binary.LittleEndian.Uint64(sum[:8])
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]] < keys[order[b]]
+	})
+	return order
+}
+
+func (o Options) sampleWhites(cards []*cah.WhiteCard) []*cah.WhiteCard {
+	byExpansion := map[string][]*cah.WhiteCard{}
+	available := map[string]int{}
+	for _, c := range cards {
+		byExpansion[c.Expansion] = append(byExpansion[c.Expansion], c)
+		available[c.Expansion]++
+	}
+	ret := []*cah.WhiteCard{}
+	quotas := weightedQuotas(available, o.weights)
+	for _, e := range sortedKeys(available) {
+		group := byExpansion[e]
+		o.shuffleW(group)
+		ret = append(ret, group[:quotas[e]]...)
+	}
+	return ret
+}
+
+func (o Options) sampleBlacks(cards []*cah.BlackCard) []*cah.BlackCard {
+	byExpansion := map[string][]*cah.BlackCard{}
+	available := map[string]int{}
+	for _, c := range cards {
+		byExpansion[c.Expansion] = append(byExpansion[c.Expansion], c)
+		available[c.Expansion]++
+	}
+	ret := []*cah.BlackCard{}
+	quotas := weightedQuotas(available, o.weights)
+	for _, e := range sortedKeys(available) {
+		group := byExpansion[e]
+		o.shuffleB(group)
+		ret = append(ret, group[:quotas[e]]...)
+	}
+	return ret
+}
+
+// weightedQuotas returns how many cards to take from each expansion, so every expansion
+// gets its weight divided by the sum of weights as its share of the deck.
+// The deck is made as big as possible without running out of cards in any expansion.
+func weightedQuotas(available map[string]int, weights map[string]float64) map[string]int {
+	total := 0.0
+	for e := range available {
+		if weights[e] > 0 {
+			total += weights[e]
+		}
+	}
+	quotas := map[string]int{}
+	if total == 0 {
+		return quotas
+	}
+	size := math.Inf(1)
+	for e, n := range available {
+		if weights[e] > 0 {
+			size = math.Min(size, float64(n)*total/weights[e])
+		}
+	}
+	for e, n := range available {
+		if weights[e] <= 0 {
+			continue
+		}
+		// The epsilon avoids losing a card to floating point errors
+		q := int(math.Floor(size*weights[e]/total + 1e-9))
+		if q > n {
+			q = n
+		}
+		quotas[e] = q
+	}
+	return quotas
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
 }
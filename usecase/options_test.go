@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/j4rv/cah"
+)
+
+func TestOptionsIncludeAndExcludeCards(t *testing.T) {
+	assert := assert.New(t)
+	opts := Options{}
+	whites := getWhiteCardsFixture(10)
+	blacks := getBlackCardsFixture(5)
+	for i, c := range whites {
+		c.ID = i + 1
+	}
+	for i, c := range blacks {
+		c.ID = i + 100
+	}
+	extraWhite := &cah.WhiteCard{ID: 50, Text: "Included"}
+	extraBlack := &cah.BlackCard{ID: 150, Text: "Included _", Blanks: 1}
+
+	s := &cah.GameState{}
+	applyOptions(s,
+		opts.WhiteDeck(whites),
+		opts.BlackDeck(blacks),
+		opts.IncludeWhites([]*cah.WhiteCard{extraWhite, whites[0]}),
+		opts.IncludeBlacks([]*cah.BlackCard{extraBlack}),
+		opts.ExcludeCards(1, 2, 100, 150),
+	)
+	assert.Len(s.WhiteDeck, 9, "Expected 10 whites, plus 1 included, minus 2 excluded")
+	assert.Len(s.BlackDeck, 4, "Expected 5 blacks, plus 1 included, minus 2 excluded")
+	ids := map[int]bool{}
+	for _, c := range s.WhiteDeck {
+		ids[c.ID] = true
+	}
+	for _, c := range s.BlackDeck {
+		ids[c.ID] = true
+	}
+	assert.True(ids[50], "Included white card not found in the deck")
+	for _, id := range []int{1, 2, 100, 150} {
+		assert.False(ids[id], "Excluded card found in the deck", id)
+	}
+}
+
+func TestWeightedQuotas(t *testing.T) {
+	cases := []struct {
+		name      string
+		available map[string]int
+		weights   map[string]float64
+		expected  map[string]int
+	}{
+		{"shares", map[string]int{"Big": 400, "Small": 50}, map[string]float64{"Big": 60, "Small": 40}, map[string]int{"Big": 75, "Small": 50}},
+		{"equal weights", map[string]int{"A": 10, "B": 30}, map[string]float64{"A": 1, "B": 1}, map[string]int{"A": 10, "B": 10}},
+		{"missing weight", map[string]int{"A": 10, "B": 30}, map[string]float64{"A": 1}, map[string]int{"A": 10}},
+		{"no weights", map[string]int{"A": 10}, map[string]float64{}, map[string]int{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, weightedQuotas(tc.available, tc.weights))
+		})
+	}
+}
+
+func TestOptionsSeededWeightedDeck(t *testing.T) {
+	assert := assert.New(t)
+	whites := getWhiteCardsFixture(60)
+	for i, c := range whites {
+		c.ID = i + 1
+		c.Expansion = "Big"
+		if i%6 == 0 {
+			c.Expansion = "Small"
+		}
+	}
+	opts := Options{}.DeckWeights(map[string]float64{"Big": 0.6, "Small": 0.4}).Seeded(42)
+
+	first, second := &cah.GameState{}, &cah.GameState{}
+	applyOptions(first, opts.WhiteDeck(whites))
+	applyOptions(second, opts.WhiteDeck(whites))
+	assert.Len(first.WhiteDeck, 25, "Expected 10 small cards and 15 big cards")
+	small := 0
+	for i := range first.WhiteDeck {
+		assert.Equal(first.WhiteDeck[i].ID, second.WhiteDeck[i].ID, "Seeded decks should be equal")
+		if first.WhiteDeck[i].Expansion == "Small" {
+			small++
+		}
+	}
+	assert.Equal(10, small)
+}
+
+func TestOptionsWeightsAfterExclusions(t *testing.T) {
+	assert := assert.New(t)
+	whites := getWhiteCardsFixture(60)
+	excluded := []int{}
+	for i, c := range whites {
+		c.ID = i + 1
+		c.Expansion = "Big"
+		if i < 10 {
+			c.Expansion = "Small"
+			// Half the small expansion is excluded, and none of the big one
+			if i%2 == 0 {
+				excluded = append(excluded, c.ID)
+			}
+		}
+	}
+	whites[11].Tags = []string{cah.NSFWTag}
+	opts := Options{}.DeckWeights(map[string]float64{"Big": 1, "Small": 1}).Excluding(excluded, []string{cah.NSFWTag})
+
+	s := &cah.GameState{}
+	applyOptions(s, opts.WhiteDeck(whites))
+	counts := map[string]int{}
+	for _, c := range s.WhiteDeck {
+		counts[c.Expansion]++
+		assert.NotContains(excluded, c.ID, "Excluded cards should not be in the deck")
+		assert.False(c.HasTag(cah.NSFWTag), "Cards with excluded tags should not be in the deck")
+	}
+	assert.Equal(map[string]int{"Big": 5, "Small": 5}, counts, "The weights should apply to the cards left after the exclusions")
+}
+
+func TestOptionsSeededDeckWithMoreExclusions(t *testing.T) {
+	assert := assert.New(t)
+	whites := getWhiteCardsFixture(30)
+	for i, c := range whites {
+		c.ID = i + 1
+	}
+	deck := func(excluded ...int) []int {
+		s := &cah.GameState{}
+		applyOptions(s, Options{}.Seeded(3).Excluding(excluded, nil).WhiteDeck(whites))
+		ids := []int{}
+		for _, c := range s.WhiteDeck {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	before := deck(5)
+	after := deck(5, 9)
+	expected := []int{}
+	for _, id := range before {
+		if id != 9 {
+			expected = append(expected, id)
+		}
+	}
+	assert.Equal(expected, after, "Banning a card should only remove it from the seeded deck")
+	assert.Equal(before, deck(5), "The same seed should give the same deck")
+}
+
+func TestOptionsPreferUnseen(t *testing.T) {
+	assert := assert.New(t)
+	opts := Options{}.Seeded(7)
+	whites := getWhiteCardsFixture(20)
+	blacks := getBlackCardsFixture(10)
+	for i, c := range whites {
+		c.ID = i + 1
+	}
+	for i, c := range blacks {
+		c.ID = i + 100
+	}
+	seen := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 100, 101, 102}
+
+	s := &cah.GameState{}
+	applyOptions(s, opts.WhiteDeck(whites), opts.BlackDeck(blacks), opts.PreferUnseen(seen...))
+	assert.Len(s.WhiteDeck, 20)
+	assert.Len(s.BlackDeck, 10)
+	for i, c := range s.WhiteDeck {
+		assert.Equal(i >= 10, c.ID <= 10, "Unseen white cards should come first")
+	}
+	for i, c := range s.BlackDeck {
+		assert.Equal(i >= 7, c.ID <= 102, "Unseen black cards should come first")
+	}
+
+	shuffled := &cah.GameState{}
+	applyOptions(shuffled, opts.WhiteDeck(whites))
+	unseenOrder := []int{}
+	for _, c := range shuffled.WhiteDeck {
+		if c.ID > 10 {
+			unseenOrder = append(unseenOrder, c.ID)
+		}
+	}
+	for i, id := range unseenOrder {
+		assert.Equal(id, s.WhiteDeck[i].ID, "Unseen cards should keep their shuffled order")
+	}
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
+)
+
+// sessionTouchInterval is how often the last use of a session is stored,
+// so every request does not need to write to the store
+const sessionTouchInterval = time.Minute
+
+type sessionController struct {
+	store cah.SessionStore
+}
+
+func NewSessionUsecase(store cah.SessionStore) *sessionController {
+	return &sessionController{store: store}
+}
+
+// Start creates a session for the user lasting age since its last use.
+// The expired sessions of every user are deleted too.
+func (control sessionController) Start(u cah.User, age time.Duration, userAgent, ip string) (cah.Session, string, error) {
+	now := time.Now()
+	checkErr(control.store.DeleteExpired(now), "sessionController.Start")
+	token, err := newToken()
+	if err != nil {
+		return cah.Session{}, "", err
+	}
+	s, err := control.store.Create(cah.Session{
+		UserID:     u.ID,
+		TokenHash:  hashToken(token),
+		UserAgent:  userAgent,
+		IP:         ip,
+		Age:        age,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(age),
+	})
+	return s, token, err
+}
+
+// ByToken returns the session of the token and extends it, as long as it was not revoked and did not expire
+func (control sessionController) ByToken(token string) (cah.Session, bool) {
+	s, err := control.store.ByToken(hashToken(token))
+	if err != nil {
+		return s, false
+	}
+	now := time.Now()
+	if !now.Before(s.ExpiresAt) {
+		checkErr(control.store.Delete(s.ID), "sessionController.ByToken")
+		return s, false
+	}
+	if now.Sub(s.LastSeenAt) >= sessionTouchInterval {
+		s.LastSeenAt = now
+		s.ExpiresAt = now.Add(s.Age)
+		checkErr(control.store.Touch(s.ID, s.LastSeenAt, s.ExpiresAt), "sessionController.ByToken")
+	}
+	return s, true
+}
+
+func (control sessionController) ByUser(userID int) ([]cah.Session, error) {
+	return control.store.ByUser(userID)
+}
+
+// Revoke deletes sessions of the user, failing if any of them belongs to someone else
+func (control sessionController) Revoke(userID int, ids ...int) error {
+	sessions, err := control.store.ByUser(userID)
+	if err != nil {
+		return err
+	}
+	owned := map[int]bool{}
+	for _, s := range sessions {
+		owned[s.ID] = true
+	}
+	for _, id := range ids {
+		if !owned[id] {
+			return i18n.Errorf("No session found with ID %d", id)
+		}
+	}
+	return control.store.Delete(ids...)
+}
+
+// RevokeAll logs the user out of every session
+func (control sessionController) RevokeAll(userID int) error {
+	return control.store.DeleteByUser(userID)
+}
+
+// newToken returns a random URL safe token
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 of the token. Tokens are random enough
+// to not need a salt or a slow hash like bcrypt.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.NewSessionStore()
+	uc := NewSessionUsecase(store)
+	alice, bob := cah.User{ID: 1}, cah.User{ID: 2}
+
+	s, token, err := uc.Start(alice, time.Hour, "Firefox", "127.0.0.1")
+	assert.NoError(err)
+	assert.NotEqual(token, s.TokenHash, "The token should not be stored")
+	found, ok := uc.ByToken(token)
+	assert.True(ok)
+	assert.Equal(s.ID, found.ID)
+	_, ok = uc.ByToken("not a token")
+	assert.False(ok)
+
+	bobSession, bobToken, err := uc.Start(bob, time.Hour, "", "")
+	assert.NoError(err)
+	assert.Error(uc.Revoke(alice.ID, bobSession.ID), "Users should not revoke sessions of other users")
+	_, ok = uc.ByToken(bobToken)
+	assert.True(ok)
+
+	assert.NoError(uc.Revoke(alice.ID, s.ID))
+	_, ok = uc.ByToken(token)
+	assert.False(ok, "Revoked sessions should not be valid")
+
+	assert.NoError(uc.RevokeAll(bob.ID))
+	_, ok = uc.ByToken(bobToken)
+	assert.False(ok)
+}
+
+func TestSessionExpiration(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.NewSessionStore()
+	uc := NewSessionUsecase(store)
+
+	s, token, err := uc.Start(cah.User{ID: 1}, time.Hour, "", "")
+	assert.NoError(err)
+	s.LastSeenAt = time.Now().Add(-30 * time.Minute)
+	s.ExpiresAt = s.LastSeenAt.Add(time.Hour)
+	assert.NoError(store.Touch(s.ID, s.LastSeenAt, s.ExpiresAt))
+	found, ok := uc.ByToken(token)
+	assert.True(ok)
+	assert.True(found.ExpiresAt.After(s.ExpiresAt), "Using a session should extend it")
+
+	assert.NoError(store.Touch(s.ID, found.LastSeenAt, time.Now().Add(-time.Second)))
+	_, ok = uc.ByToken(token)
+	assert.False(ok, "Expired sessions should not be valid")
+	sessions, err := store.ByUser(s.UserID)
+	assert.NoError(err)
+	assert.Len(sessions, 0, "Expired sessions should be deleted")
+}
@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/j4rv/cah"
+)
+
+// seenWindow is how long a card counts as recently seen by a user
+const seenWindow = 30 * 24 * time.Hour
+
+// favouriteCards is the amount of favourite cards in the user stats
+const favouriteCards = 5
+
+// initialRating is the rating of the users that have not finished a game yet
+const initialRating = 1500.0
+
+// ratingK is the most rating a player can win or lose in a game
+const ratingK = 32.0
+
+const defaultLeaderboardLimit = 20
+const maxLeaderboardLimit = 100
+
+type statsController struct {
+	store cah.StatsStore
+	cards cah.CardStore
+}
+
+func NewStatsUsecase(store cah.StatsStore, cards cah.CardStore) *statsController {
+	return &statsController{store: store, cards: cards}
+}
+
+// Card returns the stats of a white card
+func (control statsController) Card(cardID int) (cah.CardStats, error) {
+	cards, err := control.cards.WhitesByID(cardID)
+	if err != nil {
+		return cah.CardStats{}, err
+	}
+	stats, err := control.whitesStats(cards)
+	if err != nil {
+		return cah.CardStats{}, err
+	}
+	return stats[0], nil
+}
+
+// ExpansionCards returns the stats of every white card of the expansion,
+// sorted by win rate and then by times played, so the best cards come first
+func (control statsController) ExpansionCards(expansion string) ([]cah.CardStats, error) {
+	if _, err := control.cards.Expansion(expansion); err != nil {
+		return nil, err
+	}
+	cards, err := control.cards.ExpansionWhites(expansion)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := control.whitesStats(cards)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].WinRate != stats[j].WinRate {
+			return stats[i].WinRate > stats[j].WinRate
+		}
+		return stats[i].Played > stats[j].Played
+	})
+	return stats, nil
+}
+
+// Expansions returns the stats of each expansion, adding up the stats of its white cards
+func (control statsController) Expansions(expansions ...string) []cah.ExpansionStats {
+	ret := []cah.ExpansionStats{}
+	for _, name := range expansions {
+		cards, err := control.cards.ExpansionWhites(name)
+		checkErr(err, "statsController.Expansions")
+		stats, err := control.whitesStats(cards)
+		checkErr(err, "statsController.Expansions")
+		e := cah.ExpansionStats{Name: name, Cards: len(stats)}
+		for _, s := range stats {
+			e.Dealt += s.Dealt
+			e.Played += s.Played
+			e.Won += s.Won
+			if s.Played > 0 && s.Won == 0 {
+				e.NeverWon++
+			}
+		}
+		e.WinRate = rate(e.Won, e.Played)
+		e.PlayRate = rate(e.Played, e.Dealt)
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// RecentlySeen returns the IDs of the cards seen by any of the users during the seen window
+func (control statsController) RecentlySeen(users ...cah.User) []int {
+	ids := make([]int, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	res, err := control.store.Seen(time.Now().Add(-seenWindow), ids...)
+	checkErr(err, "statsController.RecentlySeen")
+	return res
+}
+
+// User returns the stats of the games finished by the user
+func (control statsController) User(userID int) (cah.UserStats, error) {
+	stats, err := control.store.UserStats(userID, favouriteCards)
+	if err != nil {
+		return stats, err
+	}
+	favourites := []cah.FavouriteCard{}
+	for _, f := range stats.FavouriteCards {
+		cards, err := control.cards.WhitesByID(f.CardID)
+		if err != nil {
+			// Cards from deleted expansions are not listed
+			continue
+		}
+		f.Text = cards[0].Text
+		f.Expansion = cards[0].Expansion
+		favourites = append(favourites, f)
+	}
+	stats.FavouriteCards = favourites
+	return stats, nil
+}
+
+// Leaderboard returns a page of the players ranked by their rating
+func (control statsController) Leaderboard(q cah.LeaderboardQuery) (cah.Leaderboard, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultLeaderboardLimit
+	}
+	if q.Limit > maxLeaderboardLimit {
+		q.Limit = maxLeaderboardLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	return control.store.Leaderboard(q)
+}
+
+func (control statsController) whitesStats(cards []*cah.WhiteCard) ([]cah.CardStats, error) {
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.ID
+	}
+	stored, err := control.store.CardStats(ids...)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]cah.CardStats, len(cards))
+	for i, c := range cards {
+		s := stored[c.ID]
+		s.CardID = c.ID
+		s.Text = c.Text
+		s.Expansion = c.Expansion
+		s.WinRate = rate(s.Won, s.Played)
+		s.PlayRate = rate(s.Played, s.Dealt)
+		ret[i] = s
+	}
+	return ret, nil
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// statsRecorder records the card stats of the games, with a nil store it records nothing.
+// Errors are only logged, since failing to record the stats should not stop a game.
+type statsRecorder struct {
+	store cah.StatsStore
+}
+
+func (r statsRecorder) dealt(cards []*cah.WhiteCard) {
+	if r.store != nil {
+		checkErr(r.store.AddDealt(whiteIDs(cards)...), "statsRecorder.dealt")
+	}
+}
+
+func (r statsRecorder) played(cards []*cah.WhiteCard) {
+	if r.store != nil {
+		checkErr(r.store.AddPlayed(whiteIDs(cards)...), "statsRecorder.played")
+	}
+}
+
+func (r statsRecorder) won(cards []*cah.WhiteCard) {
+	if r.store != nil {
+		checkErr(r.store.AddWon(whiteIDs(cards)...), "statsRecorder.won")
+	}
+}
+
+// seen records the cards each player saw during the round: the black card,
+// the white cards played by everyone and the cards in their own hand.
+// It needs to be called before the round cards are cleared.
+func (r statsRecorder) seen(g *cah.GameState) {
+	if r.store == nil {
+		return
+	}
+	table := []int{g.BlackCardInPlay.ID}
+	for _, p := range g.Players {
+		table = append(table, whiteIDs(p.WhiteCardsInPlay)...)
+	}
+	for _, p := range g.Players {
+		cards := append(whiteIDs(p.Hand), table...)
+		checkErr(r.store.AddSeen(p.User.ID, cards...), "statsRecorder.seen")
+	}
+}
+
+// finished records the results of a finished game for each player.
+// Games finished before any round was won are not recorded.
+func (r statsRecorder) finished(g *cah.GameState) {
+	if r.store == nil || len(g.Rounds) == 0 {
+		return
+	}
+	results := gameResults(g, time.Now())
+	ids := make([]int, len(results))
+	for i, res := range results {
+		ids[i] = res.UserID
+	}
+	ratings, err := r.store.Ratings(ids...)
+	if err != nil {
+		checkErr(err, "statsRecorder.finished")
+		return
+	}
+	rate := func(userID int) float64 {
+		if rating, ok := ratings[userID]; ok {
+			return rating
+		}
+		return initialRating
+	}
+	rateResults(results, rate)
+	checkErr(r.store.AddGameResults(results...), "statsRecorder.finished")
+}
+
+// rateResults sets the new ratings of the players with a multiplayer Elo:
+// every player is compared to every other player as if they had played a match,
+// winning it with more rounds won, and the changes are averaged so a game
+// is worth the same no matter how many players it had.
+func rateResults(results []cah.GameResult, rating func(userID int) float64) {
+	if len(results) < 2 {
+		for i := range results {
+			results[i].Rating = rating(results[i].UserID)
+		}
+		return
+	}
+	k := ratingK / float64(len(results)-1)
+	for i := range results {
+		ri := rating(results[i].UserID)
+		change := 0.0
+		for j := range results {
+			if i == j {
+				continue
+			}
+			rj := rating(results[j].UserID)
+			expected := 1 / (1 + math.Pow(10, (rj-ri)/400))
+			score := 0.5
+			if results[i].RoundsWon > results[j].RoundsWon {
+				score = 1
+			} else if results[i].RoundsWon < results[j].RoundsWon {
+				score = 0
+			}
+			change += k * (score - expected)
+		}
+		results[i].RatingChange = change
+		results[i].Rating = ri + change
+	}
+}
+
+// gameResults computes the results of the players from the rounds of the game.
+// The players with the most rounds won win the game, so a game can have more than one winner.
+func gameResults(g *cah.GameState, finishedAt time.Time) []cah.GameResult {
+	mostWon := 0
+	for _, p := range g.Players {
+		if len(p.Points) > mostWon {
+			mostWon = len(p.Points)
+		}
+	}
+	results := make([]cah.GameResult, len(g.Players))
+	for i, p := range g.Players {
+		res := cah.GameResult{
+			UserID:       p.User.ID,
+			GameStateID:  g.ID,
+			RoundsWon:    len(p.Points),
+			Won:          mostWon > 0 && len(p.Points) == mostWon,
+			WinningCards: []int{},
+			FinishedAt:   finishedAt,
+		}
+		for _, round := range g.Rounds {
+			if round.CzarID != p.User.ID {
+				res.CardsPlayed += round.BlackCard.Blanks
+			}
+			if round.WinnerID == p.User.ID {
+				res.WinningCards = append(res.WinningCards, whiteIDs(round.WhiteCards)...)
+			}
+		}
+		results[i] = res
+	}
+	return results
+}
+
+func whiteIDs(cards []*cah.WhiteCard) []int {
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		ids[i] = c.ID
+	}
+	return ids
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsRecordedDuringTheGame(t *testing.T) {
+	assert := assert.New(t)
+	stats := mem.NewStatsStore()
+	store := mem.GetGameStateStore()
+	uc := NewGameStateUsecase(store, stats)
+	s := getStateFixture()
+	_, err := store.Create(&s)
+	assert.NoError(err)
+	for i, c := range s.WhiteDeck {
+		c.ID = i + 1
+	}
+	deckIDs := whiteIDs(s.WhiteDeck)
+	for i, p := range s.Players {
+		p.User.ID = i + 1
+	}
+	s.HandSize = 3
+	assert.NoError(putBlackCardInPlay(&s))
+	stats.AddDealt(whiteIDs(playersDraw(&s))...)
+	recorded, _ := stats.CardStats(deckIDs...)
+	assert.Len(recorded, 9)
+
+	winnerCard := s.Players[1].Hand[0]
+	loserCard := s.Players[2].Hand[0]
+	assert.NoError(uc.PlayWhiteCards(1, []int{0}, &s))
+	assert.NoError(uc.PlayWhiteCards(2, []int{0}, &s))
+	assert.NoError(uc.GiveBlackCardToWinner(s.Players[1].User.ID, &s))
+
+	recorded, _ = stats.CardStats(deckIDs...)
+	assert.Equal(cah.CardStats{CardID: winnerCard.ID, Dealt: 1, Played: 1, Won: 1}, recorded[winnerCard.ID])
+	assert.Equal(cah.CardStats{CardID: loserCard.ID, Dealt: 1, Played: 1}, recorded[loserCard.ID])
+	assert.Len(recorded, 11, "The two new cards dealt should be recorded")
+	czarSeen, _ := stats.Seen(time.Time{}, 1)
+	assert.Contains(czarSeen, loserCard.ID, "The czar should have seen the played cards")
+	playerSeen, _ := stats.Seen(time.Time{}, 3)
+	assert.Contains(playerSeen, winnerCard.ID, "Other players should have seen the played cards")
+	assert.NotContains(playerSeen, s.Players[1].Hand[0].ID, "Players should not see the hands of others")
+}
+
+func TestExpansionStats(t *testing.T) {
+	assert := assert.New(t)
+	cards, teardown := getCardUsecase(t)
+	defer teardown()
+	assert.NoError(cards.Upload(cah.User{ID: 1}, cah.Expansion{Name: "Stats"},
+		strings.NewReader("Winner\nLoser\nUnplayed"), strings.NewReader("A _ card")))
+	whites := cards.ExpansionWhites("Stats")
+	stats := mem.NewStatsStore()
+	stats.AddDealt(whites[0].ID, whites[0].ID, whites[1].ID, whites[2].ID)
+	stats.AddPlayed(whites[0].ID, whites[0].ID, whites[1].ID)
+	stats.AddWon(whites[0].ID)
+	uc := NewStatsUsecase(stats, mem.GetCardStore())
+
+	c, err := uc.Card(whites[0].ID)
+	assert.NoError(err)
+	assert.Equal("Winner", c.Text)
+	assert.Equal(0.5, c.WinRate)
+	assert.Equal(1.0, c.PlayRate)
+
+	ranking, err := uc.ExpansionCards("Stats")
+	assert.NoError(err)
+	assert.Equal("Winner", ranking[0].Text)
+	assert.Equal("Loser", ranking[1].Text)
+	assert.Equal("Unplayed", ranking[2].Text)
+	_, err = uc.ExpansionCards("Does not exist")
+	assert.Error(err)
+
+	e := uc.Expansions("Stats")[0]
+	assert.Equal(cah.ExpansionStats{Name: "Stats", Cards: 3, Dealt: 4, Played: 3, Won: 1,
+		WinRate: 1.0 / 3, PlayRate: 0.75, NeverWon: 1}, e)
+}
+
+func TestGameResults(t *testing.T) {
+	assert := assert.New(t)
+	s := getStateFixture()
+	for i, p := range s.Players {
+		p.User.ID = i + 1
+	}
+	pick2 := &cah.BlackCard{Blanks: 2}
+	pick1 := &cah.BlackCard{Blanks: 1}
+	s.Rounds = []*cah.Round{
+		{Number: 1, CzarID: 1, WinnerID: 2, BlackCard: pick2, WhiteCards: []*cah.WhiteCard{{ID: 10}, {ID: 11}}},
+		{Number: 2, CzarID: 2, WinnerID: 3, BlackCard: pick1, WhiteCards: []*cah.WhiteCard{{ID: 12}}},
+	}
+	s.Players[1].Points = []*cah.BlackCard{pick2}
+	s.Players[2].Points = []*cah.BlackCard{pick1}
+	now := time.Now()
+
+	results := gameResults(&s, now)
+	assert.Equal([]cah.GameResult{
+		{UserID: 1, CardsPlayed: 1, WinningCards: []int{}, FinishedAt: now},
+		{UserID: 2, Won: true, RoundsWon: 1, CardsPlayed: 2, WinningCards: []int{10, 11}, FinishedAt: now},
+		{UserID: 3, Won: true, RoundsWon: 1, CardsPlayed: 3, WinningCards: []int{12}, FinishedAt: now},
+	}, results)
+}
+
+func TestStatsRecordedWhenTheGameEnds(t *testing.T) {
+	assert := assert.New(t)
+	stats := mem.NewStatsStore()
+	store := mem.GetGameStateStore()
+	uc := NewGameStateUsecase(store, stats)
+	s := getStateFixture()
+	_, err := store.Create(&s)
+	assert.NoError(err)
+	assert.NoError(uc.End(&s))
+	userStats, err := stats.UserStats(1, 0)
+	assert.NoError(err)
+	assert.Equal(0, userStats.GamesPlayed, "Games without rounds should not be recorded")
+
+	s = getStateFixture()
+	_, err = store.Create(&s)
+	assert.NoError(err)
+	for i, p := range s.Players {
+		p.User.ID = i + 1
+	}
+	s.MaxRounds = 1
+	s.HandSize = 3
+	assert.NoError(putBlackCardInPlay(&s))
+	playersDraw(&s)
+	assert.NoError(uc.PlayWhiteCards(1, []int{0}, &s))
+	assert.NoError(uc.PlayWhiteCards(2, []int{0}, &s))
+	assert.NoError(uc.GiveBlackCardToWinner(2, &s))
+	assert.Equal(cah.Finished, s.Phase)
+	for id := 1; id <= 3; id++ {
+		userStats, _ = stats.UserStats(id, 0)
+		assert.Equal(1, userStats.GamesPlayed)
+	}
+
+	userStats, err = NewStatsUsecase(stats, mem.GetCardStore()).User(2)
+	assert.NoError(err)
+	assert.Equal(1, userStats.GamesPlayed)
+	assert.Equal(1, userStats.GamesWon)
+	assert.Equal(1, userStats.RoundsWon)
+	assert.Equal(1, userStats.CardsPlayed)
+	assert.Len(userStats.FavouriteCards, 0, "Cards not in the card store are not listed")
+
+	ratings, err := stats.Ratings(1, 2, 3)
+	assert.NoError(err)
+	assert.True(ratings[2] > initialRating, "The winner should win rating")
+	assert.True(ratings[1] < initialRating, "The losers should lose rating")
+	assert.Equal(ratings[1], ratings[3])
+}
+
+func TestRateResults(t *testing.T) {
+	assert := assert.New(t)
+	ratings := map[int]float64{1: 1500, 2: 1500, 3: 1700}
+	rating := func(userID int) float64 { return ratings[userID] }
+
+	results := []cah.GameResult{
+		{UserID: 1, RoundsWon: 3},
+		{UserID: 2, RoundsWon: 1},
+		{UserID: 3, RoundsWon: 1},
+	}
+	rateResults(results, rating)
+	total := 0.0
+	for _, r := range results {
+		total += r.RatingChange
+		assert.InDelta(ratings[r.UserID]+r.RatingChange, r.Rating, 1e-9)
+	}
+	assert.InDelta(0, total, 1e-9, "The rating won by some players is lost by the others")
+	assert.True(results[0].RatingChange > 16.0, "Beating a stronger player should be worth more")
+	assert.True(results[0].RatingChange < 32.0, "A game can not be worth more than K")
+	assert.True(results[1].RatingChange > results[2].RatingChange, "Tying with a stronger player should be worth more than tying with a weaker one")
+	assert.True(results[2].RatingChange < 0, "Losing to a weaker player should lose rating")
+
+	single := []cah.GameResult{{UserID: 3, RoundsWon: 2}}
+	rateResults(single, rating)
+	assert.Equal(1700.0, single[0].Rating, "A player can not win rating alone")
+	assert.Equal(0.0, single[0].RatingChange)
+}
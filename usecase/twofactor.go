@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/totp"
+)
+
+const recoveryCodesAmount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type twoFactorController struct {
+	store  cah.TwoFactorStore
+	issuer string
+}
+
+// NewTwoFactorUsecase returns the two-factor usecases, issuer is the name shown by the authenticator apps
+func NewTwoFactorUsecase(store cah.TwoFactorStore, issuer string) *twoFactorController {
+	return &twoFactorController{store: store, issuer: issuer}
+}
+
+// Enroll creates a new secret for the user, replacing any unconfirmed one
+func (control twoFactorController) Enroll(u cah.User) (cah.TwoFactorEnrollment, error) {
+	if u.Guest {
+		return cah.TwoFactorEnrollment{}, errors.New("Guests cannot enable two-factor authentication")
+	}
+	if control.Enabled(u.ID) {
+		return cah.TwoFactorEnrollment{}, errors.New("Two-factor authentication is already enabled")
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return cah.TwoFactorEnrollment{}, err
+	}
+	if err := control.store.SetTwoFactor(cah.TwoFactor{UserID: u.ID, Secret: secret}); err != nil {
+		return cah.TwoFactorEnrollment{}, err
+	}
+	return cah.TwoFactorEnrollment{Secret: secret, URI: totp.URI(control.issuer, u.Username, secret)}, nil
+}
+
+// Confirm enables the two-factor authentication if the code matches the enrolled secret,
+// proving that the authenticator app was set up correctly
+func (control twoFactorController) Confirm(u cah.User, code string) ([]string, error) {
+	tf, err := control.store.TwoFactor(u.ID)
+	if err != nil {
+		return nil, errors.New("Two-factor authentication needs to be enrolled first")
+	}
+	if tf.Enabled {
+		return nil, errors.New("Two-factor authentication is already enabled")
+	}
+	step, ok := totp.Validate(tf.Secret, code, time.Now())
+	if !ok {
+		return nil, cah.ErrWrongCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := control.store.SetRecoveryCodes(u.ID, hashes...); err != nil {
+		return nil, err
+	}
+	tf.Enabled = true
+	tf.LastStep = step
+	if err := control.store.SetTwoFactor(tf); err != nil {
+		return nil, err
+	}
+	log.Printf("User '%s' with id %d enabled the two-factor authentication", u.Username, u.ID)
+	return codes, nil
+}
+
+// Disable turns off the two-factor authentication, it needs a code or a recovery code
+func (control twoFactorController) Disable(u cah.User, code string) error {
+	if !control.Enabled(u.ID) {
+		return errors.New("Two-factor authentication is not enabled")
+	}
+	if !control.Verify(u.ID, code) {
+		return cah.ErrWrongCode
+	}
+	log.Printf("User '%s' with id %d disabled the two-factor authentication", u.Username, u.ID)
+	return control.store.DeleteTwoFactor(u.ID)
+}
+
+func (control twoFactorController) Enabled(userID int) bool {
+	tf, err := control.store.TwoFactor(userID)
+	return err == nil && tf.Enabled
+}
+
+// Verify checks a code from the authenticator app, or else a recovery code.
+// Codes are rejected if their time step was already used, so a stolen code can not be replayed.
+func (control twoFactorController) Verify(userID int, code string) bool {
+	tf, err := control.store.TwoFactor(userID)
+	if err != nil || !tf.Enabled {
+		return false
+	}
+	if step, ok := totp.Validate(tf.Secret, code, time.Now()); ok {
+		if step <= tf.LastStep {
+			return false
+		}
+		tf.LastStep = step
+		checkErr(control.store.SetTwoFactor(tf), "twoFactorController.Verify")
+		return true
+	}
+	used, err := control.store.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+	checkErr(err, "twoFactorController.Verify")
+	if used {
+		log.Printf("User with id %d used a recovery code", userID)
+	}
+	return used
+}
+
+// RecoveryCodes returns how many unused recovery codes the user has
+func (control twoFactorController) RecoveryCodes(userID int) (int, error) {
+	return control.store.RecoveryCodes(userID)
+}
+
+// newRecoveryCodes returns random codes like "abcde-fghij", and their hashes
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodesAmount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		c := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, c[:5]+"-"+c[5:])
+		hashes = append(hashes, hashToken(c))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores the case, the dashes and the spaces of a recovery code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/db/mem"
+	"github.com/j4rv/cah/lib/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.NewTwoFactorStore()
+	uc := NewTwoFactorUsecase(store, "CAH")
+	u := cah.User{ID: 1, Username: "Red"}
+
+	enrollment, err := uc.Enroll(u)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(enrollment.URI, "otpauth://totp/CAH:Red?"))
+	assert.False(uc.Enabled(u.ID), "Enrolling should not enable it until it is confirmed")
+
+	_, err = uc.Confirm(u, "000000")
+	assert.Error(err)
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	assert.NoError(err)
+	recovery, err := uc.Confirm(u, code)
+	assert.NoError(err)
+	assert.Len(recovery, recoveryCodesAmount)
+	assert.True(uc.Enabled(u.ID))
+	for _, c := range recovery {
+		used, err := store.UseRecoveryCode(u.ID, c)
+		assert.NoError(err)
+		assert.False(used, "Recovery codes should be stored hashed")
+	}
+	_, err = uc.Enroll(u)
+	assert.Error(err, "Enrolling again should need to disable it first")
+	_, err = uc.Enroll(cah.User{ID: 2, Guest: true})
+	assert.Error(err, "Guests can not log in again, so they do not need it")
+}
+
+func TestTwoFactorVerify(t *testing.T) {
+	assert := assert.New(t)
+	store := mem.NewTwoFactorStore()
+	uc := NewTwoFactorUsecase(store, "CAH")
+	u := cah.User{ID: 1, Username: "Red"}
+	secret := "JBSWY3DPEHPK3PXP"
+	store.SetTwoFactor(cah.TwoFactor{UserID: u.ID, Secret: secret, Enabled: true})
+	_, hashes, err := newRecoveryCodes()
+	assert.NoError(err)
+	store.SetRecoveryCodes(u.ID, hashes[0], hashToken("abcdefghij"))
+
+	code, err := totp.Code(secret, time.Now())
+	assert.NoError(err)
+	assert.True(uc.Verify(u.ID, code))
+	assert.False(uc.Verify(u.ID, code), "Codes should not be used twice")
+	assert.False(uc.Verify(2, code), "Users without two-factor authentication can not verify codes")
+
+	assert.True(uc.Verify(u.ID, "ABCDE-fghij"), "Recovery codes should ignore the case and the dashes")
+	assert.False(uc.Verify(u.ID, "abcde-fghij"), "Recovery codes should be used once")
+	n, err := uc.RecoveryCodes(u.ID)
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	assert.Error(uc.Disable(u, "wrong"))
+	assert.True(uc.Enabled(u.ID))
+	store.SetRecoveryCodes(u.ID, hashToken("abcdefghij"))
+	assert.NoError(uc.Disable(u, "abcde-fghij"))
+	assert.False(uc.Enabled(u.ID))
+}
@@ -3,12 +3,27 @@ package usecase
 import (
 	"errors"
 	"log"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/j4rv/cah"
+	"github.com/j4rv/cah/lib/i18n"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// maxUsernameLength is the longest username allowed by the user table
+const maxUsernameLength = 36
+
+// guestPassword is stored as the password of the guests.
+// It is not a valid bcrypt hash, so nobody can log in as a guest.
+const guestPassword = "guest"
+
+// externalPassword is stored as the password of the users created by an external login provider.
+// It is not a valid bcrypt hash either, so they can only log in through the provider.
+const externalPassword = "external"
+
 type userController struct {
 	store cah.UserStore
 }
@@ -18,24 +33,15 @@ func NewUserUsecase(store cah.UserStore) *userController {
 }
 
 func (uc userController) Register(name, pass string) (cah.User, error) {
-	trimmedName := strings.TrimSpace(name)
-	if trimmedName == "" {
-		return cah.User{}, errors.New("Username cannot be empty.")
-	}
-	if pass == "" {
-		return cah.User{}, errors.New("Password cannot be empty.")
-	}
-	_, err := uc.store.ByName(name)
-	if err == nil {
-		return cah.User{}, errors.New("That username already exists. Please try another.")
+	trimmedName, err := uc.availableUsername(name)
+	if err != nil {
+		return cah.User{}, err
 	}
-	passHash, err := userPassHash(pass)
+	passHash, err := newPassHash(pass)
 	if err != nil {
-		//Never log passwords! But this one caused an error and will not be stored, its an ok exception
-		log.Println("ERROR while trying to hash password.", pass, err)
-		return cah.User{}, errors.New("That password could not be protected correctly. Please try another.")
+		return cah.User{}, err
 	}
-	return uc.store.Create(name, passHash)
+	return uc.store.Create(trimmedName, passHash)
 }
 
 func (uc userController) ByID(id int) (cah.User, bool) {
@@ -43,6 +49,11 @@ func (uc userController) ByID(id int) (cah.User, bool) {
 	return u, err == nil
 }
 
+func (uc userController) ByName(name string) (cah.User, bool) {
+	u, err := uc.store.ByName(strings.TrimSpace(name))
+	return u, err == nil
+}
+
 func (uc userController) Login(name, pass string) (cah.User, bool) {
 	trimmedName := strings.TrimSpace(name)
 	u, err := uc.store.ByName(trimmedName)
@@ -55,8 +66,286 @@ func (uc userController) Login(name, pass string) (cah.User, bool) {
 	return u, true
 }
 
+// SetLocale changes the language of the user, an empty locale uses the browser language
+func (uc userController) SetLocale(u cah.User, locale string) error {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if locale != "" && !i18n.Supported(locale) {
+		return i18n.Errorf("The language '%s' is not supported", locale)
+	}
+	return uc.store.SetLocale(u.ID, locale)
+}
+
+func (uc userController) HasPassword(u cah.User) bool {
+	stored, err := uc.store.ByID(u.ID)
+	return err == nil && stored.Password != externalPassword
+}
+
+// ChangePassword sets a new password, as long as the current one is correct.
+// Users created by a login provider can set their first password without it.
+func (uc userController) ChangePassword(u cah.User, current, pass string) error {
+	if err := uc.checkPassword(u, current); err != nil {
+		return err
+	}
+	passHash, err := newPassHash(pass)
+	if err != nil {
+		return err
+	}
+	return uc.store.SetPassword(u.ID, passHash)
+}
+
+// ChangeUsername renames the user, with the same checks as when registering
+func (uc userController) ChangeUsername(u cah.User, name string) (cah.User, error) {
+	trimmedName, err := uc.availableUsername(name)
+	if err != nil {
+		return u, err
+	}
+	if err := uc.store.SetUsername(u.ID, trimmedName); err != nil {
+		return u, err
+	}
+	log.Printf("User '%s' with id %d is now called '%s'", u.Username, u.ID, trimmedName)
+	u.Username = trimmedName
+	return u, nil
+}
+
+// Delete removes the account of the user, the password is needed so a stolen session can not delete it
+func (uc userController) Delete(u cah.User, pass string) error {
+	if err := uc.checkPassword(u, pass); err != nil {
+		return err
+	}
+	log.Printf("Deleting user '%s' with id %d", u.Username, u.ID)
+	return uc.store.Delete(u.ID)
+}
+
+// RegisterGuest creates a temporary user with the nickname, which needs to be available like any username
+func (uc userController) RegisterGuest(nickname string) (cah.User, error) {
+	trimmedName, err := uc.availableUsername(nickname)
+	if err != nil {
+		return cah.User{}, err
+	}
+	return uc.store.CreateGuest(trimmedName, guestPassword)
+}
+
+// Upgrade turns a guest into a full user, keeping their ID and so their stats.
+// An empty username keeps the nickname.
+func (uc userController) Upgrade(u cah.User, name, pass string) (cah.User, error) {
+	if !u.Guest {
+		return u, errors.New("Only guests can upgrade their account")
+	}
+	trimmedName := strings.TrimSpace(name)
+	if trimmedName == "" || trimmedName == u.Username {
+		trimmedName = u.Username
+	} else {
+		var err error
+		if trimmedName, err = uc.availableUsername(trimmedName); err != nil {
+			return u, err
+		}
+	}
+	passHash, err := newPassHash(pass)
+	if err != nil {
+		return u, err
+	}
+	if err := uc.store.Upgrade(u.ID, trimmedName, passHash); err != nil {
+		return u, err
+	}
+	log.Printf("Guest '%s' with id %d upgraded to the user '%s'", u.Username, u.ID, trimmedName)
+	return uc.store.ByID(u.ID)
+}
+
+func (uc userController) GuestSeen(u cah.User) error {
+	if !u.Guest {
+		return nil
+	}
+	return uc.store.GuestSeen(u.ID, time.Now())
+}
+
+// DeleteGuests deletes the guests not created nor seen since the time, and returns them
+// so they can be removed from their games
+func (uc userController) DeleteGuests(seenBefore time.Time) ([]cah.User, error) {
+	guests, err := uc.store.Guests(seenBefore)
+	if err != nil {
+		return nil, err
+	}
+	deleted := []cah.User{}
+	for _, g := range guests {
+		if err := uc.store.Delete(g.ID); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, g)
+	}
+	return deleted, nil
+}
+
+// SetRole changes the privileges of the user
+func (uc userController) SetRole(userID int, role cah.Role) error {
+	for _, r := range cah.Roles {
+		if r == role {
+			return uc.store.SetRole(userID, role)
+		}
+	}
+	return i18n.Errorf("The role '%s' does not exist", role)
+}
+
+// Ban stops the user from logging in, or lets them log in again
+func (uc userController) Ban(userID int, banned bool) error {
+	if _, err := uc.store.ByID(userID); err != nil {
+		return i18n.Errorf("No user found with ID %d", userID)
+	}
+	return uc.store.SetBanned(userID, banned)
+}
+
+func (uc userController) ResetPassword(userID int) (string, error) {
+	u, err := uc.store.ByID(userID)
+	if err != nil {
+		return "", i18n.Errorf("No user found with ID %d", userID)
+	}
+	if u.Guest {
+		return "", errors.New("Guests do not have a password")
+	}
+	pass, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	// Long enough, and easier to type than a whole token
+	pass = pass[:16]
+	passHash, err := newPassHash(pass)
+	if err != nil {
+		return "", err
+	}
+	return pass, uc.store.SetPassword(userID, passHash)
+}
+
+const defaultUserListLimit = 20
+const maxUserListLimit = 100
+
+func (uc userController) List(q cah.UserQuery) (cah.UserList, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultUserListLimit
+	}
+	if q.Limit > maxUserListLimit {
+		q.Limit = maxUserListLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	q.Query = strings.TrimSpace(q.Query)
+	return uc.store.List(q)
+}
+
+// LoginExternal returns the user linked to the identity. On the first login a user is created,
+// named as the provider suggests, or with a number after the name if it is taken.
+func (uc userController) LoginExternal(id cah.ExternalIdentity) (cah.User, error) {
+	if id.Issuer == "" || id.Subject == "" {
+		return cah.User{}, errors.New("The external identity is not valid")
+	}
+	if u, err := uc.store.ByIdentity(id.Issuer, id.Subject); err == nil {
+		return u, nil
+	}
+	name, err := uc.externalUsername(id.Username)
+	if err != nil {
+		return cah.User{}, err
+	}
+	u, err := uc.store.Create(name, externalPassword)
+	if err != nil {
+		return u, err
+	}
+	if err := uc.store.LinkIdentity(u.ID, id.Issuer, id.Subject); err != nil {
+		checkErr(uc.store.Delete(u.ID), "userController.LoginExternal")
+		return cah.User{}, err
+	}
+	log.Printf("User '%s' with id %d was created by the login provider %s", u.Username, u.ID, id.Issuer)
+	return u, nil
+}
+
+// LinkExternal lets an existing user log in with the identity too
+func (uc userController) LinkExternal(u cah.User, id cah.ExternalIdentity) error {
+	if id.Issuer == "" || id.Subject == "" {
+		return errors.New("The external identity is not valid")
+	}
+	if u.Guest {
+		return errors.New("Guests cannot link their account")
+	}
+	if linked, err := uc.store.ByIdentity(id.Issuer, id.Subject); err == nil {
+		if linked.ID == u.ID {
+			return nil
+		}
+		return errors.New("That account is already linked to another user")
+	}
+	log.Printf("User '%s' with id %d linked their account of the login provider %s", u.Username, u.ID, id.Issuer)
+	return uc.store.LinkIdentity(u.ID, id.Issuer, id.Subject)
+}
+
 // internal
 
+// maxExternalUsernameTries is how many numbers are tried after a taken name, like "Name 2"
+const maxExternalUsernameTries = 100
+
+// externalUsername returns an available username based on the suggested one
+func (uc userController) externalUsername(suggested string) (string, error) {
+	base := []rune(strings.TrimSpace(suggested))
+	if len(base) == 0 {
+		base = []rune("Player")
+	}
+	if len(base) > maxUsernameLength {
+		base = base[:maxUsernameLength]
+	}
+	if name, err := uc.availableUsername(string(base)); err == nil {
+		return name, nil
+	}
+	for i := 2; i <= maxExternalUsernameTries; i++ {
+		suffix := []rune(" " + strconv.Itoa(i))
+		prefix := base
+		if len(prefix)+len(suffix) > maxUsernameLength {
+			prefix = prefix[:maxUsernameLength-len(suffix)]
+		}
+		if name, err := uc.availableUsername(string(prefix) + string(suffix)); err == nil {
+			return name, nil
+		}
+	}
+	return "", errors.New("That username already exists. Please try another.")
+}
+
+// availableUsername returns the trimmed username if it is valid and nobody uses it yet
+func (uc userController) availableUsername(name string) (string, error) {
+	trimmedName := strings.TrimSpace(name)
+	if trimmedName == "" {
+		return "", errors.New("Username cannot be empty.")
+	}
+	if utf8.RuneCountInString(trimmedName) > maxUsernameLength {
+		return "", i18n.Errorf("Usernames cannot be longer than %d characters.", maxUsernameLength)
+	}
+	if _, err := uc.store.ByName(trimmedName); err == nil {
+		return "", errors.New("That username already exists. Please try another.")
+	}
+	return trimmedName, nil
+}
+
+// checkPassword fails if the password is not the one of the user.
+// The user is read again from the store, since the session user could be outdated.
+// It accepts any password for users without one, which were created by a login provider.
+func (uc userController) checkPassword(u cah.User, pass string) error {
+	stored, err := uc.store.ByID(u.ID)
+	if err == nil && stored.Password == externalPassword {
+		return nil
+	}
+	if err != nil || !userCorrectPass(pass, stored.Password) {
+		return cah.ErrWrongPassword
+	}
+	return nil
+}
+
+func newPassHash(pass string) (string, error) {
+	if pass == "" {
+		return "", errors.New("Password cannot be empty.")
+	}
+	passHash, err := userPassHash(pass)
+	if err != nil {
+		//Never log passwords! But this one caused an error and will not be stored, its an ok exception
+		log.Println("ERROR while trying to hash password.", pass, err)
+		return "", errors.New("That password could not be protected correctly. Please try another.")
+	}
+	return passHash, nil
+}
+
 const userPassCost = 10
 
 func userPassHash(p string) (string, error) {
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -78,3 +79,231 @@ func TestGetUserByLogin(t *testing.T) {
 		t.Error("Error should not be nil")
 	}
 }
+
+func TestRegisterChecks(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	_, err := usecase.Register("   ", "pass")
+	assert.Error(err)
+	_, err = usecase.Register("Checks", "")
+	assert.Error(err)
+	_, err = usecase.Register(" Green ", "pass")
+	assert.Error(err, "Usernames should be unique after trimming them")
+	_, err = usecase.Register("ñññññññññññññññññññññññññññññññññññññ", "pass")
+	assert.Error(err, "Usernames should not be longer than 36 characters")
+	u, err := usecase.Register(" ññññññññññññññññññññññññññññññññññññ ", "pass")
+	assert.NoError(err, "The length should count characters and not bytes")
+	assert.Equal("ññññññññññññññññññññññññññññññññññññ", u.Username)
+}
+
+func TestChangePassword(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.Register("Changing password", "old")
+	assert.NoError(err)
+
+	assert.Error(usecase.ChangePassword(u, "wrong", "new"), "The current password should be needed")
+	assert.Error(usecase.ChangePassword(u, "old", ""))
+	assert.NoError(usecase.ChangePassword(u, "old", "new"))
+	_, ok := usecase.Login("Changing password", "old")
+	assert.False(ok)
+	_, ok = usecase.Login("Changing password", "new")
+	assert.True(ok)
+}
+
+func TestChangeUsername(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.Register("Changing name", "pass")
+	assert.NoError(err)
+
+	_, err = usecase.ChangeUsername(u, "Green")
+	assert.Error(err, "Usernames should be unique")
+	_, err = usecase.ChangeUsername(u, "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX")
+	assert.Error(err)
+	u, err = usecase.ChangeUsername(u, " Changed name ")
+	assert.NoError(err)
+	assert.Equal("Changed name", u.Username)
+	_, ok := usecase.Login("Changed name", "pass")
+	assert.True(ok)
+}
+
+func TestDeleteUser(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.Register("Deleted", "pass")
+	assert.NoError(err)
+
+	assert.Error(usecase.Delete(u, "wrong"), "The password should be needed")
+	assert.NoError(usecase.Delete(u, "pass"))
+	_, ok := usecase.ByID(u.ID)
+	assert.False(ok)
+	_, ok = usecase.Login("Deleted", "pass")
+	assert.False(ok)
+}
+
+func TestGuests(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	_, err := usecase.RegisterGuest("Green")
+	assert.Error(err, "Guests should not take the name of other users")
+	guest, err := usecase.RegisterGuest(" Guest ")
+	assert.NoError(err)
+	assert.True(guest.Guest)
+	assert.Equal("Guest", guest.Username)
+	_, ok := usecase.Login("Guest", guestPassword)
+	assert.False(ok, "Nobody should log in as a guest")
+
+	red, _ := usecase.Login("Red", "Red")
+	_, err = usecase.Upgrade(red, "", "pass")
+	assert.Error(err, "Only guests can upgrade")
+	_, err = usecase.Upgrade(guest, "", "")
+	assert.Error(err)
+	u, err := usecase.Upgrade(guest, "", "pass")
+	assert.NoError(err)
+	assert.False(u.Guest)
+	assert.Equal(guest.ID, u.ID, "The upgraded user should keep the ID of the guest, and so their stats")
+	_, ok = usecase.Login("Guest", "pass")
+	assert.True(ok)
+
+	old, err := usecase.RegisterGuest("Old guest")
+	assert.NoError(err)
+	active, err := usecase.RegisterGuest("Active guest")
+	assert.NoError(err)
+	assert.NoError(usecase.GuestSeen(active))
+	assert.NoError(mem.GetUserStore().GuestSeen(active.ID, time.Now().Add(time.Minute)))
+	deleted, err := usecase.DeleteGuests(time.Now().Add(time.Second))
+	assert.NoError(err)
+	assert.Len(deleted, 1, "Upgraded guests should not be deleted")
+	assert.Equal(old.ID, deleted[0].ID)
+	_, ok = usecase.ByID(old.ID)
+	assert.False(ok)
+	_, ok = usecase.ByID(active.ID)
+	assert.True(ok, "Guests seen since should not be deleted")
+	deleted, err = usecase.DeleteGuests(time.Now().Add(2 * time.Minute))
+	assert.NoError(err)
+	assert.Len(deleted, 1)
+	assert.Equal(active.ID, deleted[0].ID)
+}
+
+func TestRolesAndBans(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.Register("Moderated", "pass")
+	assert.NoError(err)
+	assert.Equal(cah.RoleUser, u.Role)
+
+	assert.Error(usecase.SetRole(u.ID, "king"))
+	assert.NoError(usecase.SetRole(u.ID, cah.RoleModerator))
+	u, _ = usecase.ByID(u.ID)
+	assert.Equal(cah.RoleModerator, u.Role)
+
+	assert.Error(usecase.Ban(-1, true))
+	assert.NoError(usecase.Ban(u.ID, true))
+	u, _ = usecase.ByID(u.ID)
+	assert.True(u.Banned)
+	assert.NoError(usecase.Ban(u.ID, false))
+	u, _ = usecase.ByID(u.ID)
+	assert.False(u.Banned)
+}
+
+func TestResetPassword(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.Register("Forgetful", "pass")
+	assert.NoError(err)
+
+	pass, err := usecase.ResetPassword(u.ID)
+	assert.NoError(err)
+	assert.Len(pass, 16)
+	_, ok := usecase.Login("Forgetful", "pass")
+	assert.False(ok)
+	_, ok = usecase.Login("Forgetful", pass)
+	assert.True(ok)
+
+	guest, err := usecase.RegisterGuest("Passwordless guest")
+	assert.NoError(err)
+	_, err = usecase.ResetPassword(guest.ID)
+	assert.Error(err, "Guests do not have a password to reset")
+}
+
+func TestListUsers(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	list, err := usecase.List(cah.UserQuery{Query: "Yell"})
+	assert.NoError(err)
+	assert.Equal(1, list.Total)
+	assert.Equal("Yellow", list.Users[0].Username)
+
+	list, err = usecase.List(cah.UserQuery{Limit: 1})
+	assert.NoError(err)
+	assert.Len(list.Users, 1)
+	assert.True(list.Total > 1, "The total should count every user, not only the listed ones")
+}
+
+func TestLoginExternal(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	id := cah.ExternalIdentity{Issuer: "https://sso.example.com", Subject: "1234", Username: "Green"}
+
+	_, err := usecase.LoginExternal(cah.ExternalIdentity{Issuer: id.Issuer})
+	assert.Error(err, "Identities need a subject")
+	u, err := usecase.LoginExternal(id)
+	assert.NoError(err)
+	assert.Equal("Green 2", u.Username, "Taken names should get a number")
+	again, err := usecase.LoginExternal(id)
+	assert.NoError(err)
+	assert.Equal(u.ID, again.ID, "The next logins should find the same user")
+	_, ok := usecase.Login("Green 2", externalPassword)
+	assert.False(ok, "Users created by the provider should not log in with a password")
+
+	other, err := usecase.LoginExternal(cah.ExternalIdentity{Issuer: "https://other.example.com", Subject: "1234"})
+	assert.NoError(err)
+	assert.NotEqual(u.ID, other.ID, "Subjects of other issuers are other users")
+
+	long, err := usecase.LoginExternal(cah.ExternalIdentity{Issuer: id.Issuer, Subject: "long",
+		Username: "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX"})
+	assert.NoError(err)
+	assert.Len(long.Username, maxUsernameLength)
+}
+
+func TestExternalUserPassword(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.LoginExternal(cah.ExternalIdentity{Issuer: "https://sso.example.com", Subject: "first password", Username: "First password"})
+	assert.NoError(err)
+
+	assert.False(usecase.HasPassword(u))
+	assert.NoError(usecase.ChangePassword(u, "", "new"), "Users without a password should be able to set one")
+	assert.True(usecase.HasPassword(u))
+	_, ok := usecase.Login("First password", "new")
+	assert.True(ok)
+	assert.Error(usecase.ChangePassword(u, "", "other"), "Once set, the password should be needed to change it")
+
+	deleted, err := usecase.LoginExternal(cah.ExternalIdentity{Issuer: "https://sso.example.com", Subject: "deleted", Username: "Deleted external"})
+	assert.NoError(err)
+	assert.NoError(usecase.Delete(deleted, ""), "Users without a password should be able to delete their account")
+	_, ok = usecase.ByID(deleted.ID)
+	assert.False(ok)
+}
+
+func TestLinkExternal(t *testing.T) {
+	assert := assert.New(t)
+	usecase := getUserUsecase()
+	u, err := usecase.Register("Linked", "pass")
+	assert.NoError(err)
+	id := cah.ExternalIdentity{Issuer: "https://sso.example.com", Subject: "linked"}
+
+	assert.NoError(usecase.LinkExternal(u, id))
+	assert.NoError(usecase.LinkExternal(u, id), "Linking twice should not fail")
+	found, err := usecase.LoginExternal(id)
+	assert.NoError(err)
+	assert.Equal(u.ID, found.ID)
+
+	other, err := usecase.Register("Not linked", "pass")
+	assert.NoError(err)
+	assert.Error(usecase.LinkExternal(other, id), "An identity should only link one user")
+	guest, err := usecase.RegisterGuest("Linking guest")
+	assert.NoError(err)
+	assert.Error(usecase.LinkExternal(guest, cah.ExternalIdentity{Issuer: id.Issuer, Subject: "guest"}))
+}
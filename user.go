@@ -1,19 +1,62 @@
 package cah
 
 import (
+	"errors"
 	"time"
 )
 
+// ErrWrongPassword is returned when the current password of the user, asked to confirm a change, is not correct
+var ErrWrongPassword = errors.New("The password you entered is incorrect.")
+
 type UserStore interface {
 	Create(username, password string) (User, error)
 	ByName(name string) (User, error)
 	ByID(id int) (User, error)
+	SetLocale(userID int, locale string) error
+	SetPassword(userID int, password string) error
+	SetUsername(userID int, username string) error
+	Delete(userID int) error
+	CreateGuest(username, password string) (User, error)
+	Upgrade(userID int, username, password string) error
+	// GuestSeen stores the last time the guest made a request
+	GuestSeen(userID int, at time.Time) error
+	// Guests returns the guests that were not created nor seen since the time
+	Guests(seenBefore time.Time) ([]User, error)
+	SetRole(userID int, role Role) error
+	SetBanned(userID int, banned bool) error
+	List(UserQuery) (UserList, error)
+	LinkIdentity(userID int, issuer, subject string) error
+	ByIdentity(issuer, subject string) (User, error)
 }
 
 type UserUsecases interface {
 	Register(username, password string) (User, error)
 	Login(name, pass string) (u User, ok bool)
 	ByID(id int) (u User, ok bool)
+	ByName(name string) (u User, ok bool)
+	SetLocale(u User, locale string) error
+	// HasPassword is false for the users created by a login provider that did not set a password yet
+	HasPassword(u User) bool
+	// ChangePassword does not check the current password of users without one,
+	// the server needs to confirm who they are by other means
+	ChangePassword(u User, current, password string) error
+	ChangeUsername(u User, username string) (User, error)
+	// Delete does not check the password of users without one, like ChangePassword
+	Delete(u User, password string) error
+	RegisterGuest(nickname string) (User, error)
+	Upgrade(u User, username, password string) (User, error)
+	// GuestSeen marks the guest as active, so they are not deleted yet. It does nothing for other users.
+	GuestSeen(u User) error
+	DeleteGuests(seenBefore time.Time) ([]User, error)
+	SetRole(userID int, role Role) error
+	Ban(userID int, banned bool) error
+	// ResetPassword sets a random password and returns it, so an admin can send it to the user
+	ResetPassword(userID int) (string, error)
+	List(UserQuery) (UserList, error)
+	// LoginExternal returns the user linked to the identity, creating it on the first login
+	LoginExternal(id ExternalIdentity) (User, error)
+	// LinkExternal links the identity to an existing user, so they can log in with it
+	LinkExternal(u User, id ExternalIdentity) error
 }
 
 type User struct {
@@ -21,5 +64,43 @@ type User struct {
 	Username  string    `json:"username"`
 	Password  string    `json:"-"`
 	CreatedAt time.Time `json:"-" db:"created_at"`
-	// Games played, games won, cards played...
+	// Locale is the language chosen by the user, empty to use the browser language
+	Locale string `json:"locale" db:"locale"`
+	// Guest users can not log in again, they are deleted after a while unless they upgrade to a full account
+	Guest bool `json:"guest" db:"guest"`
+	Role  Role `json:"role" db:"role"`
+	// Banned users can not log in
+	Banned bool `json:"banned" db:"banned"`
+}
+
+// Role gives privileges to the users: moderators review the cards and admins manage everything
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+// UserQuery selects the users whose name contains Query, with paging
+type UserQuery struct {
+	Query  string
+	Offset int
+	Limit  int
+}
+
+// UserList holds a page of users, Total is the amount of users in all the pages
+type UserList struct {
+	Total int    `json:"total"`
+	Users []User `json:"users"`
+}
+
+// ExternalIdentity is a user of an external login provider, like an OpenID Connect one.
+// The subject identifies the user inside the issuer, Username is the name suggested for new users.
+type ExternalIdentity struct {
+	Issuer   string
+	Subject  string
+	Username string
 }